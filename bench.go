@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// benchPercentiles are the percentiles of create and remove latencies reported by bench
+var benchPercentiles = []float64{50, 90, 99}
+
+// benchResult holds the latencies of the create/remove cycles of one server type and location
+type benchResult struct {
+	serverType, location string
+	create, remove       []time.Duration
+	failures             int
+}
+
+// bench times n create/remove cycles for each combination of server type and location and prints latency percentiles
+// to w; failed cycles are counted, but do not abort the benchmark
+func bench(w io.Writer, n int, serverTypes, locations []string, values func(serverType, location string) map[string]interface{}) error {
+	var results []*benchResult
+	for _, serverType := range serverTypes {
+		for _, location := range locations {
+			result := &benchResult{serverType: serverType, location: location}
+			for i := 0; i < n; i++ {
+				if err := result.cycle(values(serverType, location)); err != nil {
+					_, _ = fmt.Fprintf(os.Stderr, "cycle %d of %v in %v failed: %v\n", i+1, orDefault(serverType), orDefault(location), err)
+					result.failures++
+				}
+			}
+			results = append(results, result)
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprint(tw, "TYPE\tLOCATION\tOK\tFAILED")
+	for _, op := range []string{"CREATE", "REMOVE"} {
+		for _, p := range benchPercentiles {
+			_, _ = fmt.Fprintf(tw, "\t%v P%v", op, p)
+		}
+	}
+	_, _ = fmt.Fprintln(tw)
+
+	for _, result := range results {
+		_, _ = fmt.Fprintf(tw, "%v\t%v\t%d\t%d", orDefault(result.serverType), orDefault(result.location), len(result.create), result.failures)
+		for _, latencies := range [][]time.Duration{result.create, result.remove} {
+			for _, p := range benchPercentiles {
+				_, _ = fmt.Fprintf(tw, "\t%v", percentile(latencies, p))
+			}
+		}
+		_, _ = fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// cycle creates and removes a single throwaway server, recording the latencies if both succeed
+func (r *benchResult) cycle(values map[string]interface{}) error {
+	m, err := newThrowawayMachine("bench", values)
+	if err != nil {
+		return err
+	}
+	defer m.close()
+
+	if err := m.PreCreateCheck(); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	createErr := m.Create()
+	created := time.Since(start)
+
+	// always attempt removal, as a failed creation may still leave a server behind
+	start = time.Now()
+	if err := m.Remove(); err != nil {
+		return err
+	}
+	removed := time.Since(start)
+
+	if createErr != nil {
+		return createErr
+	}
+	r.create = append(r.create, created)
+	r.remove = append(r.remove, removed)
+	return nil
+}
+
+// percentile returns the p-th percentile of latencies using the nearest-rank method, or "-" if there are none
+func percentile(latencies []time.Duration, p float64) string {
+	if len(latencies) == 0 {
+		return "-"
+	}
+
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1].Round(100 * time.Millisecond).String()
+}
+
+func orDefault(value string) string {
+	if value == "" {
+		return "(default)"
+	}
+	return value
+}
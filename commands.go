@@ -0,0 +1,475 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/JonasProgrammer/docker-machine-driver-hetzner/driver"
+)
+
+// command is a standalone mode of the driver binary, invoked as `docker-machine-driver-hetzner <name> [flags]`
+type command struct {
+	usage string
+	run   func(d *driver.Driver, flags *flag.FlagSet, args []string) error
+}
+
+var commands = map[string]command{
+	"list-images": {
+		usage: "list available images",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			arch := flags.String("arch", "", "only list images of the given architecture (x86, arm)")
+			if err := parseCommandFlags(d, flags, args); err != nil {
+				return err
+			}
+			return d.ListImages(os.Stdout, *arch)
+		},
+	},
+	"list-types": {
+		usage: "list available server types and their prices",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			arch := flags.String("arch", "", "only list server types of the given architecture (x86, arm)")
+			location := flags.String("location", "", "only list server types available in, and show prices of, the given location")
+			if err := parseCommandFlags(d, flags, args); err != nil {
+				return err
+			}
+			return d.ListServerTypes(os.Stdout, *arch, *location)
+		},
+	},
+	"list-locations": {
+		usage: "list available locations",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			if err := parseCommandFlags(d, flags, args); err != nil {
+				return err
+			}
+			return d.ListLocations(os.Stdout)
+		},
+	},
+	"selftest": {
+		usage: "create, check and remove a throwaway server to validate the driver and token",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			serverType := flags.String("type", "", "server type to create; defaults to the driver default")
+			location := flags.String("location", "", "location to create the server in")
+			image := flags.String("image", "", "image to create the server from; defaults to the driver default")
+			if err := parseCommandFlags(d, flags, args); err != nil {
+				return err
+			}
+			return selftest(os.Stdout, serverValues(d, *serverType, *location, *image))
+		},
+	},
+	"bench": {
+		usage: "time create/remove cycles of throwaway servers and print latency percentiles",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			n := flags.Int("n", 5, "number of create/remove cycles per server type and location")
+			serverTypes := flags.String("types", "", "comma-separated server types to benchmark; defaults to the driver default")
+			locations := flags.String("locations", "", "comma-separated locations to benchmark; defaults to no preference")
+			image := flags.String("image", "", "image to create the servers from; defaults to the driver default")
+			if err := parseCommandFlags(d, flags, args); err != nil {
+				return err
+			}
+			if *n < 1 {
+				return errors.New("-n must be positive")
+			}
+
+			return bench(os.Stdout, *n, strings.Split(*serverTypes, ","), strings.Split(*locations, ","),
+				func(serverType, location string) map[string]interface{} {
+					return serverValues(d, serverType, location, *image)
+				})
+		},
+	},
+	"prewarm": {
+		usage: "create powered off standby servers, claimed by machines created with --hetzner-standby-pool",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			defaultStorePath, err := machineStorePath()
+			if err != nil {
+				return err
+			}
+			pool := flags.String("pool", "", "standby pool to create the servers in")
+			n := flags.Int("n", 1, "number of standby servers to create")
+			storePath := flags.String("storage-path", defaultStorePath, "docker-machine storage path; defaults to MACHINE_STORAGE_PATH or ~/.docker/machine")
+			createValues := addCreateFlags(d, flags)
+			if err = parseCommandFlags(d, flags, args); err != nil {
+				return err
+			}
+			if *pool == "" {
+				return errors.New("-pool is required")
+			}
+			if *n < 1 {
+				return errors.New("-n must be positive")
+			}
+
+			values := envCreateValues(d)
+			for name, value := range createValues() {
+				values[name] = value
+			}
+			if d.Project != "" {
+				values["hetzner-project"] = d.Project
+			} else {
+				values["hetzner-api-token"] = d.AccessToken
+			}
+			values["hetzner-standby-pool"] = *pool
+			return prewarm(os.Stdout, *storePath, *pool, *n, values)
+		},
+	},
+	"remove-by-selector": {
+		usage: "remove all servers matching a label selector, with their driver-created keys and placement groups",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			selector := flags.String("selector", "", "label selector of the servers to remove, e.g. docker-machine/controller-host=ci-1")
+			dryRun := flags.Bool("dry-run", false, "only list the servers and keys which would be removed")
+			concurrency := flags.Int("concurrency", 4, "number of servers to remove concurrently")
+			if err := parseCommandFlags(d, flags, args); err != nil {
+				return err
+			}
+			return d.RemoveBySelector(os.Stdout, *selector, *dryRun, *concurrency)
+		},
+	},
+	"inventory": {
+		usage: "print the servers labeled by the driver with their IPs, networks and labels as JSON",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			selector := flags.String("selector", "", "label selector of the servers to list; defaults to all servers created by the driver, labeled docker-machine/machine")
+			if err := parseCommandFlags(d, flags, args); err != nil {
+				return err
+			}
+			return d.Inventory(os.Stdout, *selector)
+		},
+	},
+	"remove-dry-run": {
+		usage: "print the resources removing an existing machine would delete, without deleting anything",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			if _, err := parseMachineFlags(d, flags, args); err != nil {
+				return err
+			}
+			return d.RemovePlan(os.Stdout)
+		},
+	},
+	"attach-network": {
+		usage: "attach a private network to an existing machine",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			network := flags.String("network", "", "ID or name of the network to attach")
+			ip := flags.String("ip", "", "static IP of the machine in the network; assigned automatically if empty")
+			m, err := parseMachineFlags(d, flags, args)
+			if err != nil {
+				return err
+			}
+			if err = d.AttachNetwork(*network, *ip); err != nil {
+				return err
+			}
+			return m.save(d)
+		},
+	},
+	"detach-network": {
+		usage: "detach a private network from an existing machine",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			network := flags.String("network", "", "ID or name of the network to detach")
+			m, err := parseMachineFlags(d, flags, args)
+			if err != nil {
+				return err
+			}
+			if err = d.DetachNetwork(*network); err != nil {
+				return err
+			}
+			return m.save(d)
+		},
+	},
+	"apply-firewall": {
+		usage: "apply firewalls to an existing machine",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			firewall := flags.String("firewall", "", "ID or name of the firewall to apply")
+			selector := flags.String("selector", "", "label selector of the firewalls to apply, instead of -firewall")
+			m, err := parseMachineFlags(d, flags, args)
+			if err != nil {
+				return err
+			}
+			if err = d.ApplyFirewalls(*firewall, *selector); err != nil {
+				return err
+			}
+			return m.save(d)
+		},
+	},
+	"remove-firewall": {
+		usage: "remove firewalls from an existing machine",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			firewall := flags.String("firewall", "", "ID or name of the firewall to remove")
+			selector := flags.String("selector", "", "label selector of the firewalls to remove, instead of -firewall")
+			m, err := parseMachineFlags(d, flags, args)
+			if err != nil {
+				return err
+			}
+			if err = d.RemoveFirewalls(*firewall, *selector); err != nil {
+				return err
+			}
+			return m.save(d)
+		},
+	},
+	"attach-volume": {
+		usage: "attach a volume to an existing machine",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			volume := flags.String("volume", "", "ID or name of the volume to attach")
+			automount := flags.Bool("automount", false, "mount the volume automatically")
+			m, err := parseMachineFlags(d, flags, args)
+			if err != nil {
+				return err
+			}
+			if err = d.AttachVolume(*volume, *automount); err != nil {
+				return err
+			}
+			return m.save(d)
+		},
+	},
+	"detach-volume": {
+		usage: "detach a volume from an existing machine",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			volume := flags.String("volume", "", "ID or name of the volume to detach")
+			m, err := parseMachineFlags(d, flags, args)
+			if err != nil {
+				return err
+			}
+			if err = d.DetachVolume(*volume); err != nil {
+				return err
+			}
+			return m.save(d)
+		},
+	},
+	"move-placement-group": {
+		usage: "move an existing machine into another placement group, restarting it if it is running",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			group := flags.String("group", "", "ID or name of the target placement group; empty to remove the machine from its group")
+			if _, err := parseMachineFlags(d, flags, args); err != nil {
+				return err
+			}
+			return d.MovePlacementGroup(*group)
+		},
+	},
+	"sync-labels": {
+		usage: "update the labels of an existing machine's server",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			var labels stringSlice
+			flags.Var(&labels, "label", "key=value server label, may be repeated; defaults to the stored server labels")
+			m, err := parseMachineFlags(d, flags, args)
+			if err != nil {
+				return err
+			}
+			if err = d.SyncLabels(os.Stdout, labels); err != nil {
+				return err
+			}
+			return m.save(d)
+		},
+	},
+	"recover-boot": {
+		usage: "detach an ISO and disable the rescue system of an existing machine, then restart it from its disk",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			if _, err := parseMachineFlags(d, flags, args); err != nil {
+				return err
+			}
+			return d.RecoverBoot()
+		},
+	},
+	"enable-rescue": {
+		usage: "enable the rescue system of an existing machine and reset it into it, authorizing the machine's keys",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			if _, err := parseMachineFlags(d, flags, args); err != nil {
+				return err
+			}
+			return d.EnableRescue()
+		},
+	},
+	"disable-rescue": {
+		usage: "disable the rescue system of an existing machine and reset it to boot from its disk",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			if _, err := parseMachineFlags(d, flags, args); err != nil {
+				return err
+			}
+			return d.DisableRescue()
+		},
+	},
+	"traffic": {
+		usage: "show the traffic used by an existing machine and included in its price",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			if _, err := parseMachineFlags(d, flags, args); err != nil {
+				return err
+			}
+			return d.Traffic(os.Stdout)
+		},
+	},
+	"action-history": {
+		usage: "show the recent actions targeting an existing machine's server, such as reboots and attachments",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			n := flags.Int("n", 20, "number of actions to show, at most 50")
+			if _, err := parseMachineFlags(d, flags, args); err != nil {
+				return err
+			}
+			return d.ActionHistory(os.Stdout, *n)
+		},
+	},
+	"export-flags": {
+		usage: "print the stored configuration of an existing machine as a config file for --hetzner-config-file",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			if _, err := parseMachineFlags(d, flags, args); err != nil {
+				return err
+			}
+			return d.ExportFlags(os.Stdout)
+		},
+	},
+	"import-flags": {
+		usage: "print the docker-machine create flags equivalent to a config file",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			file := flags.String("file", "", "config file to convert, e.g. written by export-flags")
+			if err := flags.Parse(args); err != nil {
+				return err
+			}
+			if *file == "" {
+				return errors.New("-file is required")
+			}
+			return d.ImportFlags(os.Stdout, *file)
+		},
+	},
+	"capabilities": {
+		usage: "print the driver version and the features it supports as JSON, without requiring a token",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			if err := flags.Parse(args); err != nil {
+				return err
+			}
+			return d.PrintCapabilities(os.Stdout)
+		},
+	},
+	"validate-flags": {
+		usage: "validate a config file and the HETZNER_* environment variables without requiring a token",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			file := flags.String("file", "", "config file to validate, see --hetzner-config-file")
+			if err := flags.Parse(args); err != nil {
+				return err
+			}
+
+			values := envCreateValues(d)
+			if *file != "" {
+				values["hetzner-config-file"] = *file
+			}
+			if err := d.ValidateFlags(newCreateOptions(d, values)); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(os.Stdout, "configuration is valid")
+			return nil
+		},
+	},
+	"resync": {
+		usage: "update the stored config of a machine to match its live server",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			dryRun := flags.Bool("dry-run", false, "only report differences, do not update the stored config")
+			m, err := parseMachineFlags(d, flags, args)
+			if err != nil {
+				return err
+			}
+
+			changed, err := d.Resync(os.Stdout)
+			if err != nil || !changed || *dryRun {
+				return err
+			}
+			return m.save(d)
+		},
+	},
+}
+
+// stringSlice is a repeatable string flag
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// parseCommandFlags parses the command line of a command, adding the flags common to all commands
+func parseCommandFlags(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+	token := flags.String("token", "", "Hetzner Cloud API token; defaults to HETZNER_API_TOKEN or HCLOUD_TOKEN")
+	project := flags.String("project", "", "alias of the project in the projects file whose token to use, instead of -token")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *project != "" {
+		d.Project = *project
+		return nil
+	}
+
+	d.AccessToken = *token
+	for _, env := range []string{"HETZNER_API_TOKEN", "HCLOUD_TOKEN"} {
+		if d.AccessToken == "" {
+			d.AccessToken = os.Getenv(env)
+		}
+	}
+	if d.AccessToken == "" {
+		return errors.New("an API token is required, pass -token or set HETZNER_API_TOKEN")
+	}
+	return nil
+}
+
+// parseMachineFlags parses the command line of a command operating on an existing machine, given as its only
+// argument, and loads the machine's driver configuration into d
+func parseMachineFlags(d *driver.Driver, flags *flag.FlagSet, args []string) (*machine, error) {
+	defaultStorePath, err := machineStorePath()
+	if err != nil {
+		return nil, err
+	}
+	storePath := flags.String("storage-path", defaultStorePath, "docker-machine storage path; defaults to MACHINE_STORAGE_PATH or ~/.docker/machine")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() != 1 {
+		return nil, fmt.Errorf("expected exactly one machine name, got %d arguments", flags.NArg())
+	}
+
+	return loadMachine(*storePath, flags.Arg(0), d)
+}
+
+// serverValues returns the create flag values for throwaway servers created by commands, omitting empty ones
+func serverValues(d *driver.Driver, serverType, location, image string) map[string]interface{} {
+	values := map[string]interface{}{"hetzner-api-token": d.AccessToken}
+	for flag, value := range map[string]string{
+		"hetzner-server-type":     serverType,
+		"hetzner-server-location": location,
+		"hetzner-image":           image,
+	} {
+		if value != "" {
+			values[flag] = value
+		}
+	}
+	return values
+}
+
+func commandUsage() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("Commands:\n")
+	for _, name := range names {
+		_, _ = fmt.Fprintf(&b, "  %-16s %v\n", name, commands[name].usage)
+	}
+	return b.String()
+}
+
+// runCommand executes the command given by args and returns the process exit code
+func runCommand(args []string) int {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		_, _ = fmt.Fprintf(os.Stderr, "unknown command %v\n%v", args[0], commandUsage())
+		return 2
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	if err := cmd.run(driver.NewDriver(version), flags, args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			_, _ = fmt.Fprintf(os.Stderr, "%v: %v\n", args[0], err)
+		}
+		return 1
+	}
+	return 0
+}
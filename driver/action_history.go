@@ -0,0 +1,83 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// maxActionHistory is the maximum number of actions returned by a single page of the API
+const maxActionHistory = 50
+
+// ActionHistory prints the most recent actions targeting the server, such as its creation, reboots and attachments,
+// newest first with their status and timestamps, to tell who or what changed the server without console access
+func (d *Driver) ActionHistory(w io.Writer, n int) error {
+	if d.Robot {
+		return errors.New("action history is not available for robot servers")
+	}
+	if n < 1 || n > maxActionHistory {
+		return fmt.Errorf("the number of actions must be between 1 and %d", maxActionHistory)
+	}
+
+	srv, err := d.getServerHandle()
+	if err != nil {
+		return err
+	}
+
+	// the server's action client lists the actions of all servers of the project, so page through them newest first,
+	// keeping those targeting this server
+	var actions []*hcloud.Action
+	opts := hcloud.ActionListOpts{
+		ListOpts: hcloud.ListOpts{Page: 1, PerPage: maxActionHistory},
+		Sort:     []string{"started:desc"},
+	}
+	for len(actions) < n {
+		page, resp, err := d.getClient().Server.Action.List(context.Background(), opts)
+		if err != nil {
+			return fmt.Errorf("could not list actions of server %v[%d]: %w", srv.Name, srv.ID, err)
+		}
+		for _, action := range page {
+			if len(actions) < n && targetsServer(action, srv.ID) {
+				actions = append(actions, action)
+			}
+		}
+		if resp.Meta.Pagination == nil || resp.Meta.Pagination.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.Meta.Pagination.NextPage
+	}
+	return writeActionHistory(w, actions)
+}
+
+// targetsServer reports whether the action affects the server of the given ID
+func targetsServer(action *hcloud.Action, id int64) bool {
+	for _, resource := range action.Resources {
+		if resource.Type == hcloud.ActionResourceTypeServer && resource.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func writeActionHistory(w io.Writer, actions []*hcloud.Action) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tCOMMAND\tSTATUS\tSTARTED\tDURATION\tERROR")
+	for _, action := range actions {
+		duration := "-"
+		if !action.Finished.IsZero() {
+			duration = action.Finished.Sub(action.Started).Round(time.Second).String()
+		}
+		errorText := ""
+		if action.ErrorCode != "" {
+			errorText = fmt.Sprintf("%v: %v", action.ErrorCode, action.ErrorMessage)
+		}
+		_, _ = fmt.Fprintf(tw, "%d\t%v\t%v\t%v\t%v\t%v\n", action.ID, action.Command, action.Status,
+			action.Started.UTC().Format(time.RFC3339), duration, errorText)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,133 @@
+package driver
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/mcnflag"
+)
+
+// flagAliases maps alternative flag names, as commonly guessed e.g. by Rancher node templates deriving field names
+// from flags, to their canonical flags
+var flagAliases = map[string]string{
+	"hetzner-additional-keys": flagAdditionalKeys,
+	"hetzner-existing-key":    flagExKeyPath,
+	"hetzner-firewall":        flagFirewalls,
+	"hetzner-key-labels":      flagKeyLabel,
+	"hetzner-location":        flagLocation,
+	"hetzner-network":         flagNetworks,
+	"hetzner-server-labels":   flagServerLabel,
+	"hetzner-type":            flagType,
+	"hetzner-volume":          flagVolumes,
+}
+
+// aliasFlags creates flags for all aliases of the given flags, with the same type but without environment variables
+func aliasFlags(flags []mcnflag.Flag) []mcnflag.Flag {
+	byName := make(map[string]mcnflag.Flag, len(flags))
+	for _, flag := range flags {
+		byName[flag.String()] = flag
+	}
+
+	aliases := make([]string, 0, len(flagAliases))
+	for alias := range flagAliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	ret := make([]mcnflag.Flag, 0, len(aliases))
+	for _, alias := range aliases {
+		usage := "Alias for --" + flagAliases[alias]
+		switch flag := byName[flagAliases[alias]].(type) {
+		case mcnflag.StringFlag:
+			ret = append(ret, mcnflag.StringFlag{Name: alias, Usage: usage, Value: flag.Value})
+		case mcnflag.StringSliceFlag:
+			ret = append(ret, mcnflag.StringSliceFlag{Name: alias, Usage: usage, Value: []string{}})
+		case mcnflag.IntFlag:
+			ret = append(ret, mcnflag.IntFlag{Name: alias, Usage: usage, Value: flag.Value})
+		case mcnflag.BoolFlag:
+			ret = append(ret, mcnflag.BoolFlag{Name: alias, Usage: usage})
+		}
+	}
+	return ret
+}
+
+// overriddenOptions provides fixed values for some flags, falling back to the wrapped options for all others
+type overriddenOptions struct {
+	drivers.DriverOptions
+	values map[string]interface{}
+}
+
+func (o *overriddenOptions) String(key string) string {
+	if value, ok := o.values[key]; ok {
+		return value.(string)
+	}
+	return o.DriverOptions.String(key)
+}
+
+func (o *overriddenOptions) StringSlice(key string) []string {
+	if value, ok := o.values[key]; ok {
+		return value.([]string)
+	}
+	return o.DriverOptions.StringSlice(key)
+}
+
+func (o *overriddenOptions) Int(key string) int {
+	if value, ok := o.values[key]; ok {
+		return value.(int)
+	}
+	return o.DriverOptions.Int(key)
+}
+
+func (o *overriddenOptions) Bool(key string) bool {
+	if value, ok := o.values[key]; ok {
+		return value.(bool)
+	}
+	return o.DriverOptions.Bool(key)
+}
+
+// flagValue retrieves the value of the given flag according to its type, and whether it differs from the default
+func flagValue(opts drivers.DriverOptions, flag mcnflag.Flag) (interface{}, bool) {
+	name := flag.String()
+	switch flag.(type) {
+	case mcnflag.StringFlag:
+		value := opts.String(name)
+		return value, value != "" && value != flag.Default()
+	case mcnflag.StringSliceFlag:
+		value := opts.StringSlice(name)
+		return value, len(value) != 0
+	case mcnflag.IntFlag:
+		value := opts.Int(name)
+		return value, value != 0 && value != flag.Default()
+	case mcnflag.BoolFlag:
+		value := opts.Bool(name)
+		return value, value
+	}
+	return nil, false
+}
+
+// withFlagAliases resolves the values of set alias flags to their canonical flags
+func (d *Driver) withFlagAliases(opts drivers.DriverOptions) (drivers.DriverOptions, error) {
+	flags := make(map[string]mcnflag.Flag)
+	for _, flag := range d.GetCreateFlags() {
+		flags[flag.String()] = flag
+	}
+
+	values := make(map[string]interface{})
+	for alias, canonical := range flagAliases {
+		value, set := flagValue(opts, flags[alias])
+		if !set {
+			continue
+		}
+
+		if current, set := flagValue(opts, flags[canonical]); set && !reflect.DeepEqual(current, value) {
+			return nil, d.flagFailure("--%v and its alias --%v are mutually exclusive", canonical, alias)
+		}
+		values[canonical] = value
+	}
+
+	if len(values) == 0 {
+		return opts, nil
+	}
+	return &overriddenOptions{DriverOptions: opts, values: values}, nil
+}
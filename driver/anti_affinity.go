@@ -0,0 +1,120 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	labelAntiAffinity        = "anti-affinity"
+	antiAffinityPgName       = "__anti_affinity"
+	antiAffinityPgNameFormat = "Docker-Machine anti-affinity %v=%v #%d"
+	spreadGroupMaxServers    = 10
+)
+
+// setAntiAffinityFlag parses the anti-affinity label, which is also assigned to the server so later machines avoid it
+func (d *Driver) setAntiAffinityFlag(label string) error {
+	d.antiAffinityKey, d.antiAffinityValue = "", ""
+	if label == "" {
+		return nil
+	}
+
+	key, value, ok := strings.Cut(label, "=")
+	if !ok || key == "" {
+		return d.flagFailure("--%v must be a key=value pair: %v", flagAntiAffinity, label)
+	}
+	if existing, set := d.ServerLabels[key]; set && existing != value {
+		return d.flagFailure("--%v %v conflicts with server label %v=%v", flagAntiAffinity, label, key, existing)
+	}
+
+	d.antiAffinityKey, d.antiAffinityValue = key, value
+	d.ServerLabels[key] = value
+	if d.placementGroup == "" {
+		d.placementGroup = antiAffinityPgName
+	}
+	return nil
+}
+
+func (d *Driver) antiAffinitySelector() string {
+	return fmt.Sprintf("%v=%v", d.antiAffinityKey, d.antiAffinityValue)
+}
+
+// applyAntiAffinity restricts the candidate locations to those not hosting a server with the anti-affinity label yet;
+// if all of them do, or a single location was requested, the server is created anyway
+func (d *Driver) applyAntiAffinity() error {
+	if d.antiAffinityKey == "" {
+		return nil
+	}
+
+	servers, err := d.getClient().Server.AllWithOpts(context.Background(), hcloud.ServerListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: d.antiAffinitySelector()},
+	})
+	if err != nil {
+		return fmt.Errorf("could not list servers labeled %v: %w", d.antiAffinitySelector(), err)
+	}
+
+	occupied := make(map[string]bool)
+	for _, srv := range servers {
+		if srv.Datacenter != nil && srv.Datacenter.Location != nil {
+			occupied[srv.Datacenter.Location.Name] = true
+		}
+	}
+
+	if len(d.locationCandidates) == 0 {
+		if d.Location != "" && occupied[d.Location] {
+			log.Warnf("location %v already hosts a server labeled %v", d.Location, d.antiAffinitySelector())
+		}
+		return nil
+	}
+
+	var free []string
+	for _, candidate := range d.locationCandidates {
+		if !occupied[candidate] {
+			free = append(free, candidate)
+		}
+	}
+
+	if len(free) == 0 {
+		log.Warnf("all locations already host a server labeled %v", d.antiAffinitySelector())
+		return nil
+	}
+	log.Infof(" -> Locations without server labeled %v: %v", d.antiAffinitySelector(), free)
+	d.locationCandidates = free
+	return nil
+}
+
+// getAntiAffinityPlacementGroup returns a spread placement group dedicated to the anti-affinity label, preferring one
+// not hosting a labeled server yet; further groups are created once all existing ones are full
+func (d *Driver) getAntiAffinityPlacementGroup() (*hcloud.PlacementGroup, error) {
+	groups, err := d.getClient().PlacementGroup.AllWithOpts(context.Background(), hcloud.PlacementGroupListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: fmt.Sprintf("%v,%v", d.labelName(labelAntiAffinity), d.antiAffinitySelector())},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list placement groups: %w", err)
+	}
+
+	var best *hcloud.PlacementGroup
+	for _, grp := range groups {
+		if len(grp.Servers) >= spreadGroupMaxServers {
+			continue
+		}
+		if best == nil || len(grp.Servers) < len(best.Servers) {
+			best = grp
+		}
+	}
+
+	if best != nil {
+		log.Infof(" -> Using anti-affinity placement group %v[%d]", best.Name, best.ID)
+		return instrumented(best), nil
+	}
+
+	return d.makePlacementGroup(fmt.Sprintf(antiAffinityPgNameFormat, d.antiAffinityKey, d.antiAffinityValue, len(groups)+1), map[string]string{
+		d.labelName(labelAntiAffinity): "true",
+		d.labelName(labelAutoCreated):  "true",
+		d.antiAffinityKey:              d.antiAffinityValue,
+	})
+}
@@ -0,0 +1,85 @@
+package driver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// setAPITLSFlags validates the CA bundle and minimum TLS version used for the API, e.g. behind a TLS-intercepting proxy
+func (d *Driver) setAPITLSFlags(caBundle, minVersion string) error {
+	d.APICABundle, d.APITLSMinVersion, d.cachedTLSConfig, d.cachedTransport = caBundle, minVersion, nil, nil
+	if minVersion != "" {
+		if _, ok := tlsVersions[minVersion]; !ok {
+			return d.flagFailure("--%v must be 1.2 or 1.3, got: %v", flagAPITLSMinVersion, minVersion)
+		}
+	}
+	if caBundle != "" {
+		if _, err := loadCABundle(caBundle); err != nil {
+			return d.flagFailure("--%v: %v", flagAPICABundle, err)
+		}
+	}
+	return nil
+}
+
+// loadCABundle returns the system's certificate pool extended by the PEM encoded certificates of the file
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM encoded certificates found in %v", path)
+	}
+	return pool, nil
+}
+
+// apiTLSConfig returns the TLS configuration for the API, or nil to use the default one
+func (d *Driver) apiTLSConfig() *tls.Config {
+	if d.APICABundle == "" && d.APITLSMinVersion == "" {
+		return nil
+	}
+	if d.cachedTLSConfig != nil {
+		return d.cachedTLSConfig
+	}
+
+	config := &tls.Config{MinVersion: tlsVersions[d.APITLSMinVersion]}
+	if d.APICABundle != "" {
+		pool, err := loadCABundle(d.APICABundle)
+		if err != nil {
+			log.Errorf("not using CA bundle: %v", err)
+		} else {
+			config.RootCAs = pool
+		}
+	}
+	d.cachedTLSConfig = config
+	return config
+}
+
+// setupClientTLS applies --hetzner-api-ca-bundle and --hetzner-api-tls-min-version to the API client; the transport is
+// shared by all clients of the driver, so their connections are reused
+func (d *Driver) setupClientTLS(httpClient *http.Client) {
+	if d.cachedTransport == nil {
+		config := d.apiTLSConfig()
+		if config == nil {
+			return
+		}
+		d.cachedTransport = http.DefaultTransport.(*http.Transport).Clone()
+		d.cachedTransport.TLSClientConfig = config
+	}
+	httpClient.Transport = d.cachedTransport
+}
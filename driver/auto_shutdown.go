@@ -0,0 +1,47 @@
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const autoShutdownCronFile = "/etc/cron.d/docker-machine-auto-shutdown"
+
+var (
+	cronField  = regexp.MustCompile(`^[0-9A-Za-z*/,-]+$`)
+	cronMacros = map[string]bool{
+		"@yearly": true, "@annually": true, "@monthly": true, "@weekly": true, "@daily": true, "@midnight": true, "@hourly": true,
+	}
+)
+
+// setAutoShutdownFlag validates the cron expression of --hetzner-auto-shutdown-cron, being either five schedule fields
+// or one of the common macros
+func (d *Driver) setAutoShutdownFlag(expr string) error {
+	d.autoShutdownCron = strings.Join(strings.Fields(expr), " ")
+	if d.autoShutdownCron == "" || cronMacros[d.autoShutdownCron] {
+		return nil
+	}
+
+	fields := strings.Fields(d.autoShutdownCron)
+	if len(fields) != 5 {
+		return d.flagFailure("--%v must have 5 fields or be a macro like @daily, got: %v", flagAutoShutdownCron, expr)
+	}
+	for _, field := range fields {
+		if !cronField.MatchString(field) {
+			return d.flagFailure("--%v has invalid field %q", flagAutoShutdownCron, field)
+		}
+	}
+	return nil
+}
+
+// autoShutdownCloudInitFile returns the cron file powering off the server on the --hetzner-auto-shutdown-cron schedule
+func (d *Driver) autoShutdownCloudInitFile() cloudInitFile {
+	return cloudInitFile{
+		Path:        autoShutdownCronFile,
+		Permissions: "0644",
+		Content: fmt.Sprintf("# created by docker-machine-driver-hetzner for machine %v\n"+
+			"PATH=/usr/local/sbin:/usr/local/bin:/sbin:/bin:/usr/sbin:/usr/bin\n"+
+			"%v root shutdown -h now\n", d.GetMachineName(), d.autoShutdownCron),
+	}
+}
@@ -0,0 +1,27 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// enableBackups enables the automatic backups of the server if --hetzner-enable-backups is given, which Hetzner then
+// takes daily and charges for as a percentage of the server price
+func (d *Driver) enableBackups(srv *hcloud.Server) error {
+	if !d.EnableBackup {
+		return nil
+	}
+
+	log.Infof(" -> Enabling backups of server %s[%d] ...", srv.Name, srv.ID)
+	act, _, err := d.getClient().Server.EnableBackup(context.Background(), srv, "")
+	if err != nil {
+		return fmt.Errorf("could not enable backups: %w", err)
+	}
+	if err = d.waitForAction(act); err != nil {
+		return fmt.Errorf("could not wait for backups to be enabled: %w", err)
+	}
+	return nil
+}
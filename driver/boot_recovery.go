@@ -0,0 +1,82 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// RecoverBoot restores booting from the server's disk after rescue or installation workflows, by detaching an attached
+// ISO and disabling the rescue system, then resetting the server, or powering it on if it is off
+func (d *Driver) RecoverBoot() (err error) {
+	defer d.operation("RecoverBoot")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.Robot {
+		return errors.New("boot recovery is not supported for robot servers")
+	}
+
+	srv, err := d.getServerHandle()
+	if err != nil {
+		return fmt.Errorf("could not get server handle: %w", err)
+	}
+
+	if !hasAlternativeBoot(srv) {
+		log.Infof("Server %v[%d] already boots from its disk", srv.Name, srv.ID)
+		return nil
+	}
+
+	if srv.ISO != nil {
+		log.Infof("Detaching ISO %v from server %v[%d] ...", srv.ISO.Name, srv.Name, srv.ID)
+		act, _, err := d.getClient().Server.DetachISO(context.Background(), srv)
+		if err != nil {
+			return fmt.Errorf("could not detach ISO: %w", err)
+		}
+		if err = d.waitForAction(act); err != nil {
+			return fmt.Errorf("could not wait for ISO detachment: %w", err)
+		}
+	}
+
+	if srv.RescueEnabled {
+		log.Infof("Disabling rescue system of server %v[%d] ...", srv.Name, srv.ID)
+		act, _, err := d.getClient().Server.DisableRescue(context.Background(), srv)
+		if err != nil {
+			return fmt.Errorf("could not disable rescue system: %w", err)
+		}
+		if err = d.waitForAction(act); err != nil {
+			return fmt.Errorf("could not wait for rescue system to be disabled: %w", err)
+		}
+	}
+
+	return d.restartServer(srv)
+}
+
+// restartServer resets the server so it boots into the system configured, or powers it on if it is off
+func (d *Driver) restartServer(srv *hcloud.Server) error {
+	var act *hcloud.Action
+	var err error
+	if srv.Status == hcloud.ServerStatusOff {
+		act, _, err = d.getClient().Server.Poweron(context.Background(), srv)
+	} else {
+		// the rescue or installation system may not handle ACPI reboots, so reset the server instead
+		act, _, err = d.getClient().Server.Reset(context.Background(), srv)
+	}
+	if err != nil {
+		return fmt.Errorf("could not restart server: %w", err)
+	}
+	log.Infof(" -> Restarting server %s[%d] in %s[%d]...", srv.Name, srv.ID, act.Command, act.ID)
+	return d.waitForAction(act)
+}
+
+// hasAlternativeBoot reports whether the server boots from something other than its disk on its next start
+func hasAlternativeBoot(srv *hcloud.Server) bool {
+	return srv.ISO != nil || srv.RescueEnabled
+}
@@ -0,0 +1,52 @@
+package driver
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// capabilitiesSchemaVersion is the version of the format of [Capabilities], increased on incompatible changes
+const capabilitiesSchemaVersion = 1
+
+// Capabilities describes the features of the driver, so orchestrators managing fleets with mixed driver versions can
+// enable them conditionally; features of later versions are added to Features rather than as new fields
+type Capabilities struct {
+	SchemaVersion  int      `json:"schema-version"`
+	DriverVersion  string   `json:"driver-version"`
+	SupportsAdopt  bool     `json:"supports-adopt"`
+	SupportsResize bool     `json:"supports-resize"`
+	SupportsRobot  bool     `json:"supports-robot"`
+	Features       []string `json:"features"`
+}
+
+// features lists the optional features of the driver by stable names, sorted
+var features = []string{
+	"auto-load-balancer",
+	"floating-ip",
+	"instance-group",
+	"inventory",
+	"protection",
+	"recover-boot",
+	"remove-plan",
+	"rescue",
+	"robot",
+	"standby-pool",
+}
+
+// Capabilities returns the features supported by this version of the driver; adopting existing servers and resizing
+// machines are not supported
+func (d *Driver) Capabilities() Capabilities {
+	return Capabilities{
+		SchemaVersion: capabilitiesSchemaVersion,
+		DriverVersion: d.version,
+		SupportsRobot: true,
+		Features:      append([]string{}, features...),
+	}
+}
+
+// PrintCapabilities prints the capabilities of the driver as JSON
+func (d *Driver) PrintCapabilities(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d.Capabilities())
+}
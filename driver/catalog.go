@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// ListImages prints the available system and app images, optionally restricted to the given architecture, for
+// discovering valid --hetzner-image values
+func (d *Driver) ListImages(w io.Writer, arch string) error {
+	opts := hcloud.ImageListOpts{
+		Type:              []hcloud.ImageType{hcloud.ImageTypeSystem, hcloud.ImageTypeApp},
+		IncludeDeprecated: true,
+	}
+	if arch != "" {
+		opts.Architecture = []hcloud.Architecture{hcloud.Architecture(arch)}
+	}
+
+	images, err := d.getClient().Image.AllWithOpts(context.Background(), opts)
+	if err != nil {
+		return fmt.Errorf("could not list images: %w", err)
+	}
+	sort.SliceStable(images, func(i, j int) bool {
+		if images[i].Name != images[j].Name {
+			return images[i].Name < images[j].Name
+		}
+		return images[i].Architecture < images[j].Architecture
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tNAME\tARCH\tTYPE\tDESCRIPTION\tDEPRECATED")
+	for _, image := range images {
+		deprecated := ""
+		if image.IsDeprecated() {
+			deprecated = image.Deprecated.Format("2006-01-02")
+		}
+		_, _ = fmt.Fprintf(tw, "%d\t%v\t%v\t%v\t%v\t%v\n",
+			image.ID, image.Name, image.Architecture, image.Type, image.Description, deprecated)
+	}
+	return tw.Flush()
+}
+
+// ListServerTypes prints the available server types, optionally restricted to the given architecture, along with their
+// monthly gross price in the given location or the lowest price across all locations, for discovering valid
+// --hetzner-server-type values
+func (d *Driver) ListServerTypes(w io.Writer, arch, location string) error {
+	types, err := d.getClient().ServerType.All(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not list server types: %w", err)
+	}
+
+	priceHeader := "MONTHLY (FROM)"
+	if location != "" {
+		priceHeader = fmt.Sprintf("MONTHLY (%v)", location)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "NAME\tARCH\tCORES\tCPU\tMEMORY\tDISK\t%v\tDEPRECATED\n", priceHeader)
+	for _, stype := range types {
+		if arch != "" && string(stype.Architecture) != arch {
+			continue
+		}
+
+		price := monthlyPrice(stype, location)
+		if price == "" && location != "" {
+			continue // not available in the requested location
+		}
+
+		deprecated := ""
+		if stype.IsDeprecated() {
+			deprecated = stype.UnavailableAfter().Format("2006-01-02")
+		}
+		_, _ = fmt.Fprintf(tw, "%v\t%v\t%d\t%v\t%v GB\t%d GB\t%v\t%v\n",
+			stype.Name, stype.Architecture, stype.Cores, stype.CPUType, stype.Memory, stype.Disk, price, deprecated)
+	}
+	return tw.Flush()
+}
+
+// monthlyPrice returns the formatted monthly gross price of a server type in the given location, or the lowest one if
+// location is empty
+func monthlyPrice(stype *hcloud.ServerType, location string) string {
+	best := -1.0
+	for _, pricing := range stype.Pricings {
+		if location != "" && (pricing.Location == nil || pricing.Location.Name != location) {
+			continue
+		}
+
+		gross, err := strconv.ParseFloat(pricing.Monthly.Gross, 64)
+		if err != nil {
+			continue
+		}
+		if best < 0 || gross < best {
+			best = gross
+		}
+	}
+
+	if best < 0 {
+		return ""
+	}
+	return strconv.FormatFloat(best, 'f', 2, 64)
+}
+
+// ListLocations prints the available locations, for discovering valid --hetzner-server-location values
+func (d *Driver) ListLocations(w io.Writer) error {
+	locations, err := d.getClient().Location.All(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not list locations: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tCITY\tCOUNTRY\tNETWORK ZONE\tDESCRIPTION")
+	for _, location := range locations {
+		_, _ = fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n",
+			location.Name, location.City, location.Country, location.NetworkZone, location.Description)
+	}
+	return tw.Flush()
+}
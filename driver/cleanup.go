@@ -3,38 +3,129 @@ package driver
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/docker/machine/libmachine/log"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
 
-func (d *Driver) destroyDangling() {
-	for _, destructor := range d.dangling {
-		destructor()
+// danglingResource is a resource created during [Driver.Create], which is destroyed again should creation fail
+type danglingResource struct {
+	kind    string
+	destroy func() error
+}
+
+// LeakedResourcesError is returned by [Driver.Create], joined with the error it failed with, if resources created for
+// the machine could not be destroyed again and need to be cleaned up manually
+type LeakedResourcesError struct {
+	Resources []LeakedResource
+}
+
+// LeakedResource describes a resource which could not be destroyed after a failed creation
+type LeakedResource struct {
+	Kind string
+	Err  error
+}
+
+func (e *LeakedResourcesError) Error() string {
+	resources := make([]string, 0, len(e.Resources))
+	for _, resource := range e.Resources {
+		resources = append(resources, fmt.Sprintf("%v: %v", resource.Kind, resource.Err))
+	}
+	return fmt.Sprintf("%d resource(s) created for the machine were left behind: %v", len(e.Resources), strings.Join(resources, "; "))
+}
+
+func (e *LeakedResourcesError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Resources))
+	for _, resource := range e.Resources {
+		errs = append(errs, resource.Err)
+	}
+	return errs
+}
+
+// trackDangling registers a resource of the given kind for destruction should creation fail
+func (d *Driver) trackDangling(kind string, destroy func() error) {
+	d.dangling = append(d.dangling, danglingResource{kind: kind, destroy: destroy})
+}
+
+// destroyDangling destroys the resources created so far by a failed creation and logs the outcome per kind of
+// resource; resources which could not be destroyed are returned as *LeakedResourcesError
+func (d *Driver) destroyDangling() error {
+	defer d.cleanup()()
+	if len(d.dangling) == 0 {
+		return nil
+	}
+
+	destroyed := make(map[string]int)
+	leaked := make(map[string]int)
+	var failures []LeakedResource
+	for _, resource := range d.dangling {
+		if err := resource.destroy(); err != nil {
+			log.Errorf("could not delete %v: %v", resource.kind, err)
+			failures = append(failures, LeakedResource{Kind: resource.kind, Err: err})
+			leaked[resource.kind]++
+		} else {
+			destroyed[resource.kind]++
+		}
+	}
+	d.recordDanglingCleanup(destroyed, leaked)
+
+	var summary []string
+	for kind, count := range destroyed {
+		summary = append(summary, fmt.Sprintf("%d %v(s) deleted", count, kind))
+	}
+	for kind, count := range leaked {
+		summary = append(summary, fmt.Sprintf("%d %v(s) left behind", count, kind))
+	}
+	sort.Strings(summary)
+
+	if len(failures) == 0 {
+		log.Infof(" -> Cleaned up after failed creation: %v", strings.Join(summary, ", "))
+		return nil
 	}
+	log.Warnf("Cleanup after failed creation incomplete, manual cleanup required: %v", strings.Join(summary, ", "))
+	return &LeakedResourcesError{Resources: failures}
 }
 
 func (d *Driver) removeEmptyServerPlacementGroup(srv *hcloud.Server) error {
 	pg := srv.PlacementGroup
-	if pg == nil {
+	if !d.isRemovablePlacementGroup(pg) {
 		return nil
 	}
 
+	err := d.retry("placement group deletion", func() error {
+		_, err := d.getClient().PlacementGroup.Delete(context.Background(), pg)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not remove placement group: %w", err)
+	}
+	return nil
+}
+
+// isRemovablePlacementGroup reports whether the placement group of a server is removed along with it, which is the case
+// for groups the driver created that contain no other servers
+func (d *Driver) isRemovablePlacementGroup(pg *hcloud.PlacementGroup) bool {
+	if pg == nil {
+		return false
+	}
+
 	if len(pg.Servers) > 1 {
 		log.Debugf("more than 1 servers in group, ignoring %v", pg)
-		return nil
+		return false
 	}
 
-	if auto, exists := pg.Labels[d.labelName(labelAutoCreated)]; exists && auto == "true" {
-		_, err := d.getClient().PlacementGroup.Delete(context.Background(), pg)
-		if err != nil {
-			return fmt.Errorf("could not remove placement group: %w", err)
-		}
-		return nil
-	} else {
+	if auto, exists := pg.Labels[d.labelName(labelAutoCreated)]; !exists || auto != "true" {
 		log.Debugf("group not auto-created, ignoring: %v", pg)
-		return nil
+		return false
 	}
+	return true
+}
+
+// ownsKey reports whether the SSH key of the machine was created for it, and is therefore removed along with it
+func (d *Driver) ownsKey() bool {
+	return !d.IsExistingKey && !d.KeyReused && d.KeyID != 0
 }
 
 func (d *Driver) destroyServer() error {
@@ -52,20 +143,26 @@ func (d *Driver) destroyServer() error {
 	} else {
 		log.Infof(" -> Destroying server %s[%d] in...", srv.Name, srv.ID)
 
-		res, _, err := d.getClient().Server.DeleteWithResult(context.Background(), srv)
+		err = d.retry("server deletion", func() error {
+			res, _, err := d.getClient().Server.DeleteWithResult(context.Background(), srv)
+			if err != nil {
+				return fmt.Errorf("could not delete server: %w", err)
+			}
+
+			// wait for the server to actually be deleted
+			if err = d.waitForAction(res.Action); err != nil {
+				return fmt.Errorf("could not wait for deletion: %w", err)
+			}
+			return nil
+		})
 		if err != nil {
-			return fmt.Errorf("could not delete server: %w", err)
+			return err
 		}
 
 		// failure to remove a placement group is not a hard error
 		if softErr := d.removeEmptyServerPlacementGroup(srv); softErr != nil {
 			log.Error(softErr)
 		}
-
-		// wait for the server to actually be deleted
-		if err = d.waitForAction(res.Action); err != nil {
-			return fmt.Errorf("could not wait for deletion: %w", err)
-		}
 	}
 
 	return nil
@@ -0,0 +1,210 @@
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"gopkg.in/yaml.v3"
+)
+
+// labelCloudInit marks images which do not run cloud-init with the value false
+const labelCloudInit = "cloud-init"
+
+// cloudConfigMergeHow makes cloud-init append the generated cloud-config to the user's one, e.g. its write_files,
+// rather than replacing them
+const cloudConfigMergeHow = "dict(recurse_array,no_replace)+list(append)"
+
+// cloudConfig is the cloud-config generated by the driver from its flags
+type cloudConfig struct {
+	MergeHow   string              `yaml:"merge_how,omitempty"`
+	WriteFiles []cloudInitFile     `yaml:"write_files,omitempty"`
+	Swap       *cloudInitSwap      `yaml:"swap,omitempty"`
+	PhoneHome  *cloudInitPhoneHome `yaml:"phone_home,omitempty"`
+	Apt        *cloudInitApt       `yaml:"apt,omitempty"`
+}
+
+type cloudInitFile struct {
+	Path        string `yaml:"path"`
+	Permissions string `yaml:"permissions"`
+	Content     string `yaml:"content"`
+	Append      bool   `yaml:"append,omitempty"`
+}
+
+type cloudInitSwap struct {
+	Filename string `yaml:"filename"`
+	Size     string `yaml:"size"`
+	MaxSize  string `yaml:"maxsize"`
+}
+
+type cloudInitApt struct {
+	Primary  []cloudInitAptMirror `yaml:"primary,omitempty"`
+	Security []cloudInitAptMirror `yaml:"security,omitempty"`
+}
+
+type cloudInitAptMirror struct {
+	Arches []string `yaml:"arches"`
+	URI    string   `yaml:"uri"`
+}
+
+type cloudInitPhoneHome struct {
+	URL   string   `yaml:"url"`
+	Post  []string `yaml:"post"`
+	Tries int      `yaml:"tries"`
+}
+
+func (c cloudConfig) empty() bool {
+	return len(c.WriteFiles) == 0 && c.Swap == nil && c.PhoneHome == nil && c.Apt == nil
+}
+
+func (c cloudConfig) marshal() (string, error) {
+	raw, err := yaml.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("could not serialize generated cloud-config: %w", err)
+	}
+	return "#cloud-config\n" + string(raw), nil
+}
+
+// generateCloudConfig collects the cloud-config required by the flags
+func (d *Driver) generateCloudConfig() (cloudConfig, error) {
+	var config cloudConfig
+
+	if d.metadataFile != "" {
+		file, err := d.metadataCloudInitFile()
+		if err != nil {
+			return config, err
+		}
+		config.WriteFiles = append(config.WriteFiles, file)
+	}
+	if d.autoShutdownCron != "" {
+		config.WriteFiles = append(config.WriteFiles, d.autoShutdownCloudInitFile())
+	}
+	if d.swapSize != "" {
+		config.Swap = d.swapCloudConfig()
+	}
+	if d.phoneHomeURL != "" {
+		config.PhoneHome = d.phoneHomeCloudConfig()
+	}
+	if d.PackageMirror != "" {
+		if err := d.packageMirrorCloudConfig(&config); err != nil {
+			return config, err
+		}
+	}
+
+	return config, nil
+}
+
+// userDataPart is a part of the user data, merged into the preceding parts as given by the cloud-init merge classes of
+// mergeHow, if set
+type userDataPart struct {
+	contentType string
+	content     string
+	mergeHow    string
+}
+
+// withGeneratedCloudConfig adds the cloud-config generated from the flags to the given user data, as a separate part of
+// a MIME multipart archive if there is any
+func (d *Driver) withGeneratedCloudConfig(userData string) (string, error) {
+	var parts []userDataPart
+	if userData != "" {
+		parts = append(parts, userDataPart{contentType: "text/plain", content: userData})
+	}
+	return d.assembleUserData(parts)
+}
+
+// assembleUserData adds the cloud-config generated from the flags to the user data parts and combines them into a MIME
+// multipart archive if there is more than one part
+func (d *Driver) assembleUserData(parts []userDataPart) (string, error) {
+	config, err := d.generateCloudConfig()
+	if err != nil {
+		return "", err
+	}
+	if !config.empty() {
+		if len(parts) > 0 {
+			config.MergeHow = cloudConfigMergeHow
+		}
+		generated, err := config.marshal()
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, userDataPart{contentType: "text/cloud-config", content: generated})
+	}
+
+	switch len(parts) {
+	case 0:
+		return "", nil
+	case 1:
+		return parts[0].content, nil
+	}
+	if err = checkMultipartCombination(parts); err != nil {
+		return "", err
+	}
+	return multipartUserData(parts)
+}
+
+// multipartUserData combines the parts into a MIME multipart archive, passing their merge classes as Merge-Type header
+func multipartUserData(parts []userDataPart) (string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_, _ = fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=\"%v\"\nMIME-Version: 1.0\n\n", mw.Boundary())
+	for _, part := range parts {
+		// for text/plain, cloud-init determines the actual type from the content
+		header := textproto.MIMEHeader{"Content-Type": {part.contentType + "; charset=\"utf-8\""}}
+		if part.mergeHow != "" {
+			header.Set("Merge-Type", part.mergeHow)
+		}
+		w, err := mw.CreatePart(header)
+		if err != nil {
+			return "", fmt.Errorf("could not create user data part: %w", err)
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return "", fmt.Errorf("could not write user data part: %w", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return "", fmt.Errorf("could not finish user data: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// verifyCloudInitSupport warns, or fails with --hetzner-strict-user-data, if cloud-config would be passed to an image
+// labeled as not running cloud-init, which would silently ignore it
+func (d *Driver) verifyCloudInitSupport(image *hcloud.Image) error {
+	if image == nil || image.Labels[d.labelName(labelCloudInit)] != "false" {
+		return nil
+	}
+
+	parts, err := d.userDataParts()
+	if err != nil {
+		return err
+	}
+	config, err := d.generateCloudConfig()
+	if err != nil {
+		return err
+	}
+	hasCloudConfig := !config.empty()
+	for _, part := range parts {
+		hasCloudConfig = hasCloudConfig || strings.HasPrefix(strings.TrimSpace(part.content), "#cloud-config")
+	}
+	if !hasCloudConfig {
+		return nil
+	}
+
+	msg := fmt.Sprintf("image %v is labeled %v=false, so the cloud-config passed to it will be ignored",
+		image.Name, d.labelName(labelCloudInit))
+	if d.strictUserData {
+		return errors.New(msg)
+	}
+	log.Warn(msg)
+	return nil
+}
+
+func isMultipartUserData(userData string) bool {
+	lower := strings.ToLower(userData)
+	return strings.HasPrefix(lower, "content-type:") || strings.HasPrefix(lower, "mime-version:")
+}
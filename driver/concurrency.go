@@ -0,0 +1,105 @@
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	requestSlotsDir      = "docker-machine-driver-hetzner"
+	requestSlotRetryBase = 50 * time.Millisecond
+)
+
+// requestSlots limits the number of concurrent API requests across all driver processes on this host using the same
+// token, by holding a lock on one of a fixed number of slot files per request
+type requestSlots struct {
+	dir   string
+	count int
+}
+
+func newRequestSlots(token string, count int) (*requestSlots, error) {
+	hash := sha256.Sum256([]byte(token))
+	dir := filepath.Join(os.TempDir(), requestSlotsDir, "slots-"+hex.EncodeToString(hash[:8]))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create request slot directory: %w", err)
+	}
+	return &requestSlots{dir: dir, count: count}, nil
+}
+
+// acquire blocks until a slot is free or the request is cancelled, returning the locked slot file
+func (s *requestSlots) acquire(req *http.Request) (*os.File, error) {
+	start := rand.Intn(s.count) // avoid all processes contending for the first slot
+	for {
+		for i := 0; i < s.count; i++ {
+			path := filepath.Join(s.dir, fmt.Sprintf("slot-%d", (start+i)%s.count))
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+			if err != nil {
+				return nil, fmt.Errorf("could not open request slot: %w", err)
+			}
+
+			locked, err := tryLockFile(f)
+			if locked {
+				return f, nil
+			}
+			_ = f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("could not lock request slot: %w", err)
+			}
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(requestSlotRetryBase + time.Duration(rand.Int63n(int64(requestSlotRetryBase)))):
+		}
+	}
+}
+
+func (s *requestSlots) release(f *os.File) {
+	if err := unlockFile(f); err != nil {
+		log.Debugf("could not unlock request slot: %v", err)
+	}
+	_ = f.Close()
+}
+
+// concurrencyTransport holds a request slot for the duration of each API request
+type concurrencyTransport struct {
+	slots *requestSlots
+	next  http.RoundTripper
+}
+
+func (t concurrencyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	slot, err := t.slots.acquire(req)
+	if err != nil {
+		return nil, err
+	}
+	defer t.slots.release(slot)
+
+	return t.next.RoundTrip(req)
+}
+
+func (d *Driver) setupClientConcurrencyLimit(httpClient *http.Client) {
+	if d.MaxConcurrentRequests <= 0 {
+		return
+	}
+
+	slots, err := newRequestSlots(d.apiToken(), d.MaxConcurrentRequests)
+	if err != nil {
+		log.Warnf("not limiting concurrent requests: %v", err)
+		return
+	}
+
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	httpClient.Transport = concurrencyTransport{slots: slots, next: next}
+}
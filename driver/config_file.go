@@ -0,0 +1,248 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	flagPrefix   = "hetzner-"
+	profilesDir  = "docker-machine-driver-hetzner"
+	profilesFile = "profiles.yaml"
+)
+
+// configFileOptions provides flag values from a config file or profile for all flags not explicitly set on the command
+// line
+type configFileOptions struct {
+	drivers.DriverOptions
+	defaults map[string]interface{}
+	values   map[string]interface{}
+}
+
+// withConfigFile wraps the given options with the values read from --hetzner-config-file and the profile selected by
+// --hetzner-profile, if set; config file values take precedence over profile values
+func (d *Driver) withConfigFile(opts drivers.DriverOptions) (drivers.DriverOptions, error) {
+	values := make(map[string]interface{})
+	if path := opts.String(flagConfigFile); path != "" {
+		raw, err := readConfigFile(path)
+		if err != nil {
+			return nil, d.flagFailure("could not read config file %v: %v", path, err)
+		}
+
+		values, err = d.configFileValues(raw, "config file "+path, flagConfigFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	profile := opts.String(flagProfile)
+	if profile == "" {
+		profile, _ = values[flagProfile].(string)
+	}
+	if profile != "" {
+		profileValues, err := d.profileValues(opts.String(flagProfilesFile), profile)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range profileValues {
+			if _, ok := values[key]; !ok {
+				values[key] = value
+			}
+		}
+	}
+
+	if len(values) == 0 {
+		return opts, nil
+	}
+
+	defaults := make(map[string]interface{})
+	for _, flag := range d.GetCreateFlags() {
+		defaults[flag.String()] = flag.Default()
+	}
+	return &configFileOptions{DriverOptions: opts, defaults: defaults, values: values}, nil
+}
+
+func readConfigFile(path string) (map[string]interface{}, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err = yaml.Unmarshal(buf, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// configFileValues maps the keys of a decoded config file or profile to flag names, converting values to the expected
+// types; excluded flags may not be set from the given source
+func (d *Driver) configFileValues(raw map[string]interface{}, source string, excluded ...string) (map[string]interface{}, error) {
+	flags := make(map[string]mcnflag.Flag)
+	for _, flag := range d.GetCreateFlags() {
+		flags[flag.String()] = flag
+	}
+	for _, name := range excluded {
+		delete(flags, name)
+	}
+
+	values := make(map[string]interface{}, len(raw))
+	for key, value := range raw {
+		name := key
+		if !strings.HasPrefix(name, flagPrefix) {
+			name = flagPrefix + name
+		}
+		if canonical, ok := flagAliases[name]; ok {
+			name = canonical
+		}
+
+		flag, ok := flags[name]
+		if !ok {
+			return nil, d.flagFailure("unknown key '%v' in %v", key, source)
+		}
+
+		var err error
+		values[name], err = configFileValue(flag, value)
+		if err != nil {
+			return nil, d.flagFailure("invalid value for '%v' in %v: %v", key, source, err)
+		}
+	}
+	return values, nil
+}
+
+// profileValues reads the given profile from the profiles file, defaulting to profiles.yaml in the user's config
+// directory
+func (d *Driver) profileValues(path, profile string) (map[string]interface{}, error) {
+	if path == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("could not determine profiles file location: %w", err)
+		}
+		path = filepath.Join(dir, profilesDir, profilesFile)
+	}
+
+	raw, err := readConfigFile(path)
+	if err != nil {
+		return nil, d.flagFailure("could not read profiles file %v: %v", path, err)
+	}
+
+	entry, ok := raw[profile]
+	if !ok {
+		names := make([]string, 0, len(raw))
+		for name := range raw {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, d.flagFailure("profile '%v' not found in %v, available profiles: %v", profile, path, strings.Join(names, ", "))
+	}
+
+	settings, ok := entry.(map[string]interface{})
+	if !ok {
+		return nil, d.flagFailure("profile '%v' in %v is not a mapping", profile, path)
+	}
+
+	return d.configFileValues(settings, fmt.Sprintf("profile '%v'", profile), flagConfigFile, flagProfile, flagProfilesFile)
+}
+
+// configFileValue converts a decoded YAML value to the type expected for the given flag
+func configFileValue(flag mcnflag.Flag, value interface{}) (interface{}, error) {
+	switch flag.(type) {
+	case mcnflag.StringFlag:
+		return configFileScalar(value)
+	case mcnflag.IntFlag:
+		switch v := value.(type) {
+		case int:
+			return v, nil
+		case string:
+			return strconv.Atoi(v)
+		}
+		return nil, fmt.Errorf("expected an integer, got %v", value)
+	case mcnflag.BoolFlag:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("expected a boolean, got %v", value)
+	case mcnflag.StringSliceFlag:
+		return configFileSlice(value)
+	}
+	return nil, fmt.Errorf("unsupported flag type %T", flag)
+}
+
+func configFileScalar(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int, float64, bool:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("expected a scalar, got %v", value)
+}
+
+// configFileSlice accepts single values, lists and, for key-value flags such as labels, maps
+func configFileSlice(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		ret := make([]string, 0, len(v))
+		for _, elem := range v {
+			str, err := configFileScalar(elem)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, str)
+		}
+		return ret, nil
+	case map[string]interface{}:
+		ret := make([]string, 0, len(v))
+		for key, elem := range v {
+			str, err := configFileScalar(elem)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, key+"="+str)
+		}
+		sort.Strings(ret)
+		return ret, nil
+	}
+
+	str, err := configFileScalar(value)
+	if err != nil {
+		return nil, err
+	}
+	return []string{str}, nil
+}
+
+// lookup returns the config file value for key if the command line value is the flag's default
+func (o *configFileOptions) lookup(key string, cli interface{}, isDefault bool) interface{} {
+	if value, ok := o.values[key]; ok && isDefault {
+		return value
+	}
+	return cli
+}
+
+func (o *configFileOptions) String(key string) string {
+	cli := o.DriverOptions.String(key)
+	return o.lookup(key, cli, cli == "" || cli == o.defaults[key]).(string)
+}
+
+func (o *configFileOptions) StringSlice(key string) []string {
+	cli := o.DriverOptions.StringSlice(key)
+	return o.lookup(key, cli, len(cli) == 0).([]string)
+}
+
+func (o *configFileOptions) Int(key string) int {
+	cli := o.DriverOptions.Int(key)
+	return o.lookup(key, cli, cli == 0 || cli == o.defaults[key]).(int)
+}
+
+func (o *configFileOptions) Bool(key string) bool {
+	cli := o.DriverOptions.Bool(key)
+	return o.lookup(key, cli, !cli).(bool)
+}
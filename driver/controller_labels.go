@@ -0,0 +1,42 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	labelControllerHost       = "controller-host"
+	labelControllerDockerHost = "controller-docker-host"
+	maxLabelValueLength       = 63
+)
+
+var invalidLabelValueChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// labelValue converts s into a valid label value, replacing disallowed characters, e.g. of URLs, with underscores
+func labelValue(s string) string {
+	trim := func(s string) string { return strings.Trim(s, "_.-") }
+
+	value := trim(invalidLabelValueChars.ReplaceAllString(s, "_"))
+	if len(value) > maxLabelValueLength {
+		value = trim(value[:maxLabelValueLength])
+	}
+	return value
+}
+
+// setControllerLabels labels the server with the hostname and DOCKER_HOST of the controller running docker-machine, so
+// it is obvious which one owns a server when several share a project
+func (d *Driver) setControllerLabels() error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("could not determine controller hostname: %w", err)
+	}
+	d.ServerLabels[d.labelName(labelControllerHost)] = labelValue(hostname)
+
+	if dockerHost := os.Getenv("DOCKER_HOST"); dockerHost != "" {
+		d.ServerLabels[d.labelName(labelControllerDockerHost)] = labelValue(dockerHost)
+	}
+	return nil
+}
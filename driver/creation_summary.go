@@ -0,0 +1,83 @@
+package driver
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// CreationSummary records the resources and gross prices of a server at its creation, so billing reconciliation can
+// read them from the stored machine config (e.g. docker-machine inspect) without querying the API
+type CreationSummary struct {
+	ServerType         string
+	Location           string
+	ImageID            int64
+	Currency           string
+	HourlyPrice        string
+	MonthlyPrice       string
+	PrimaryIPv4Monthly string
+	PrimaryIPv6Monthly string
+	IncludedTraffic    uint64
+	CreatedAt          time.Time
+}
+
+// recordCreationSummary stores the summary of the newly created server; as it is informational only, failing to query
+// the prices is logged rather than failing the creation
+func (d *Driver) recordCreationSummary(srv *hcloud.Server) {
+	summary := &CreationSummary{
+		IncludedTraffic: srv.IncludedTraffic,
+		CreatedAt:       srv.Created.UTC(),
+	}
+	if srv.ServerType != nil {
+		summary.ServerType = srv.ServerType.Name
+	}
+	if srv.Datacenter != nil && srv.Datacenter.Location != nil {
+		summary.Location = srv.Datacenter.Location.Name
+	}
+	if srv.Image != nil {
+		summary.ImageID = srv.Image.ID
+	}
+	d.CreationSummary = summary
+
+	pricing, _, err := d.getClient().Pricing.Get(context.Background())
+	if err != nil {
+		log.Warnf("could not get prices for creation summary: %v", err)
+		return
+	}
+	summary.addPrices(pricing, srv)
+}
+
+// addPrices adds the gross prices of the server and its primary IPs in its location to the summary
+func (summary *CreationSummary) addPrices(pricing hcloud.Pricing, srv *hcloud.Server) {
+	summary.Currency = pricing.Image.PerGBMonth.Currency
+
+	for _, typePricing := range pricing.ServerTypes {
+		if typePricing.ServerType == nil || typePricing.ServerType.Name != summary.ServerType {
+			continue
+		}
+		for _, price := range typePricing.Pricings {
+			if price.Location != nil && price.Location.Name == summary.Location {
+				summary.HourlyPrice, summary.MonthlyPrice = price.Hourly.Gross, price.Monthly.Gross
+			}
+		}
+	}
+
+	for _, ipPricing := range pricing.PrimaryIPs {
+		var monthly *string
+		switch {
+		case ipPricing.Type == string(hcloud.PrimaryIPTypeIPv4) && !srv.PublicNet.IPv4.IsUnspecified():
+			monthly = &summary.PrimaryIPv4Monthly
+		case ipPricing.Type == string(hcloud.PrimaryIPTypeIPv6) && !srv.PublicNet.IPv6.IsUnspecified():
+			monthly = &summary.PrimaryIPv6Monthly
+		default:
+			continue
+		}
+		for _, price := range ipPricing.Pricings {
+			if price.Location == summary.Location {
+				*monthly = price.Monthly.Gross
+			}
+		}
+	}
+}
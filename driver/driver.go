@@ -2,10 +2,14 @@ package driver
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
+	"os"
 	"strconv"
+	"text/template"
 	"time"
 
 	"github.com/docker/machine/libmachine/drivers"
@@ -20,6 +24,10 @@ type Driver struct {
 	*drivers.BaseDriver
 
 	AccessToken       string
+	Project           string
+	ProjectsFile      string
+	FailoverProject   string
+	projectToken      string
 	Image             string
 	ImageID           int64
 	ImageArch         hcloud.Architecture
@@ -27,18 +35,38 @@ type Driver struct {
 	Type              string
 	cachedType        *hcloud.ServerType
 	Location          string
+	ServerLocations   []string
 	cachedLocation    *hcloud.Location
 	KeyID             int64
 	cachedKey         *hcloud.SSHKey
 	IsExistingKey     bool
+	KeyReused         bool
+	keyConflict       string
+	SSHKeyName        string
+	keyNameTemplate   *template.Template
+	KeyLimit          int
 	originalKey       string
-	dangling          []func()
+	SSHAgentKey       string
+	dangling          []danglingResource
 	ServerID          int64
 	cachedServer      *hcloud.Server
 	userData          string
 	userDataFile      string
+	userDataMerges    []userDataFile
+	metadataFile      string
+	strictUserData    bool
+	autoShutdownCron  string
+	swapSize          string
+	PackageMirror     string
+	phoneHomeURL      string
+	phoneHomeListen   string
+	phoneHome         *phoneHomeListener
 	Volumes           []string
 	Networks          []string
+	NetworkIPs        map[string]string
+	allocatedIPs      map[string]string
+	NetworkIPStrategy string
+	NetworkIPWebhook  string
 	UsePrivateNetwork bool
 	DisablePublic4    bool
 	DisablePublic6    bool
@@ -46,53 +74,181 @@ type Driver struct {
 	cachedPrimaryIPv4 *hcloud.PrimaryIP
 	PrimaryIPv6       string
 	cachedPrimaryIPv6 *hcloud.PrimaryIP
+	FloatingIP        string
+	FloatingIPID      int64
+	FloatingIPCreated bool
+	CreateFloatingIP  bool
+	cachedFloatingIP  *hcloud.FloatingIP
+	PrimaryIPPool     string
+	reusePrimaryIPOf  string
+	cleanupDefaultIPs bool
+	startPoweredOff   bool
+	EnableBackup      bool
+	BootRescue        bool
+	ISO               string
+	cachedISO         *hcloud.ISO
+	Protection        bool
+	ForceRemove       bool
+	standbyPool       string
 	Firewalls         []string
+	LoadBalancers     []string
+	LoadBalancerIDs   []int64
+	LoadBalancerLabel string
+	AutoLoadBalancer  string
 	ServerLabels      map[string]string
+	GuardLabel        string
+	Description       string
+	EngineLabels      bool
 	keyLabels         map[string]string
 	placementGroup    string
+	autoSpreadName    string
+	autoSpreadLabels  map[string]string
 	cachedPGrp        *hcloud.PlacementGroup
 
-	AdditionalKeys       []string
-	AdditionalKeyIDs     []int64
-	cachedAdditionalKeys []*hcloud.SSHKey
+	VSwitchID           int64
+	VSwitchSubnet       string
+	VSwitchExposeRoutes bool
+
+	AdditionalKeys            []string
+	AdditionalKeyFingerprints []string
+	AdditionalKeyIDs          []int64
+	cachedAdditionalKeys      []*hcloud.SSHKey
 
 	WaitOnError           int
 	WaitOnPolling         int
 	WaitForRunningTimeout int
+	ActionTimeout         int
+	reachabilityTimeout   int
+	MaxConcurrentRequests int
+	StateGracePeriod      int
+	CreationSummary       *CreationSummary
+	TrafficWarning        int
+	trafficWarned         bool
+
+	MetricsFile        string
+	MetricsPushgateway string
+	DebugAPIPayloads   bool
+	APICABundle        string
+	APITLSMinVersion   string
+	cachedTLSConfig    *tls.Config
+	cachedTransport    *http.Transport
+
+	Robot               bool
+	RobotUser           string
+	RobotPassword       string
+	RobotServerNumber   int64
+	RobotImage          string
+	RobotInstallArgs    string
+	RobotKeyFingerprint string
+	RobotKeyCreated     bool
+
+	locationCandidates []string
+	typeRequirements   serverTypeRequirements
+	locationStrategy   string
+	locationAuto       bool
+	antiAffinityKey    string
+	antiAffinityValue  string
+	FirewallLabel      string
+	FirewallPreset     string
+	FirewallSources    []string
+	ControllerIPURL    string
 
 	// internal housekeeping
-	version string
-	usesDfr bool
+	version  string
+	usesDfr  bool
+	offline  bool
+	traceCtx context.Context
+	usage    *apiUsage
+	shutdown *shutdownState
+	cleaning bool
+	endpoint string // API endpoint override for tests
 }
 
 const (
 	defaultImage = "ubuntu-20.04"
 	defaultType  = "cx11"
 
+	// envHcloudToken is the token variable used by the hcloud CLI and terraform, used as a fallback for --hetzner-api-token
+	envHcloudToken = "HCLOUD_TOKEN"
+
 	flagAPIToken          = "hetzner-api-token"
+	flagConfigFile        = "hetzner-config-file"
+	flagProfile           = "hetzner-profile"
+	flagProfilesFile      = "hetzner-profiles-file"
+	flagProject           = "hetzner-project"
+	flagProjectsFile      = "hetzner-projects-file"
+	flagFailoverProject   = "hetzner-failover-project"
+	flagExpandEnv         = "hetzner-expand-env"
 	flagImage             = "hetzner-image"
 	flagImageID           = "hetzner-image-id"
 	flagImageArch         = "hetzner-image-arch"
 	flagType              = "hetzner-server-type"
+	flagMinCores          = "hetzner-min-cores"
+	flagMinMemory         = "hetzner-min-memory"
+	flagMinDisk           = "hetzner-min-disk"
 	flagLocation          = "hetzner-server-location"
+	flagLocationStrategy  = "hetzner-location-strategy"
+	flagServerLocations   = "hetzner-server-locations"
 	flagExKeyID           = "hetzner-existing-key-id"
 	flagExKeyPath         = "hetzner-existing-key-path"
+	flagSSHAgentKey       = "hetzner-ssh-agent-key"
+	flagKeyConflict       = "hetzner-key-conflict"
+	flagKeyLimit          = "hetzner-key-limit"
+	flagSSHKeyName        = "hetzner-ssh-key-name"
 	flagUserData          = "hetzner-user-data"
 	flagUserDataFile      = "hetzner-user-data-file"
+	flagUserDataMergeFile = "hetzner-user-data-merge-file"
 	flagVolumes           = "hetzner-volumes"
 	flagNetworks          = "hetzner-networks"
+	flagNetworkIPStrategy = "hetzner-network-ip-strategy"
+	flagNetworkIPWebhook  = "hetzner-network-ip-webhook"
 	flagUsePrivateNetwork = "hetzner-use-private-network"
+	flagVSwitchID         = "hetzner-vswitch-id"
+	flagVSwitchSubnet     = "hetzner-vswitch-subnet"
+	flagVSwitchExpose     = "hetzner-vswitch-expose-routes"
 	flagDisablePublic4    = "hetzner-disable-public-ipv4"
 	flagDisablePublic6    = "hetzner-disable-public-ipv6"
 	flagPrimary4          = "hetzner-primary-ipv4"
 	flagPrimary6          = "hetzner-primary-ipv6"
 	flagDisablePublic     = "hetzner-disable-public"
+	flagPrimaryIPPool     = "hetzner-primary-ip-pool"
+	flagReusePrimaryIPOf  = "hetzner-reuse-primary-ip-of"
+	flagFloatingIP        = "hetzner-floating-ip"
+	flagCreateFloatingIP  = "hetzner-create-floating-ip"
 	flagFirewalls         = "hetzner-firewalls"
+	flagFirewallLabel     = "hetzner-firewall-label"
+	flagLoadBalancers     = "hetzner-load-balancer"
+	flagLoadBalancerLabel = "hetzner-load-balancer-label"
+	flagAutoLoadBalancer  = "hetzner-auto-load-balancer"
+	flagFirewallPreset    = "hetzner-firewall-preset"
+	flagFirewallSSHSource = "hetzner-firewall-ssh-source"
+	flagControllerIPURL   = "hetzner-controller-ip-url"
 	flagAdditionalKeys    = "hetzner-additional-key"
+	flagAdditionalKeyFPs  = "hetzner-additional-key-fingerprint"
 	flagServerLabel       = "hetzner-server-label"
 	flagKeyLabel          = "hetzner-key-label"
+	flagEngineLabels      = "hetzner-engine-labels"
+	flagControllerLabels  = "hetzner-controller-labels"
+	flagDescription       = "hetzner-description"
 	flagPlacementGroup    = "hetzner-placement-group"
 	flagAutoSpread        = "hetzner-auto-spread"
+	flagAutoSpreadName    = "hetzner-auto-spread-group-name"
+	flagAutoSpreadLabel   = "hetzner-auto-spread-group-label"
+	flagAntiAffinity      = "hetzner-anti-affinity-label"
+	flagGuardLabel        = "hetzner-guard-label"
+
+	flagMetricsFile        = "hetzner-metrics-file"
+	flagMetricsPushgateway = "hetzner-metrics-pushgateway"
+	flagDebugAPIPayloads   = "hetzner-debug-api-payloads"
+	flagAPICABundle        = "hetzner-api-ca-bundle"
+	flagAPITLSMinVersion   = "hetzner-api-tls-min-version"
+
+	flagRobot            = "hetzner-robot"
+	flagRobotUser        = "hetzner-robot-user"
+	flagRobotPassword    = "hetzner-robot-password"
+	flagRobotServer      = "hetzner-robot-server"
+	flagRobotImage       = "hetzner-robot-image"
+	flagRobotInstallArgs = "hetzner-robot-install-args"
 
 	flagSshUser = "hetzner-ssh-user"
 	flagSshPort = "hetzner-ssh-port"
@@ -106,6 +262,26 @@ const (
 	defaultWaitOnPolling         = 1
 	flagWaitForRunningTimeout    = "hetzner-wait-for-running-timeout"
 	defaultWaitForRunningTimeout = 0
+	flagActionTimeout            = "hetzner-action-timeout"
+	flagReachabilityTimeout      = "hetzner-reachability-timeout"
+	flagMaxConcurrentRequests    = "hetzner-max-concurrent-requests"
+	flagStateGracePeriod         = "hetzner-state-grace-period"
+	flagTrafficWarning           = "hetzner-traffic-warning"
+	flagStrictUserData           = "hetzner-strict-user-data"
+	flagMetadataFile             = "hetzner-metadata-file"
+	flagAutoShutdownCron         = "hetzner-auto-shutdown-cron"
+	flagSwapSize                 = "hetzner-swap-size"
+	flagPackageMirror            = "hetzner-package-mirror"
+	flagCleanupDefaultIPs        = "hetzner-cleanup-primary-ips"
+	flagPhoneHomeURL             = "hetzner-phone-home-url"
+	flagPhoneHomeListen          = "hetzner-phone-home-listen"
+	flagNoStartAfterCreate       = "hetzner-no-start-after-create"
+	flagStandbyPool              = "hetzner-standby-pool"
+	flagEnableBackups            = "hetzner-enable-backups"
+	flagBootRescue               = "hetzner-boot-rescue"
+	flagISO                      = "hetzner-iso"
+	flagProtection               = "hetzner-protection"
+	flagForceRemove              = "hetzner-force-remove"
 
 	legacyFlagUserDataFromFile = "hetzner-user-data-from-file"
 	legacyFlagDisablePublic4   = "hetzner-disable-public-4"
@@ -116,14 +292,12 @@ const (
 
 // NewDriver initializes a new driver instance; see [drivers.Driver.NewDriver]
 func NewDriver(version string) *Driver {
-	if runningInstrumented {
-		instrumented("running instrument mode") // will be a no-op when not built with instrumentation
-	}
 	return &Driver{
 		Type:          defaultType,
 		IsExistingKey: false,
 		BaseDriver:    &drivers.BaseDriver{},
 		version:       version,
+		shutdown:      newShutdownState(),
 	}
 }
 
@@ -134,17 +308,58 @@ func (d *Driver) DriverName() string {
 
 // GetCreateFlags retrieves additional driver-specific arguments; see [drivers.Driver.GetCreateFlags]
 func (d *Driver) GetCreateFlags() []mcnflag.Flag {
-	return []mcnflag.Flag{
+	flags := []mcnflag.Flag{
 		mcnflag.StringFlag{
 			EnvVar: "HETZNER_API_TOKEN",
 			Name:   flagAPIToken,
-			Usage:  "Project-specific Hetzner API token",
+			Usage:  "Project-specific Hetzner API token; falls back to HCLOUD_TOKEN",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_CONFIG_FILE",
+			Name:   flagConfigFile,
+			Usage:  "YAML file providing values for all flags not given on the command line",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_PROFILE",
+			Name:   flagProfile,
+			Usage:  "Named profile from the profiles file providing defaults for all flags not given otherwise",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_PROFILES_FILE",
+			Name:   flagProfilesFile,
+			Usage:  "YAML file containing named profiles; defaults to docker-machine-driver-hetzner/profiles.yaml in the user config directory",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_PROJECT",
+			Name:   flagProject,
+			Usage:  "Alias of the project from the projects file whose API token to use; only the alias is stored with the machine",
 			Value:  "",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_PROJECTS_FILE",
+			Name:   flagProjectsFile,
+			Usage:  "YAML file mapping project aliases to API tokens; defaults to docker-machine-driver-hetzner/projects.yaml in the user config directory",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_FAILOVER_PROJECT",
+			Name:   flagFailoverProject,
+			Usage:  "Alias of the project from the projects file to create the server in if the current project's resource limits are exceeded",
+			Value:  "",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_EXPAND_ENV",
+			Name:   flagExpandEnv,
+			Usage:  "Expand ${VAR} environment variable references in flag values",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "HETZNER_IMAGE",
 			Name:   flagImage,
-			Usage:  "Image to use for server creation",
+			Usage:  "Image name or ID to use for server creation; numeric values are treated as IDs",
 			Value:  "",
 		},
 		mcnflag.StringFlag{
@@ -163,12 +378,39 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "Server type to create",
 			Value:  defaultType,
 		},
+		mcnflag.IntFlag{
+			EnvVar: "HETZNER_MIN_CORES",
+			Name:   flagMinCores,
+			Usage:  "Minimum number of cores; selects the cheapest matching server type instead of --" + flagType,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "HETZNER_MIN_MEMORY",
+			Name:   flagMinMemory,
+			Usage:  "Minimum memory in GB; selects the cheapest matching server type instead of --" + flagType,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "HETZNER_MIN_DISK",
+			Name:   flagMinDisk,
+			Usage:  "Minimum disk size in GB; selects the cheapest matching server type instead of --" + flagType,
+		},
 		mcnflag.StringFlag{
 			EnvVar: "HETZNER_LOCATION",
 			Name:   flagLocation,
-			Usage:  "Location to create machine at",
+			Usage:  "Location to create machine at; a comma-separated list is chosen from according to --hetzner-location-strategy, auto chooses the closest one",
 			Value:  "",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_LOCATION_STRATEGY",
+			Name:   flagLocationStrategy,
+			Usage:  "Strategy for choosing from multiple locations: spread (fewest servers of the same location list), random or latency",
+			Value:  locationStrategySpread,
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "HETZNER_SERVER_LOCATIONS",
+			Name:   flagServerLocations,
+			Usage:  "Ordered locations to create machine at, falling back to the next one if a location lacks capacity",
+			Value:  []string{},
+		},
 		mcnflag.StringFlag{
 			EnvVar: "HETZNER_EXISTING_KEY_ID",
 			Name:   flagExKeyID,
@@ -181,6 +423,30 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "Path to existing key (new public key will be created unless --hetzner-existing-key-id is specified)",
 			Value:  "",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_KEY_CONFLICT",
+			Name:   flagKeyConflict,
+			Usage:  "Handling of an existing remote key matching the machine key: reuse, fail, or suffix (reuse, but upload under a suffixed name if the name is taken)",
+			Value:  keyConflictReuse,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_SSH_KEY_NAME",
+			Name:   flagSSHKeyName,
+			Usage:  "Name of the uploaded machine key instead of the machine name, a template like team-{{.MachineName}}",
+			Value:  "",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "HETZNER_KEY_LIMIT",
+			Name:   flagKeyLimit,
+			Usage:  "SSH key limit of the project; creation fails early if uploading the machine's keys would exceed it and warns when getting close",
+			Value:  0,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_SSH_AGENT_KEY",
+			Name:   flagSSHAgentKey,
+			Usage:  "Fingerprint of an ssh-agent key to provision with instead of storing a private key",
+			Value:  "",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "HETZNER_USER_DATA",
 			Name:   flagUserData,
@@ -198,18 +464,136 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "Cloud-init based user data (read from file)",
 			Value:  "",
 		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "HETZNER_USER_DATA_MERGE_FILE",
+			Name:   flagUserDataMergeFile,
+			Usage:  "Cloud-init based user data file merged into the user data; suffix it with :append (default) or :replace",
+			Value:  []string{},
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_METADATA_FILE",
+			Name:   flagMetadataFile,
+			Usage:  "Path of a JSON file written onto the server via cloud-init, describing how the machine was created",
+			Value:  "",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_STRICT_USER_DATA",
+			Name:   flagStrictUserData,
+			Usage:  "Fail instead of warning if cloud-config is passed to an image labeled docker-machine/cloud-init=false",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_AUTO_SHUTDOWN_CRON",
+			Name:   flagAutoShutdownCron,
+			Usage:  "Cron schedule (in the server's time zone, usually UTC) for powering off the server, set up via cloud-init",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_SWAP_SIZE",
+			Name:   flagSwapSize,
+			Usage:  "Size of a swap file created and enabled via cloud-init, e.g. 2G",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_PACKAGE_MIRROR",
+			Name:   flagPackageMirror,
+			Usage:  "Package mirror to configure via cloud-init: 'hetzner' for Hetzner's local mirror, or the URL of an apt mirror",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_PHONE_HOME_URL",
+			Name:   flagPhoneHomeURL,
+			Usage:  "URL notified by cloud-init's phone_home module once the server finished booting",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_PHONE_HOME_LISTEN",
+			Name:   flagPhoneHomeListen,
+			Usage:  "Local address to receive the phone home notification on instead of polling the server state; requires --hetzner-phone-home-url",
+			Value:  "",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_NO_START_AFTER_CREATE",
+			Name:   flagNoStartAfterCreate,
+			Usage:  "Create the server powered off, e.g. for programmatic consumers pre-provisioning machines",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_ENABLE_BACKUPS",
+			Name:   flagEnableBackups,
+			Usage:  "Enable automatic backups of the server after creating it",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_BOOT_RESCUE",
+			Name:   flagBootRescue,
+			Usage:  "Boot the server into the rescue system after creating it, e.g. for installer-based workflows",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_ISO",
+			Name:   flagISO,
+			Usage:  "ISO ID or name to attach to the server after creating it, which it boots from on its next start",
+			Value:  "",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_PROTECTION",
+			Name:   flagProtection,
+			Usage:  "Enable delete and rebuild protection of the server after creating it",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_ALWAYS_FORCE_REMOVE",
+			Name:   flagForceRemove,
+			Usage:  "Remove the machine despite the delete protection of the server, disabling it rather than failing",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_STANDBY_POOL",
+			Name:   flagStandbyPool,
+			Usage:  "Claim a powered off standby server pre-provisioned into the given pool, if available, instead of creating one",
+			Value:  "",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_CLEANUP_PRIMARY_IPS",
+			Name:   flagCleanupDefaultIPs,
+			Usage:  "Delete the primary IPs created along with the server if server creation fails",
+		},
 		mcnflag.StringSliceFlag{
 			EnvVar: "HETZNER_VOLUMES",
 			Name:   flagVolumes,
-			Usage:  "Volume IDs or names which should be attached to the server",
+			Usage:  "Volume IDs or names which should be attached to the server; numeric values are treated as IDs",
 			Value:  []string{},
 		},
 		mcnflag.StringSliceFlag{
 			EnvVar: "HETZNER_NETWORKS",
 			Name:   flagNetworks,
-			Usage:  "Network IDs or names which should be attached to the server private network interface",
+			Usage:  "Network IDs or names which should be attached to the server private network interface; numeric values are treated as IDs",
 			Value:  []string{},
 		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_NETWORK_IP_STRATEGY",
+			Name:   flagNetworkIPStrategy,
+			Usage:  "Strategy for choosing the IPs of networks given without a static IP: api (assigned by the API), sequential, random or webhook",
+			Value:  ipStrategyAPI,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_NETWORK_IP_WEBHOOK",
+			Name:   flagNetworkIPWebhook,
+			Usage:  "URL of an IPAM webhook choosing the IPs of networks with --hetzner-network-ip-strategy webhook",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_VSWITCH_ID",
+			Name:   flagVSwitchID,
+			Usage:  "Robot vSwitch ID the first network should be coupled with",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_VSWITCH_SUBNET",
+			Name:   flagVSwitchSubnet,
+			Usage:  "Subnet of the vSwitch in the first network; added if the network is not coupled yet",
+			Value:  "",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_VSWITCH_EXPOSE_ROUTES",
+			Name:   flagVSwitchExpose,
+			Usage:  "Expose the routes of the first network to the vSwitch",
+		},
 		mcnflag.BoolFlag{
 			EnvVar: "HETZNER_USE_PRIVATE_NETWORK",
 			Name:   flagUsePrivateNetwork,
@@ -252,16 +636,87 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "Existing primary IPv6 address",
 			Value:  "",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_PRIMARY_IP_POOL",
+			Name:   flagPrimaryIPPool,
+			Usage:  "Explicitly create labeled primary IPs in, or reuse free ones from, the given pool; requires --hetzner-server-location",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_REUSE_PRIMARY_IP_OF",
+			Name:   flagReusePrimaryIPOf,
+			Usage:  "Reuse the retained primary IPs labeled with the given (removed) machine's name",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_FLOATING_IP",
+			Name:   flagFloatingIP,
+			Usage:  "Existing floating IP (ID, name or address) to assign to the server and use as the machine's address",
+			Value:  "",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_CREATE_FLOATING_IP",
+			Name:   flagCreateFloatingIP,
+			Usage:  "Create an IPv4 floating IP for the server, use it as the machine's address and delete it along with the machine",
+		},
 		mcnflag.StringSliceFlag{
 			EnvVar: "HETZNER_FIREWALLS",
 			Name:   flagFirewalls,
-			Usage:  "Firewall IDs or names which should be applied on the server",
+			Usage:  "Firewall IDs or names which should be applied on the server; numeric values are treated as IDs",
 			Value:  []string{},
 		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_FIREWALL_LABEL",
+			Name:   flagFirewallLabel,
+			Usage:  "key=value server label the firewalls are applied to via a label selector, instead of attaching them to the server",
+			Value:  "",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "HETZNER_LOAD_BALANCERS",
+			Name:   flagLoadBalancers,
+			Usage:  "Load balancer IDs or names to register the server with as a target",
+			Value:  []string{},
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_LOAD_BALANCER_LABEL",
+			Name:   flagLoadBalancerLabel,
+			Usage:  "key=value server label the load balancers target via a label selector, instead of targeting the server",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_AUTO_LOAD_BALANCER",
+			Name:   flagAutoLoadBalancer,
+			Usage:  "Name of a load balancer targeting all machines given the same name, created if it does not exist",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_FIREWALL_PRESET",
+			Name:   flagFirewallPreset,
+			Usage:  "Attach a firewall created from a preset; egress-only denies inbound traffic except SSH and Docker from the controller",
+			Value:  "",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "HETZNER_FIREWALL_SSH_SOURCE",
+			Name:   flagFirewallSSHSource,
+			Usage:  "Additional addresses or CIDRs the firewall preset allows SSH and Docker access from",
+			Value:  []string{},
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_CONTROLLER_IP_URL",
+			Name:   flagControllerIPURL,
+			Usage:  "Echo service detecting the controller's public IP the firewall preset allows access from, or off",
+			Value:  defaultControllerIPURL,
+		},
 		mcnflag.StringSliceFlag{
 			EnvVar: "HETZNER_ADDITIONAL_KEYS",
 			Name:   flagAdditionalKeys,
-			Usage:  "Additional public keys to be attached to the server",
+			Usage:  "Additional public keys to be attached to the server; github:<user> and gitlab:<user> fetch published keys",
+			Value:  []string{},
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "HETZNER_ADDITIONAL_KEY_FINGERPRINTS",
+			Name:   flagAdditionalKeyFPs,
+			Usage:  "MD5 or SHA256 fingerprints of existing project keys to be attached to the server",
 			Value:  []string{},
 		},
 		mcnflag.StringSliceFlag{
@@ -276,10 +731,26 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "Key value pairs of additional labels to assign to the SSH key",
 			Value:  []string{},
 		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_ENGINE_LABELS",
+			Name:   flagEngineLabels,
+			Usage:  "Apply server labels and topology (server ID, type, datacenter) as Docker engine labels",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_CONTROLLER_LABELS",
+			Name:   flagControllerLabels,
+			Usage:  "Label the server with the hostname and DOCKER_HOST of the controller creating it",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_DESCRIPTION",
+			Name:   flagDescription,
+			Usage:  "Description of the machine's purpose, stored as the docker-machine/description label of the server",
+			Value:  "",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "HETZNER_PLACEMENT_GROUP",
 			Name:   flagPlacementGroup,
-			Usage:  "Placement group ID or name to add the server to; will be created if it does not exist",
+			Usage:  "Placement group ID or name to add the server to; will be created if it does not exist, unless given by ID (numeric values are treated as IDs)",
 			Value:  "",
 		},
 		mcnflag.BoolFlag{
@@ -287,6 +758,82 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:   flagAutoSpread,
 			Usage:  "Auto-spread on a docker-machine-specific default placement group",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_AUTO_SPREAD_GROUP_NAME",
+			Name:   flagAutoSpreadName,
+			Usage:  "Name of the auto-spread placement group, to keep the groups of controllers sharing a project apart",
+			Value:  "",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "HETZNER_AUTO_SPREAD_GROUP_LABELS",
+			Name:   flagAutoSpreadLabel,
+			Usage:  "key=value label to assign to the auto-spread placement group when creating it",
+			Value:  []string{},
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_ANTI_AFFINITY_LABEL",
+			Name:   flagAntiAffinity,
+			Usage:  "key=value label to assign; prefer locations and placement groups not hosting servers with this label",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_GUARD_LABEL",
+			Name:   flagGuardLabel,
+			Usage:  "key=value or key label which, when present on the server, makes removal fail unless HETZNER_FORCE_REMOVE=true is set when removing it",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_METRICS_FILE",
+			Name:   flagMetricsFile,
+			Usage:  "Write API metrics in Prometheus text format to the given file after each operation",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_METRICS_PUSHGATEWAY",
+			Name:   flagMetricsPushgateway,
+			Usage:  "Push API metrics to the given Prometheus pushgateway URL after each operation",
+			Value:  "",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_DEBUG_API_PAYLOADS",
+			Name:   flagDebugAPIPayloads,
+			Usage:  "Log API request and response payloads, with credentials redacted, at debug level",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "HETZNER_ROBOT",
+			Name:   flagRobot,
+			Usage:  "Provision a dedicated server via the Robot API instead of a cloud server",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_ROBOT_USER",
+			Name:   flagRobotUser,
+			Usage:  "Robot webservice user",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_ROBOT_PASSWORD",
+			Name:   flagRobotPassword,
+			Usage:  "Robot webservice password",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_ROBOT_SERVER",
+			Name:   flagRobotServer,
+			Usage:  "Number of the dedicated server to provision",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_ROBOT_IMAGE",
+			Name:   flagRobotImage,
+			Usage:  "installimage image to install from the rescue system; keep the rescue system if empty",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_ROBOT_INSTALL_ARGS",
+			Name:   flagRobotInstallArgs,
+			Usage:  "Additional installimage arguments, e.g. drive and RAID setup",
+			Value:  "",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "HETZNER_SSH_USER",
 			Name:   flagSshUser,
@@ -317,7 +864,50 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "Period for waiting for a machine to be running before failing",
 			Value:  defaultWaitForRunningTimeout,
 		},
+		mcnflag.IntFlag{
+			EnvVar: "HETZNER_ACTION_TIMEOUT",
+			Name:   flagActionTimeout,
+			Usage:  "Period in seconds for waiting for API actions, like attaching a volume, to complete before failing; 0 for no timeout",
+			Value:  0,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "HETZNER_REACHABILITY_TIMEOUT",
+			Name:   flagReachabilityTimeout,
+			Usage:  "Period in seconds for waiting for the SSH port of a new server to accept TCP connections before failing; 0 to skip the check",
+			Value:  0,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "HETZNER_MAX_CONCURRENT_REQUESTS",
+			Name:   flagMaxConcurrentRequests,
+			Usage:  "Maximum number of concurrent API requests of all driver processes on this host using the same token; 0 for no limit",
+			Value:  0,
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_API_CA_BUNDLE",
+			Name:   flagAPICABundle,
+			Usage:  "PEM file of additional CA certificates trusted for the API, e.g. of a TLS-intercepting proxy",
+			Value:  "",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "HETZNER_API_TLS_MIN_VERSION",
+			Name:   flagAPITLSMinVersion,
+			Usage:  "Minimum TLS version for the API, 1.2 or 1.3",
+			Value:  "",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "HETZNER_STATE_GRACE_PERIOD",
+			Name:   flagStateGracePeriod,
+			Usage:  "Period in seconds for retrying state queries of a server that is not found or fails transiently before reporting it",
+			Value:  0,
+		},
+		mcnflag.IntFlag{
+			EnvVar: "HETZNER_TRAFFIC_WARNING",
+			Name:   flagTrafficWarning,
+			Usage:  "Warn when querying the state of a server whose outgoing traffic exceeds the given percentage of its included traffic; 0 to disable",
+			Value:  0,
+		},
 	}
+	return append(flags, aliasFlags(flags)...)
 }
 
 func flagI64(opts drivers.DriverOptions, key string) (int64, error) {
@@ -340,10 +930,37 @@ func (d *Driver) SetConfigFromFlags(opts drivers.DriverOptions) error {
 	return d.setConfigFromFlags(opts)
 }
 
+// ValidateFlags checks the given options like [Driver.SetConfigFromFlags], i.e. mutual exclusions, formats, label
+// syntax and keys, but without requiring credentials, so node templates can be linted in CI and unit tests without a
+// token; the driver is not usable to create machines afterwards
+func (d *Driver) ValidateFlags(opts drivers.DriverOptions) error {
+	d.offline = true
+	defer func() { d.offline = false }()
+	return d.setConfigFromFlags(opts)
+}
+
 func (d *Driver) setConfigFromFlagsImpl(opts drivers.DriverOptions) error {
-	var err error
+	opts, err := d.withFlagAliases(opts)
+	if err != nil {
+		return err
+	}
+	opts, err = d.withConfigFile(opts)
+	if err != nil {
+		return err
+	}
+	opts, err = d.withEnvExpansion(opts)
+	if err != nil {
+		return err
+	}
 
 	d.AccessToken = opts.String(flagAPIToken)
+	if d.AccessToken == "" {
+		d.AccessToken = os.Getenv(envHcloudToken)
+	}
+	err = d.setProjectFlags(opts.String(flagProject), opts.String(flagProjectsFile))
+	if err != nil {
+		return err
+	}
 	d.Image = opts.String(flagImage)
 	d.ImageID, err = flagI64(opts, flagImageID)
 	if err != nil {
@@ -353,28 +970,102 @@ func (d *Driver) setConfigFromFlagsImpl(opts drivers.DriverOptions) error {
 	if err != nil {
 		return err
 	}
-	d.Location = opts.String(flagLocation)
+	err = d.setLocationFlag(opts.String(flagLocation), opts.String(flagLocationStrategy))
+	if err != nil {
+		return err
+	}
+	err = d.setServerLocationsFlag(opts.StringSlice(flagServerLocations))
+	if err != nil {
+		return err
+	}
 	d.Type = opts.String(flagType)
+	err = d.setServerTypeRequirementsFlags(opts.Int(flagMinCores), opts.Int(flagMinMemory), opts.Int(flagMinDisk))
+	if err != nil {
+		return err
+	}
 	d.KeyID, err = flagI64(opts, flagExKeyID)
 	if err != nil {
 		return err
 	}
 	d.IsExistingKey = d.KeyID != 0
+	err = d.setFailoverProjectFlag(opts.String(flagFailoverProject))
+	if err != nil {
+		return err
+	}
 	d.originalKey = opts.String(flagExKeyPath)
+	err = d.setKeyConflictFlag(opts.String(flagKeyConflict))
+	if err != nil {
+		return err
+	}
+	err = d.setKeyNameFlag(opts.String(flagSSHKeyName))
+	if err != nil {
+		return err
+	}
+	d.KeyLimit = opts.Int(flagKeyLimit)
+	d.SSHAgentKey = opts.String(flagSSHAgentKey)
 	err = d.setUserDataFlags(opts)
 	if err != nil {
 		return err
 	}
+	d.metadataFile = opts.String(flagMetadataFile)
+	d.strictUserData = opts.Bool(flagStrictUserData)
+	err = d.setAutoShutdownFlag(opts.String(flagAutoShutdownCron))
+	if err != nil {
+		return err
+	}
+	err = d.setSwapSizeFlag(opts.String(flagSwapSize))
+	if err != nil {
+		return err
+	}
+	err = d.setPackageMirrorFlag(opts.String(flagPackageMirror))
+	if err != nil {
+		return err
+	}
+	err = d.setPhoneHomeFlags(opts.String(flagPhoneHomeURL), opts.String(flagPhoneHomeListen))
+	if err != nil {
+		return err
+	}
+	d.cleanupDefaultIPs = opts.Bool(flagCleanupDefaultIPs)
+	d.startPoweredOff = opts.Bool(flagNoStartAfterCreate)
+	d.EnableBackup = opts.Bool(flagEnableBackups)
+	err = d.setStandbyPoolFlag(opts.String(flagStandbyPool))
+	if err != nil {
+		return err
+	}
 	d.Volumes = opts.StringSlice(flagVolumes)
-	d.Networks = opts.StringSlice(flagNetworks)
+	d.Networks, d.NetworkIPs, err = d.parseNetworksFlag(opts.StringSlice(flagNetworks))
+	if err != nil {
+		return err
+	}
+	err = d.setNetworkIPFlags(opts.String(flagNetworkIPStrategy), opts.String(flagNetworkIPWebhook))
+	if err != nil {
+		return err
+	}
+	d.VSwitchID, err = flagI64(opts, flagVSwitchID)
+	if err != nil {
+		return err
+	}
+	d.VSwitchSubnet = opts.String(flagVSwitchSubnet)
+	d.VSwitchExposeRoutes = opts.Bool(flagVSwitchExpose)
 	disablePublic := opts.Bool(flagDisablePublic)
 	d.UsePrivateNetwork = opts.Bool(flagUsePrivateNetwork) || disablePublic
 	d.DisablePublic4 = d.deprecatedBooleanFlag(opts, flagDisablePublic4, legacyFlagDisablePublic4) || disablePublic
 	d.DisablePublic6 = d.deprecatedBooleanFlag(opts, flagDisablePublic6, legacyFlagDisablePublic6) || disablePublic
 	d.PrimaryIPv4 = opts.String(flagPrimary4)
 	d.PrimaryIPv6 = opts.String(flagPrimary6)
+	d.PrimaryIPPool = opts.String(flagPrimaryIPPool)
+	d.reusePrimaryIPOf = opts.String(flagReusePrimaryIPOf)
+	d.FloatingIP = opts.String(flagFloatingIP)
+	d.CreateFloatingIP = opts.Bool(flagCreateFloatingIP)
 	d.Firewalls = opts.StringSlice(flagFirewalls)
 	d.AdditionalKeys = opts.StringSlice(flagAdditionalKeys)
+	d.AdditionalKeyFingerprints = opts.StringSlice(flagAdditionalKeyFPs)
+	if err = d.verifyKeyFingerprintFlags(); err != nil {
+		return err
+	}
+	if err = d.verifyAdditionalKeyFlags(); err != nil {
+		return err
+	}
 
 	d.SSHUser = opts.String(flagSshUser)
 	d.SSHPort = opts.Int(flagSshPort)
@@ -382,6 +1073,29 @@ func (d *Driver) setConfigFromFlagsImpl(opts drivers.DriverOptions) error {
 	d.WaitOnError = opts.Int(flagWaitOnError)
 	d.WaitOnPolling = opts.Int(flagWaitOnPolling)
 	d.WaitForRunningTimeout = opts.Int(flagWaitForRunningTimeout)
+	d.ActionTimeout = opts.Int(flagActionTimeout)
+	d.reachabilityTimeout = opts.Int(flagReachabilityTimeout)
+	d.MaxConcurrentRequests = opts.Int(flagMaxConcurrentRequests)
+	if err = d.setAPITLSFlags(opts.String(flagAPICABundle), opts.String(flagAPITLSMinVersion)); err != nil {
+		return err
+	}
+	d.StateGracePeriod = opts.Int(flagStateGracePeriod)
+	d.TrafficWarning = opts.Int(flagTrafficWarning)
+
+	d.MetricsFile = opts.String(flagMetricsFile)
+	d.MetricsPushgateway = opts.String(flagMetricsPushgateway)
+	d.DebugAPIPayloads = opts.Bool(flagDebugAPIPayloads)
+	debugAPIPayloads = d.DebugAPIPayloads
+
+	d.Robot = opts.Bool(flagRobot)
+	d.RobotUser = opts.String(flagRobotUser)
+	d.RobotPassword = opts.String(flagRobotPassword)
+	d.RobotServerNumber, err = flagI64(opts, flagRobotServer)
+	if err != nil {
+		return err
+	}
+	d.RobotImage = opts.String(flagRobotImage)
+	d.RobotInstallArgs = opts.String(flagRobotInstallArgs)
 
 	d.placementGroup = opts.String(flagPlacementGroup)
 	if opts.Bool(flagAutoSpread) {
@@ -390,16 +1104,81 @@ func (d *Driver) setConfigFromFlagsImpl(opts drivers.DriverOptions) error {
 		}
 		d.placementGroup = autoSpreadPgName
 	}
+	err = d.setAutoSpreadFlags(opts.String(flagAutoSpreadName), opts.StringSlice(flagAutoSpreadLabel))
+	if err != nil {
+		return err
+	}
 
 	err = d.setLabelsFromFlags(opts)
 	if err != nil {
 		return err
 	}
+	d.EngineLabels = opts.Bool(flagEngineLabels)
+	if d.startPoweredOff && d.EngineLabels {
+		return d.flagFailure("--%v and --%v are mutually exclusive", flagNoStartAfterCreate, flagEngineLabels)
+	}
+	if d.startPoweredOff && d.phoneHomeListen != "" {
+		return d.flagFailure("--%v and --%v are mutually exclusive", flagNoStartAfterCreate, flagPhoneHomeListen)
+	}
+	if d.startPoweredOff && d.reachabilityTimeout > 0 {
+		return d.flagFailure("--%v and --%v are mutually exclusive", flagNoStartAfterCreate, flagReachabilityTimeout)
+	}
+	// the floating IP is configured on the server via SSH
+	if d.startPoweredOff && d.FloatingIP != "" {
+		return d.flagFailure("--%v and --%v are mutually exclusive", flagNoStartAfterCreate, flagFloatingIP)
+	}
+	if d.startPoweredOff && d.CreateFloatingIP {
+		return d.flagFailure("--%v and --%v are mutually exclusive", flagNoStartAfterCreate, flagCreateFloatingIP)
+	}
+	if err = d.setBootRescueFlag(opts.Bool(flagBootRescue)); err != nil {
+		return err
+	}
+	d.ISO = opts.String(flagISO)
+	d.Protection = opts.Bool(flagProtection)
+	d.ForceRemove = opts.Bool(flagForceRemove)
+	if d.ISO != "" && d.BootRescue {
+		return d.flagFailure("--%v and --%v are mutually exclusive", flagISO, flagBootRescue)
+	}
+	if opts.Bool(flagControllerLabels) {
+		if err = d.setControllerLabels(); err != nil {
+			return err
+		}
+	}
+	err = d.setDescriptionFlag(opts.String(flagDescription))
+	if err != nil {
+		return err
+	}
+	err = d.setGuardLabelFlag(opts.String(flagGuardLabel))
+	if err != nil {
+		return err
+	}
+	err = d.setAntiAffinityFlag(opts.String(flagAntiAffinity))
+	if err != nil {
+		return err
+	}
+	err = d.setFirewallLabelFlag(opts.String(flagFirewallLabel))
+	if err != nil {
+		return err
+	}
+	err = d.setLoadBalancerFlags(opts.StringSlice(flagLoadBalancers), opts.String(flagLoadBalancerLabel),
+		opts.String(flagAutoLoadBalancer))
+	if err != nil {
+		return err
+	}
+	err = d.setFirewallPresetFlags(opts.String(flagFirewallPreset), opts.StringSlice(flagFirewallSSHSource),
+		opts.String(flagControllerIPURL))
+	if err != nil {
+		return err
+	}
 
 	d.SetSwarmConfigFromFlags(opts)
 
-	if d.AccessToken == "" {
-		return d.flagFailure("hetzner requires --%v to be set", flagAPIToken)
+	if d.Robot {
+		if err = d.verifyRobotFlags(); err != nil {
+			return err
+		}
+	} else if d.apiToken() == "" && !d.offline {
+		return d.flagFailure("hetzner requires --%v, --%v or %v to be set", flagAPIToken, flagProject, envHcloudToken)
 	}
 
 	if err = d.verifyImageFlags(); err != nil {
@@ -410,6 +1189,10 @@ func (d *Driver) setConfigFromFlagsImpl(opts drivers.DriverOptions) error {
 		return err
 	}
 
+	if err = d.verifyFailoverFlags(); err != nil {
+		return err
+	}
+
 	instrumented(d)
 
 	if d.usesDfr {
@@ -433,19 +1216,64 @@ func (d *Driver) GetSSHPort() (int, error) {
 }
 
 // PreCreateCheck validates the Driver data is in a valid state for creation; see [drivers.Driver.PreCreateCheck]
-func (d *Driver) PreCreateCheck() error {
+func (d *Driver) PreCreateCheck() (err error) {
+	defer d.operation("PreCreateCheck")(&err)
+
+	if d.Robot {
+		return d.preCreateCheckRobot()
+	}
+
+	if err := d.verifyTokenWritable(); err != nil {
+		return err
+	}
+
 	if err := d.setupExistingKey(); err != nil {
 		return err
 	}
 
-	if serverType, err := d.getType(); err != nil {
+	if err := d.resolveAutoLocations(); err != nil {
+		return fmt.Errorf("could not resolve locations: %w", err)
+	}
+
+	if err := d.applyAntiAffinity(); err != nil {
+		return fmt.Errorf("could not apply anti-affinity: %w", err)
+	}
+
+	if err := d.selectLocation(); err != nil {
+		return fmt.Errorf("could not select location: %w", err)
+	}
+	if err := d.selectServerType(); err != nil {
+		return fmt.Errorf("could not select server type: %w", err)
+	}
+
+	additionalKeys, err := d.resolveAdditionalKeys()
+	if err != nil {
+		return fmt.Errorf("could not resolve additional keys: %w", err)
+	}
+	if err = d.checkKeyLimit(len(additionalKeys)); err != nil {
+		return err
+	}
+
+	for _, fp := range d.AdditionalKeyFingerprints {
+		if _, err := d.getRemoteKeyByFingerprint(fp); err != nil {
+			return fmt.Errorf("could not resolve additional key %v: %w", fp, err)
+		}
+	}
+
+	serverType, err := d.getType()
+	if err != nil {
 		return fmt.Errorf("could not get type: %w", err)
 	} else if d.ImageArch != "" && serverType.Architecture != d.ImageArch {
 		log.Warnf("supplied architecture %v differs from server architecture %v", d.ImageArch, serverType.Architecture)
 	}
+	if err = d.verifyISO(serverType.Architecture); err != nil {
+		return fmt.Errorf("could not resolve ISO: %w", err)
+	}
 
-	if _, err := d.getImage(); err != nil {
+	if image, err := d.getImage(); err != nil {
 		return fmt.Errorf("could not get image: %w", err)
+	} else if err = d.verifyCloudInitSupport(image); err != nil {
+		return err
 	}
 
 	if _, err := d.getLocationNullable(); err != nil {
@@ -464,69 +1292,251 @@ func (d *Driver) PreCreateCheck() error {
 		return fmt.Errorf("could not resolve primary IPv6: %w", err)
 	}
 
+	if err := d.verifyFloatingIP(); err != nil {
+		return fmt.Errorf("could not resolve floating IP: %w", err)
+	}
+
 	if d.UsePrivateNetwork && len(d.Networks) == 0 {
 		return fmt.Errorf("no private network attached")
 	}
 
+	if _, err := d.checkVSwitch(); err != nil {
+		return fmt.Errorf("could not check vSwitch: %w", err)
+	}
+
 	return nil
 }
 
 // Create actually creates the hetzner-cloud server; see [drivers.Driver.Create]
-func (d *Driver) Create() error {
-	err := d.prepareLocalKey()
+func (d *Driver) Create() (err error) {
+	defer d.operation("Create")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.Robot {
+		return d.createRobot()
+	}
+	d.setMachineLabel()
+
+	defer func() {
+		// on success, nothing dangles anymore; otherwise resources left behind are reported along with the error
+		if leaked := d.destroyDangling(); leaked != nil {
+			err = errors.Join(err, leaked)
+		}
+	}()
+
+	if d.standbyPool != "" && !d.startPoweredOff {
+		claimed, err := d.claimStandby()
+		if err != nil {
+			return err
+		}
+		if claimed != nil {
+			// the standby server was created with the same create settings, the others are applied as for a new one
+			return d.completeCreation(hcloud.ServerCreateResult{Server: claimed})
+		}
+	}
+
+	err = d.prepareLocalKey()
 	if err != nil {
 		return err
 	}
 
-	defer d.destroyDangling()
 	err = d.createRemoteKeys()
 	if err != nil {
 		return err
 	}
+	progress(20, "key uploaded")
 
-	log.Infof("Creating Hetzner server...")
+	if err = d.listenPhoneHome(); err != nil {
+		return err
+	}
+	defer d.closePhoneHome()
 
-	srvopts, err := d.makeCreateServerOptions()
-	if err != nil {
+	if err = d.setupVSwitch(); err != nil {
+		return fmt.Errorf("could not set up vSwitch: %w", err)
+	}
+	if err = d.allocateNetworkIPs(); err != nil {
 		return err
 	}
 
-	srv, _, err := d.getClient().Server.Create(context.Background(), instrumented(*srvopts))
+	if err = d.interrupted(); err != nil {
+		return err
+	}
+	log.Infof("Creating Hetzner server...")
+
+	srv, err := d.createServer()
+	if err != nil && d.shouldFailOver(err) {
+		if err = d.failOver(); err != nil {
+			return err
+		}
+		if err = d.createRemoteKeys(); err != nil {
+			return err
+		}
+		if err = d.allocateNetworkIPs(); err != nil {
+			return err
+		}
+		srv, err = d.createServer()
+	}
+	for err != nil && d.shouldFallBack(err) {
+		if err = d.fallBack(err); err != nil {
+			return err
+		}
+		// the IPs allocated for the previous attempt may have been taken meanwhile
+		if err = d.allocateNetworkIPs(); err != nil {
+			return err
+		}
+		srv, err = d.createServer()
+	}
 	if err != nil {
 		time.Sleep(time.Duration(d.WaitOnError) * time.Second)
 		return fmt.Errorf("could not create server: %w", err)
 	}
+	if d.cleanupDefaultIPs {
+		d.trackDefaultPrimaryIPs(srv.Server)
+	}
 
 	log.Infof(" -> Creating server %s[%d] in %s[%d]", srv.Server.Name, srv.Server.ID, srv.Action.Command, srv.Action.ID)
 	if err = d.waitForAction(srv.Action); err != nil {
-		return fmt.Errorf("could not wait for action: %w", err)
+		err = fmt.Errorf("could not wait for action: %w", err)
+		if d.interrupted() != nil {
+			d.ServerID = srv.Server.ID
+			return d.rollbackServer(srv.Server, err)
+		}
+		return err
 	}
 
 	d.ServerID = srv.Server.ID
+	defer func() {
+		// a shutdown interrupted the setup, so the half-created server is rolled back unless that already happened
+		if err != nil && d.ServerID != 0 && d.interrupted() != nil {
+			err = d.rollbackServer(srv.Server, err)
+		}
+	}()
+	progress(40, "server created")
 	log.Infof(" -> Server %s[%d]: Waiting to come up...", srv.Server.Name, srv.Server.ID)
 
+	if err = d.attachISO(srv.Server); err != nil {
+		return err
+	}
 	err = d.waitForInitialStartup(srv)
 	if err != nil {
 		return err
 	}
+	return d.completeCreation(srv)
+}
+
+// completeCreation applies the settings which do not need to be passed when creating the server to the started server,
+// of a new machine or a claimed standby server
+func (d *Driver) completeCreation(srv hcloud.ServerCreateResult) (err error) {
+	if err = d.enableBackups(srv.Server); err != nil {
+		return err
+	}
+	if err = d.bootIntoRescue(srv.Server); err != nil {
+		return err
+	}
+	progress(60, "server running")
 
 	err = d.configureNetworkAccess(srv)
 	if err != nil {
 		return err
 	}
+	progress(90, "network configured")
+
+	if d.reachabilityTimeout > 0 {
+		if err = d.waitForReachableSSH(srv.Server); err != nil {
+			return err
+		}
+	}
+
+	if err = d.assignFloatingIP(srv.Server); err != nil {
+		return err
+	}
+
+	if err = d.registerLoadBalancers(srv.Server); err != nil {
+		return err
+	}
+	if err = d.ensureAutoLoadBalancer(srv.Server); err != nil {
+		return err
+	}
+
+	err = d.applyEngineLabels(srv.Server)
+	if err != nil {
+		return err
+	}
+
+	if err = d.enableProtection(srv.Server); err != nil {
+		return err
+	}
+
+	d.recordCreationSummary(srv.Server)
 
 	log.Infof(" -> Server %s[%d] ready. Ip %s", srv.Server.Name, srv.Server.ID, d.IPAddress)
+	progress(100, "server ready")
 	// Successful creation, so no keys dangle anymore
 	d.dangling = nil
 
 	return nil
 }
 
+func (d *Driver) createServer() (srv hcloud.ServerCreateResult, err error) {
+	srvopts, err := d.makeCreateServerOptions()
+	if err != nil {
+		return srv, err
+	}
+
+	err = d.retryClassified("server creation", d.isRetryableCreationError, func() (err error) {
+		srv, _, err = d.getClient().Server.Create(context.Background(), instrumented(*srvopts))
+		if err == nil || errorCode(err) == string(hcloud.ErrorCodeRateLimitExceeded) || !d.isRetryableCreationError(err) {
+			return // rate limited requests are rejected before anything is created
+		}
+		return d.checkServerCreated(srvopts.Name, err)
+	})
+	return srv, err
+}
+
+// checkServerCreated looks up the server after a transient failure of its creation request, which may have been
+// processed nonetheless; as retrying would then fail or create a duplicate, a server found is tracked for cleanup and
+// creation fails for good
+func (d *Driver) checkServerCreated(name string, createErr error) error {
+	existing, _, err := d.getClient().Server.GetByName(context.Background(), name)
+	if err != nil {
+		return fmt.Errorf("could not check whether server %v was created despite the failed request, not retrying: %v: %v", name, createErr, err)
+	}
+	if existing == nil {
+		return createErr
+	}
+
+	d.trackDangling("server", func() error {
+		_, _, err := d.getClient().Server.DeleteWithResult(context.Background(), existing)
+		return err
+	})
+	return fmt.Errorf("server %v[%d] was created despite the failed request, not retrying: %v", existing.Name, existing.ID, createErr)
+}
+
 // GetSSHHostname retrieves the SSH host to connect to the machine; see [drivers.Driver.GetSSHHostname]
 func (d *Driver) GetSSHHostname() (string, error) {
 	return d.GetIP()
 }
 
+// GetHcloudClient returns a client for the cloud API, configured with the machine's token and the driver's
+// instrumentation, for programmatic consumers needing more than the [drivers.Driver] interface offers
+func (d *Driver) GetHcloudClient() *hcloud.Client {
+	return d.getClient()
+}
+
+// GetHcloudServer returns the machine's server as reported by the cloud API, including details such as its datacenter,
+// included traffic and labels; it is only queried once per driver instance
+func (d *Driver) GetHcloudServer() (*hcloud.Server, error) {
+	if d.Robot {
+		return nil, errors.New("robot servers are not managed by the cloud API")
+	}
+	return d.getServerHandle()
+}
+
 // GetURL retrieves the URL of the docker daemon on the machine; see [drivers.Driver.GetURL]
 func (d *Driver) GetURL() (string, error) {
 	if err := drivers.MustBeRunning(d); err != nil {
@@ -543,13 +1553,18 @@ func (d *Driver) GetURL() (string, error) {
 
 // GetState retrieves the state the machine is currently in; see [drivers.Driver.GetState]
 func (d *Driver) GetState() (state.State, error) {
-	srv, _, err := d.getClient().Server.GetByID(context.Background(), d.ServerID)
+	if d.Robot {
+		return d.getStateRobot()
+	}
+
+	srv, err := d.getServerForState()
 	if err != nil {
 		return state.None, fmt.Errorf("could not get server by ID: %w", err)
 	}
 	if srv == nil {
 		return state.None, errors.New("server not found")
 	}
+	d.warnTrafficUsage(srv)
 
 	switch srv.Status {
 	case hcloud.ServerStatusInitializing:
@@ -563,50 +1578,67 @@ func (d *Driver) GetState() (state.State, error) {
 }
 
 // Remove deletes the hetzner server and additional resources created during creation; see [drivers.Driver.Remove]
-func (d *Driver) Remove() error {
-	if err := d.destroyServer(); err != nil {
+func (d *Driver) Remove() (err error) {
+	defer d.operation("Remove")(&err)
+	// removal is cleanup itself, so it is completed rather than interrupted on shutdown
+	defer d.cleanup()()
+
+	unlock, err := d.lockMachine()
+	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	// failure to remove a key is not ha hard error
-	for i, id := range d.AdditionalKeyIDs {
-		log.Infof(" -> Destroying additional key #%d (%d)", i, id)
-		key, _, softErr := d.getClient().SSHKey.GetByID(context.Background(), id)
-		if softErr != nil {
-			log.Warnf(" ->  -> could not retrieve key %v", softErr)
-		} else if key == nil {
-			log.Warnf(" ->  -> %d no longer exists", id)
-		}
-
-		_, softErr = d.getClient().SSHKey.Delete(context.Background(), key)
-		if softErr != nil {
-			log.Warnf(" ->  -> could not remove key: %v", softErr)
-		}
+	if d.Robot {
+		return d.removeRobot()
 	}
 
-	// failure to remove a server-specific key is a hard error
-	if !d.IsExistingKey && d.KeyID != 0 {
-		key, err := d.getKeyNullable()
+	if d.ServerID != 0 {
+		srv, err := d.getServerHandleNullable()
 		if err != nil {
-			return fmt.Errorf("could not get ssh key: %w", err)
+			return fmt.Errorf("could not get server handle: %w", err)
 		}
-		if key == nil {
-			log.Infof(" -> SSH key does not exist anymore")
-			return nil
+		if err = d.checkRemovalGuard(srv); err != nil {
+			return err
 		}
-
-		log.Infof(" -> Destroying SSHKey %s[%d]...", key.Name, key.ID)
-
-		if _, err := d.getClient().SSHKey.Delete(context.Background(), key); err != nil {
-			return fmt.Errorf("could not delete ssh key: %w", err)
+		if err = d.checkDeleteProtection(srv); err != nil {
+			return err
+		}
+		if err = d.disableProtection(srv); err != nil {
+			return err
 		}
 	}
 
-	return nil
+	d.deregisterLoadBalancers()
+	if err := d.destroyServer(); err != nil {
+		return err
+	}
+	if err := d.removeFloatingIP(); err != nil {
+		return err
+	}
+	// failure to remove the auto-created load balancer is not a hard error, like for placement groups
+	if err := d.removeAutoLoadBalancer(); err != nil {
+		log.Error(err)
+	}
+
+	// the server is gone at this point, so keys left behind are reported separately, see IncompleteCleanupError
+	return d.removeMachineKeys()
 }
 
 // Restart instructs the hetzner cloud server to reboot; see [drivers.Driver.Restart]
-func (d *Driver) Restart() error {
+func (d *Driver) Restart() (err error) {
+	defer d.operation("Restart")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.Robot {
+		return d.resetRobot("sw", "Rebooting")
+	}
+
 	srv, err := d.getServerHandle()
 	if err != nil {
 		return fmt.Errorf("could not get server handle: %w", err)
@@ -626,7 +1658,19 @@ func (d *Driver) Restart() error {
 }
 
 // Start instructs the hetzner cloud server to power up; see [drivers.Driver.Start]
-func (d *Driver) Start() error {
+func (d *Driver) Start() (err error) {
+	defer d.operation("Start")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.Robot {
+		return d.powerRobot(true)
+	}
+
 	srv, err := d.getServerHandle()
 	if err != nil {
 		return fmt.Errorf("could not get server handle: %w", err)
@@ -643,7 +1687,19 @@ func (d *Driver) Start() error {
 }
 
 // Stop instructs the hetzner cloud server to shut down; see [drivers.Driver.Stop]
-func (d *Driver) Stop() error {
+func (d *Driver) Stop() (err error) {
+	defer d.operation("Stop")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.Robot {
+		return d.powerRobot(false)
+	}
+
 	srv, err := d.getServerHandle()
 	if err != nil {
 		return fmt.Errorf("could not get server handle: %w", err)
@@ -660,7 +1716,19 @@ func (d *Driver) Stop() error {
 }
 
 // Kill forcefully shuts down the hetzner cloud server; see [drivers.Driver.Kill]
-func (d *Driver) Kill() error {
+func (d *Driver) Kill() (err error) {
+	defer d.operation("Kill")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.Robot {
+		return d.resetRobot("power_long", "Powering off")
+	}
+
 	srv, err := d.getServerHandle()
 	if err != nil {
 		return fmt.Errorf("could not get server handle: %w", err)
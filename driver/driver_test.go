@@ -1,14 +1,35 @@
 package driver
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/docker/machine/commands/commandstest"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
 )
 
 var defaultFlags = map[string]interface{}{
@@ -27,6 +48,56 @@ func makeFlags(args map[string]interface{}) drivers.DriverOptions {
 	return &commandstest.FakeFlagger{Data: combined}
 }
 
+// fakeAPI serves canned JSON responses keyed by method and path, e.g. "GET /servers/42", and records the requests made
+// with the last body of each; error responses are served with status 422 and actions are always reported as finished
+type fakeAPI struct {
+	*httptest.Server
+	requests []string
+	bodies   map[string]string
+}
+
+func newFakeAPI(t *testing.T, responses map[string]string) *fakeAPI {
+	api := &fakeAPI{bodies: make(map[string]string)}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.Path
+		api.requests = append(api.requests, key)
+		if body, err := io.ReadAll(r.Body); err == nil {
+			api.bodies[key] = string(body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if body, ok := responses[key]; ok {
+			if strings.HasPrefix(body, `{"error"`) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			}
+			_, _ = fmt.Fprint(w, body)
+		} else if key == "GET /actions" {
+			_, _ = fmt.Fprint(w, `{"actions": [{"id": 1, "status": "success", "progress": 100}]}`)
+		} else {
+			t.Errorf("unexpected request %v", key)
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"error": {"code": "not_found", "message": "not found"}}`)
+		}
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// driver returns a driver talking to the fake API
+func (api *fakeAPI) driver() *Driver {
+	d := NewDriver("test")
+	d.AccessToken, d.endpoint = "foo", api.URL
+	return d
+}
+
+func (api *fakeAPI) requested(key string) bool {
+	for _, request := range api.requests {
+		if request == key {
+			return true
+		}
+	}
+	return false
+}
+
 func TestUserData(t *testing.T) {
 	const fileContents = "User data from file"
 	const inlineContents = "User data"
@@ -210,102 +281,3389 @@ func TestDisablePublic46Legacy(t *testing.T) {
 	}
 }
 
-func TestImageFlagExclusions(t *testing.T) {
-	// both id and name given
+func TestPrimaryIPPool(t *testing.T) {
 	d := NewDriver("test")
 	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
-		flagImageID: "42",
-		flagImage:   "answer",
+		flagPrimaryIPPool: "runners",
 	}))
-	assertMutualExclusion(t, err, flagImageID, flagImage)
+	if err == nil {
+		t.Fatal("expected error, but pool without location was accepted")
+	}
 
-	// both id and arch given
 	d = NewDriver("test")
 	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
-		flagImageID:   "42",
-		flagImageArch: string(hcloud.ArchitectureX86),
+		flagPrimaryIPPool: "not a valid label!",
+		flagLocation:      "fsn1",
 	}))
-	assertMutualExclusion(t, err, flagImageID, flagImageArch)
+	if err == nil {
+		t.Fatal("expected error, but invalid pool name was accepted")
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagPrimaryIPPool: "runners",
+		flagLocation:      "fsn1",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.PrimaryIPPool != "runners" {
+		t.Errorf("expected pool runners, but got %v", d.PrimaryIPPool)
+	}
 }
 
-func TestImageArch(t *testing.T) {
-	// no explicit arch
+func TestTrackDefaultPrimaryIPs(t *testing.T) {
 	d := NewDriver("test")
-	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
-		flagImage: "answer",
+	d.cachedPrimaryIPv4 = &hcloud.PrimaryIP{ID: 1}
+
+	srv := &hcloud.Server{}
+	srv.PublicNet.IPv4.ID = 1
+	srv.PublicNet.IPv6.ID = 2
+	d.trackDefaultPrimaryIPs(srv)
+
+	if len(d.dangling) != 1 {
+		t.Errorf("expected only the implicitly created primary IP to be tracked, but got %d", len(d.dangling))
+	}
+}
+
+func TestRobotFlags(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(&commandstest.FakeFlagger{
+		Data: map[string]interface{}{
+			flagRobot:     true,
+			flagRobotUser: "user",
+		},
+	})
+	if err == nil {
+		t.Fatal("expected error, but robot mode without credentials was accepted")
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(&commandstest.FakeFlagger{
+		Data: map[string]interface{}{
+			flagRobot:         true,
+			flagRobotUser:     "user",
+			flagRobotPassword: "password",
+			flagRobotServer:   "4242",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.RobotServerNumber != 4242 {
+		t.Errorf("expected server 4242, but got %v", d.RobotServerNumber)
+	}
+}
+
+func TestRobotPower(t *testing.T) {
+	cases := []struct {
+		on, poweredOn bool
+		expected      string
+	}{
+		{on: true, poweredOn: false, expected: "power"},
+		{on: true, poweredOn: true, expected: ""},
+		{on: false, poweredOn: true, expected: "power_long"},
+		{on: false, poweredOn: false, expected: ""},
+	}
+	for _, c := range cases {
+		if reset := robotPowerReset(c.on, c.poweredOn); reset != c.expected {
+			t.Errorf("expected reset %q to power %v a server powered on=%v, but got %q", c.expected, c.on, c.poweredOn, reset)
+		}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	d := NewDriver("test")
+	d.IPAddress, d.SSHPort = "127.0.0.1", listener.Addr().(*net.TCPAddr).Port
+	if !d.robotPoweredOn() {
+		t.Error("expected server with reachable SSH port to be powered on")
+	}
+	_ = listener.Close()
+	if d.robotPoweredOn() {
+		t.Error("expected server with unreachable SSH port to be powered off")
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	const config = `
+server-type: cx21
+hetzner-server-location: fsn1
+networks: [foo, 4711]
+server-label:
+  env: test
+  team: ops
+use-private-network: true
+ssh-port: 2222
+`
+
+	file := t.TempDir() + string(os.PathSeparator) + "machine.yaml"
+	err := os.WriteFile(file, []byte(config), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagConfigFile: file,
+		flagLocation:   "nbg1",
 	}))
 	if err != nil {
 		t.Fatalf("unexpected error, %v", err)
 	}
 
-	if d.ImageArch != emptyImageArchitecture {
-		t.Errorf("expected empty architecture, but got %v", d.ImageArch)
+	if d.Type != "cx21" {
+		t.Errorf("expected server type from config file, but got %v", d.Type)
+	}
+	if d.Location != "nbg1" {
+		t.Errorf("expected command line to override config file, but got location %v", d.Location)
+	}
+	if len(d.Networks) != 2 || d.Networks[0] != "foo" || d.Networks[1] != "4711" {
+		t.Errorf("expected networks from config file, but got %v", d.Networks)
+	}
+	if d.ServerLabels["env"] != "test" || d.ServerLabels["team"] != "ops" {
+		t.Errorf("expected labels from config file, but got %v", d.ServerLabels)
+	}
+	if !d.UsePrivateNetwork || d.SSHPort != 2222 {
+		t.Errorf("expected private network on port 2222, but got %v %v", d.UsePrivateNetwork, d.SSHPort)
 	}
 
-	// existing architectures
-	testArchFlag(t, hcloud.ArchitectureARM)
-	testArchFlag(t, hcloud.ArchitectureX86)
+	err = os.WriteFile(file, []byte("server-tpye: cx21\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagConfigFile: file,
+	}))
+	if err == nil || !strings.Contains(err.Error(), "server-tpye") {
+		t.Errorf("expected unknown key to fail, but got %v", err)
+	}
 
-	// invalid
+	err = os.WriteFile(file, []byte("ssh-port: twenty-two\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
 	d = NewDriver("test")
 	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
-		flagImage:     "answer",
-		flagImageArch: "hal9000",
+		flagConfigFile: file,
 	}))
 	if err == nil {
-		t.Fatal("expected error, but invalid arch was accepted")
+		t.Error("expected invalid integer to fail, but no error was thrown")
 	}
 }
 
-func TestBogusId(t *testing.T) {
+func TestExportFlags(t *testing.T) {
 	d := NewDriver("test")
 	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
-		flagImageID: "answer",
+		flagImage:       "debian-12",
+		flagType:        "cx22",
+		flagNetworks:    []string{"foo", "bar:10.0.1.5"},
+		flagServerLabel: []string{"env=test"},
+		flagSshPort:     2222,
+
+		flagEnableBackups: true,
+		flagSSHKeyName:    "team-{{.MachineName}}",
+		flagKeyLimit:      20,
+		flagPackageMirror: packageMirrorHetzner,
 	}))
-	if err == nil {
-		t.Fatal("expected error, but invalid arch was accepted")
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	d.ServerLabels[d.labelName(labelControllerHost)] = "ci-1"
+
+	// export-flags runs on a machine loaded from its stored configuration, i.e. without any unexported fields
+	raw, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = NewDriver("test")
+	if err = json.Unmarshal(raw, d); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = d.ExportFlags(&buf); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if strings.Contains(buf.String(), "api-token") || strings.Contains(buf.String(), labelControllerHost) {
+		t.Errorf("expected token and driver labels not to be exported, but got %v", buf.String())
+	}
+
+	file := t.TempDir() + string(os.PathSeparator) + "machine.yaml"
+	if err = os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	clone := NewDriver("test")
+	err = clone.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagConfigFile: file}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if clone.Image != "debian-12" || clone.Type != "cx22" || clone.SSHPort != 2222 || clone.ServerLabels["env"] != "test" {
+		t.Errorf("expected settings to round-trip, but got %v %v %v %v", clone.Image, clone.Type, clone.SSHPort, clone.ServerLabels)
+	}
+	if len(clone.Networks) != 2 || clone.NetworkIPs["bar"] != "10.0.1.5" {
+		t.Errorf("expected networks to round-trip, but got %v %v", clone.Networks, clone.NetworkIPs)
+	}
+	if !clone.EnableBackup || clone.SSHKeyName != "team-{{.MachineName}}" || clone.KeyLimit != 20 || clone.PackageMirror != packageMirrorHetzner {
+		t.Errorf("expected stored settings to round-trip, but got %v %v %v %v", clone.EnableBackup, clone.SSHKeyName, clone.KeyLimit, clone.PackageMirror)
+	}
+
+	buf.Reset()
+	if err = d.ImportFlags(&buf, file); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !strings.Contains(buf.String(), "--hetzner-networks bar:10.0.1.5\n") || !strings.Contains(buf.String(), "--hetzner-ssh-port 2222\n") {
+		t.Errorf("unexpected command line flags %v", buf.String())
+	}
+	if quoted := shellQuote("it's"); quoted != `'it'\''s'` {
+		t.Errorf("unexpected quoting %v", quoted)
 	}
 }
 
-func TestLongId(t *testing.T) {
-	var testId int64 = 79871865169581
+func TestProfiles(t *testing.T) {
+	const profiles = `
+ci-small:
+  server-type: cx11
+  server-location: hel1
+ci-large:
+  server-type: cpx51
+`
+
+	dir := t.TempDir()
+	file := dir + string(os.PathSeparator) + "profiles.yaml"
+	err := os.WriteFile(file, []byte(profiles), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := dir + string(os.PathSeparator) + "machine.yaml"
+	err = os.WriteFile(config, []byte("profile: ci-large\nserver-location: fsn1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	d := NewDriver("test")
-	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
-		flagImageID: strconv.FormatInt(testId, 10),
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagProfile:      "ci-small",
+		flagProfilesFile: file,
 	}))
 	if err != nil {
 		t.Fatalf("unexpected error, %v", err)
 	}
+	if d.Type != "cx11" || d.Location != "hel1" {
+		t.Errorf("expected values from profile, but got %v %v", d.Type, d.Location)
+	}
 
-	if d.ImageID != testId {
-		t.Errorf("expected %v id, but got %v", testId, d.ImageArch)
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagConfigFile:   config,
+		flagProfilesFile: file,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.Type != "cpx51" || d.Location != "fsn1" {
+		t.Errorf("expected config file to select and override profile, but got %v %v", d.Type, d.Location)
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagProfile:      "ci-medium",
+		flagProfilesFile: file,
+	}))
+	if err == nil || !strings.Contains(err.Error(), "ci-large, ci-small") {
+		t.Errorf("expected unknown profile to fail listing available profiles, but got %v", err)
 	}
 }
 
-func testArchFlag(t *testing.T, arch hcloud.Architecture) {
+func TestProjects(t *testing.T) {
+	file := t.TempDir() + string(os.PathSeparator) + "projects.yaml"
+	err := os.WriteFile(file, []byte("prod:\n  token: prod-token\nstaging:\n  token: staging-token\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	d := NewDriver("test")
-	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
-		flagImage:     "answer",
-		flagImageArch: string(arch),
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAPIToken:     "",
+		flagProject:      "staging",
+		flagProjectsFile: file,
 	}))
 	if err != nil {
 		t.Fatalf("unexpected error, %v", err)
 	}
+	if d.apiToken() != "staging-token" {
+		t.Errorf("expected token of project, but got %v", d.apiToken())
+	}
 
-	if d.ImageArch != arch {
-		t.Errorf("expected %v architecture, but got %v", arch, d.ImageArch)
+	stored, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewDriver("test")
+	if err = json.Unmarshal(stored, restored); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(stored), "staging-token") || restored.apiToken() != "staging-token" {
+		t.Errorf("expected only the project alias to be stored, but got %v", string(stored))
+	}
+
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagProject:      "staging",
+		flagProjectsFile: file,
+	}))
+	if err == nil {
+		t.Error("expected error for token and project")
+	}
+
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAPIToken:     "",
+		flagProject:      "dev",
+		flagProjectsFile: file,
+	}))
+	if err == nil || !strings.Contains(err.Error(), "prod, staging") {
+		t.Errorf("expected unknown project error, but got %v", err)
 	}
 }
 
-func assertMutualExclusion(t *testing.T, err error, flag1, flag2 string) {
+func TestFailoverProject(t *testing.T) {
+	file := t.TempDir() + string(os.PathSeparator) + "projects.yaml"
+	err := os.WriteFile(file, []byte("overflow:\n  token: overflow-token\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagFailoverProject: "overflow",
+		flagProjectsFile:    file,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+
+	limitErr := fmt.Errorf("could not create server: %w", hcloud.Error{Code: hcloud.ErrorCodeResourceLimitExceeded})
+	if !d.shouldFailOver(limitErr) || d.shouldFailOver(hcloud.Error{Code: hcloud.ErrorCodeInvalidInput}) {
+		t.Error("unexpected failover classification")
+	}
+
+	d.KeyID = 42
+	d.cachedImage, d.cachedFloatingIP, d.ImageID = &hcloud.Image{ID: 1}, &hcloud.FloatingIP{ID: 2}, 1
+	if err = d.failOver(); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.apiToken() != "overflow-token" || d.KeyID != 0 || d.ServerLabels["docker-machine/project"] != "overflow" {
+		t.Errorf("unexpected state after failover: token %v, key %v, labels %v", d.apiToken(), d.KeyID, d.ServerLabels)
+	}
+	if d.cachedImage != nil || d.cachedFloatingIP != nil || d.ImageID != 0 {
+		t.Error("expected resources of the previous project to be resolved again")
+	}
+	if d.shouldFailOver(limitErr) {
+		t.Error("expected failover to happen only once")
+	}
+
+	d.FailoverProject, d.reusePrimaryIPOf = "overflow", "previous"
+	if err = d.failOver(); err == nil || d.FailoverProject != "overflow" {
+		t.Errorf("expected failover reusing primary IPs of the current project to be refused, but got %v", err)
+	}
+	d.reusePrimaryIPOf = ""
+
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagFailoverProject: "overflow",
+		flagExKeyID:         "1",
+		flagExKeyPath:       "/dev/null",
+	}))
 	if err == nil {
-		t.Errorf("expected mutually exclusive flags to fail, but no error was thrown: %v %v", flag1, flag2)
-		return
+		t.Error("expected error for failover project with existing key ID")
 	}
 
-	errstr := err.Error()
-	if !(strings.Contains(errstr, flag1) && strings.Contains(errstr, flag2) && strings.Contains(errstr, "mutually exclusive")) {
-		t.Errorf("expected mutually exclusive flags to fail, but message differs: %v %v %v", flag1, flag2, errstr)
+	for flag, value := range map[string]interface{}{
+		flagNetworks:  []string{"backend", "4711"},
+		flagFirewalls: []string{"42"},
+		flagImageID:   "67794396",
+	} {
+		err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+			flagFailoverProject: "overflow",
+			flagProjectsFile:    file,
+			flag:                value,
+		}))
+		if err == nil || !strings.Contains(err.Error(), flag) {
+			t.Errorf("expected reference by ID in --%v to be rejected, but got %v", flag, err)
+		}
+	}
+}
+
+func TestEnvExpansion(t *testing.T) {
+	t.Setenv("TEST_TEAM", "ops")
+	t.Setenv("TEST_FIREWALL", "web")
+
+	args := map[string]interface{}{
+		flagServerLabel: []string{"team=${TEST_TEAM}"},
+		flagFirewalls:   []string{"${TEST_FIREWALL}-in", "ssh"},
+		flagUserData:    "#!/bin/sh\necho $HOME ${TEST_TEAM}",
+	}
+
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(args))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.ServerLabels["team"] != "${TEST_TEAM}" {
+		t.Errorf("expected no expansion without --%v, but got %v", flagExpandEnv, d.ServerLabels["team"])
+	}
+
+	args[flagExpandEnv] = true
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(args))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.ServerLabels["team"] != "ops" {
+		t.Errorf("expected expanded label, but got %v", d.ServerLabels["team"])
+	}
+	if d.Firewalls[0] != "web-in" || d.Firewalls[1] != "ssh" {
+		t.Errorf("expected expanded firewalls, but got %v", d.Firewalls)
+	}
+	if d.userData != "#!/bin/sh\necho $HOME ops" {
+		t.Errorf("expected only braced references to be expanded, but got %v", d.userData)
+	}
+
+	args[flagPlacementGroup] = "${TEST_UNDEFINED}"
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(args))
+	if err == nil || !strings.Contains(err.Error(), "TEST_UNDEFINED") {
+		t.Errorf("expected undefined variable to fail, but got %v", err)
+	}
+}
+
+func TestHcloudToken(t *testing.T) {
+	t.Setenv(envHcloudToken, "bar")
+
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAPIToken: "",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.AccessToken != "bar" {
+		t.Errorf("expected token from %v, but got %v", envHcloudToken, d.AccessToken)
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(nil))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.AccessToken != "foo" {
+		t.Errorf("expected --%v to take precedence, but got %v", flagAPIToken, d.AccessToken)
+	}
+}
+
+func TestFlagAliases(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		"hetzner-additional-keys": []string{"github:someone"},
+		"hetzner-location":        "fsn1",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if len(d.AdditionalKeys) != 1 || d.AdditionalKeys[0] != "github:someone" {
+		t.Errorf("expected additional keys from alias, but got %v", d.AdditionalKeys)
+	}
+	if d.Location != "fsn1" {
+		t.Errorf("expected location from alias, but got %v", d.Location)
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		"hetzner-location": "fsn1",
+		flagLocation:       "nbg1",
+	}))
+	assertMutualExclusion(t, err, flagLocation, "hetzner-location")
+
+	registered := 0
+	for _, flag := range d.GetCreateFlags() {
+		if _, ok := flagAliases[flag.String()]; ok {
+			registered++
+		}
+	}
+	if registered != len(flagAliases) {
+		t.Errorf("expected all %d aliases to be registered as flags, but got %d", len(flagAliases), registered)
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	types := []string{"cx11", "cx21", "cpx11", "cpx21", "cpx31", "cax11", "ccx13"}
+
+	suggestions := suggest("cpx2", types)
+	if len(suggestions) == 0 || suggestions[0] != "cpx21" {
+		t.Errorf("expected cpx21 as best match, but got %v", suggestions)
+	}
+	if len(suggestions) > maxSuggestions {
+		t.Errorf("expected at most %d suggestions, but got %v", maxSuggestions, suggestions)
+	}
+
+	if msg := didYouMean("FSN", []string{"fsn1", "nbg1", "hel1", "ash", "hil"}); msg != " (did you mean fsn1?)" {
+		t.Errorf("unexpected suggestion %q", msg)
+	}
+
+	if msg := didYouMean("debian-12", []string{"ubuntu-22.04", "rocky-9"}); msg != "" {
+		t.Errorf("expected no suggestions for unrelated names, but got %q", msg)
+	}
+}
+
+func TestMonthlyPrice(t *testing.T) {
+	stype := &hcloud.ServerType{Pricings: []hcloud.ServerTypeLocationPricing{
+		{Location: &hcloud.Location{Name: "fsn1"}, Monthly: hcloud.Price{Gross: "4.5100000000000000"}},
+		{Location: &hcloud.Location{Name: "ash"}, Monthly: hcloud.Price{Gross: "5.3500000000000000"}},
+	}}
+
+	if price := monthlyPrice(stype, ""); price != "4.51" {
+		t.Errorf("expected lowest price 4.51, but got %v", price)
+	}
+	if price := monthlyPrice(stype, "ash"); price != "5.35" {
+		t.Errorf("expected price 5.35 in ash, but got %v", price)
+	}
+	if price := monthlyPrice(stype, "hel1"); price != "" {
+		t.Errorf("expected no price for unavailable location, but got %v", price)
+	}
+}
+
+func TestEngineLabels(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagServerLabel:  []string{"role=worker", "env=prod"},
+		flagEngineLabels: true,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+
+	labels := d.engineLabels(&hcloud.Server{
+		ID:         42,
+		ServerType: &hcloud.ServerType{Name: "cx21"},
+		Datacenter: &hcloud.Datacenter{Name: "fsn1-dc14", Location: &hcloud.Location{Name: "fsn1", NetworkZone: hcloud.NetworkZoneEUCentral}},
+	})
+
+	expected := []string{"env=prod", "role=worker", "hetzner.server-id=42", "hetzner.server-type=cx21",
+		"hetzner.datacenter=fsn1-dc14", "hetzner.location=fsn1", "hetzner.network-zone=eu-central"}
+	if strings.Join(labels, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %v, but got %v", expected, labels)
+	}
+
+	// the labels are merged into the dockerd command line instead of a daemon configuration conflicting with it
+	script := engineLabelsGeneratorScript(labels[:2])
+	if !strings.Contains(script, "labels='--label env=prod --label role=worker'") || strings.Contains(script, "daemon.json") {
+		t.Errorf("unexpected generator script %q", script)
+	}
+}
+
+func TestControllerLabels(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "tcp://manager.example.com:2376")
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagControllerLabels: true,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+
+	if d.ServerLabels["docker-machine/controller-host"] == "" {
+		t.Error("controller hostname label was not set")
+	}
+	if value := d.ServerLabels["docker-machine/controller-docker-host"]; value != "tcp_manager.example.com_2376" {
+		t.Errorf("unexpected controller DOCKER_HOST label %v", value)
+	}
+
+	if value := labelValue(strings.Repeat("a", 62) + "/b"); value != strings.Repeat("a", 62) {
+		t.Errorf("expected truncated label value, but got %v", value)
+	}
+}
+
+func TestMachineKeys(t *testing.T) {
+	keys := []*hcloud.SSHKey{
+		{Name: "runner", Labels: map[string]string{"docker-machine/machine": "runner"}},
+		{Name: "runner-2", Labels: map[string]string{"docker-machine/machine": "runner"}},
+		{Name: "ci-key-runner", Labels: map[string]string{"docker-machine/machine": "runner"}},
+		{Name: "runner-0123456789ab", Labels: map[string]string{"docker-machine/machine": "runner-2"}},
+		{Name: "runner-additional-0"},
+		{Name: "runner-fedcba987654"},
+		{Name: "runner-2-0123456789ab"},
+		{Name: "other"},
+	}
+
+	d := NewDriver("test")
+	matched := d.machineKeys("runner", keys)
+	var names []string
+	for _, key := range matched {
+		names = append(names, key.Name)
+	}
+	if strings.Join(names, ",") != "runner,runner-2,ci-key-runner,runner-additional-0,runner-fedcba987654" {
+		t.Errorf("unexpected keys %v", names)
+	}
+
+	// the key found by fingerprint is only deleted with the machine if it is one of the machine's keys by the same rule
+	if !d.isMachineKey("runner", "runner", &hcloud.SSHKey{Name: "runner"}) {
+		t.Error("expected unlabeled key named like the machine key to belong to the machine")
+	}
+	if d.isMachineKey("runner", "runner", &hcloud.SSHKey{Name: "runner", Labels: map[string]string{"docker-machine/machine": "other"}}) {
+		t.Error("expected key labeled for another machine not to belong to the machine")
+	}
+	if d.isMachineKey("runner", "runner", &hcloud.SSHKey{Name: "deploy"}) {
+		t.Error("expected unlabeled foreign key not to belong to the machine")
+	}
+}
+
+func TestKeyConflict(t *testing.T) {
+	d := NewDriver("test")
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{})); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.keyConflict != keyConflictReuse {
+		t.Errorf("expected default %v, but got %v", keyConflictReuse, d.keyConflict)
+	}
+
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagKeyConflict: keyConflictSuffix})); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.keyConflict != keyConflictSuffix {
+		t.Errorf("expected %v, but got %v", keyConflictSuffix, d.keyConflict)
+	}
+
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagKeyConflict: "replace"})); err == nil {
+		t.Error("expected error for unknown behavior")
+	}
+}
+
+func TestSSHKeyName(t *testing.T) {
+	d := NewDriver("test")
+	d.MachineName = "worker-1"
+	if name, err := d.machineKeyName(); err != nil || name != "worker-1" {
+		t.Errorf("expected key to be named after the machine, but got %v (%v)", name, err)
+	}
+
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagSSHKeyName: "team-{{.Machine}}",
+	}))
+	if err == nil || !strings.Contains(err.Error(), flagSSHKeyName) {
+		t.Errorf("expected unknown template field to be rejected, but got %v", err)
+	}
+
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagSSHKeyName: "team-a-{{.MachineName}}-{{.Location}}",
+		flagLocation:   "fsn1",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if name, err := d.machineKeyName(); err != nil || name != "team-a-worker-1-fsn1" {
+		t.Errorf("expected rendered key name, but got %v (%v)", name, err)
+	}
+}
+
+func TestKeyLimit(t *testing.T) {
+	if err := checkKeyCount(2, 1, 3); err != nil {
+		t.Errorf("expected key to fit, but got %v", err)
+	}
+	if err := checkKeyCount(2, 2, 3); err == nil || !strings.Contains(err.Error(), "2 of 3") {
+		t.Errorf("expected key limit to be exceeded, but got %v", err)
+	}
+
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagKeyLimit: 100}))
+	if err != nil || d.KeyLimit != 100 {
+		t.Errorf("expected key limit 100, but got %v, %v", d.KeyLimit, err)
+	}
+}
+
+func TestAdditionalKeyName(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized := string(ssh.MarshalAuthorizedKey(sshPub))
+
+	name, err := additionalKeyName("runner", authorized)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !isAdditionalKeyName("runner", name) {
+		t.Errorf("unexpected additional key name %v", name)
+	}
+	if again, _ := additionalKeyName("runner", authorized); again != name {
+		t.Errorf("expected deterministic name %v, but got %v", name, again)
+	}
+	if _, err = additionalKeyName("runner", "not a key"); err == nil {
+		t.Error("expected error for invalid key")
+	}
+}
+
+func TestNetworkIPs(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagNetworks: []string{"backend:10.0.1.50", "42", "frontend"},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if strings.Join(d.Networks, ",") != "backend,42,frontend" {
+		t.Errorf("unexpected networks %v", d.Networks)
+	}
+	if len(d.NetworkIPs) != 1 || d.NetworkIPs["backend"] != "10.0.1.50" {
+		t.Errorf("unexpected network IPs %v", d.NetworkIPs)
+	}
+
+	d.forgetNetworkIP(&hcloud.Network{ID: 1, Name: "backend"})
+	if len(d.NetworkIPs) != 0 {
+		t.Errorf("expected network IP to be forgotten, but got %v", d.NetworkIPs)
+	}
+
+	for _, invalid := range []string{"backend:10.0.1", "backend:fd00::1"} {
+		err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagNetworks: []string{invalid}}))
+		if err == nil {
+			t.Errorf("expected error for %v", invalid)
+		}
+	}
+}
+
+func TestApplyFirewalls(t *testing.T) {
+	d := NewDriver("test")
+	for _, refs := range [][2]string{{"", ""}, {"web", "role=web"}} {
+		if err := d.ApplyFirewalls(refs[0], refs[1]); err == nil || !strings.Contains(err.Error(), "exactly one") {
+			t.Errorf("expected firewall %q and selector %q to be rejected, but got %v", refs[0], refs[1], err)
+		}
+	}
+
+	api := newFakeAPI(t, map[string]string{
+		"GET /servers/1":   `{"server": {"id": 1, "name": "test"}}`,
+		"GET /firewalls":   `{"firewalls": [{"id": 2, "name": "web"}, {"id": 3, "name": "ssh"}]}`,
+		"GET /firewalls/2": `{"firewall": {"id": 2, "name": "web"}}`,
+		"POST /firewalls/2/actions/apply_to_resources": `{"actions": [{"id": 1, "status": "running"}]}`,
+		"POST /firewalls/3/actions/apply_to_resources": `{"error": {"code": "firewall_already_applied", "message": "already applied"}}`,
+	})
+
+	// by ID, the firewall is applied and added to the configured ones
+	d = api.driver()
+	d.ServerID, d.Firewalls = 1, []string{"2"}
+	if err := d.ApplyFirewalls("2", ""); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !api.requested("POST /firewalls/2/actions/apply_to_resources") || strings.Join(d.Firewalls, ",") != "web" {
+		t.Errorf("expected firewall web to be applied once and configured, but got %v and %v", api.requests, d.Firewalls)
+	}
+
+	// by selector, firewalls already applied are skipped but configured
+	d = api.driver()
+	d.ServerID = 1
+	if err := d.ApplyFirewalls("", "role=web"); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if strings.Join(d.Firewalls, ",") != "web,ssh" {
+		t.Errorf("expected firewalls web and ssh to be configured, but got %v", d.Firewalls)
+	}
+}
+
+func TestAttachVolume(t *testing.T) {
+	api := newFakeAPI(t, map[string]string{
+		"GET /servers/1":                 `{"server": {"id": 1, "name": "test", "datacenter": {"location": {"name": "fsn1"}}}}`,
+		"GET /volumes/2":                 `{"volume": {"id": 2, "name": "data", "location": {"name": "fsn1"}}}`,
+		"GET /volumes/3":                 `{"volume": {"id": 3, "name": "archive", "location": {"name": "hel1"}}}`,
+		"GET /volumes/4":                 `{"volume": {"id": 4, "name": "shared", "location": {"name": "fsn1"}, "server": 5}}`,
+		"POST /volumes/2/actions/attach": `{"action": {"id": 1, "status": "running"}}`,
+	})
+
+	for _, automount := range []bool{true, false} {
+		d := api.driver()
+		d.ServerID = 1
+		if err := d.AttachVolume("2", automount); err != nil {
+			t.Fatalf("unexpected error, %v", err)
+		}
+		if body := api.bodies["POST /volumes/2/actions/attach"]; !strings.Contains(body, fmt.Sprintf(`"automount":%v`, automount)) {
+			t.Errorf("expected attachment with automount %v, but got %v", automount, body)
+		}
+		if strings.Join(d.Volumes, ",") != "data" {
+			t.Errorf("expected volume data to be configured, but got %v", d.Volumes)
+		}
+	}
+
+	d := api.driver()
+	d.ServerID = 1
+	if err := d.AttachVolume("3", true); err == nil || !strings.Contains(err.Error(), "location hel1") {
+		t.Errorf("expected volume in another location to be refused, but got %v", err)
+	}
+	if err := d.AttachVolume("4", true); err == nil || !strings.Contains(err.Error(), "another server") {
+		t.Errorf("expected volume attached to another server to be refused, but got %v", err)
+	}
+	if len(d.Volumes) != 0 {
+		t.Errorf("expected refused volumes not to be configured, but got %v", d.Volumes)
+	}
+}
+
+func TestWithoutResource(t *testing.T) {
+	refs := withoutResource([]string{"backend", "42", "frontend"}, 42, "backend")
+	if len(refs) != 1 || refs[0] != "frontend" {
+		t.Errorf("expected [frontend], but got %v", refs)
+	}
+}
+
+func TestLabelChanges(t *testing.T) {
+	changes := labelChanges(
+		map[string]string{"owner": "alice", "team": "frontend"},
+		map[string]string{"env": "prod", "team": "web"},
+	)
+	expected := []string{"+ env=prod", "- owner=alice", "~ team=web (was frontend)"}
+	if strings.Join(changes, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %v, but got %v", expected, changes)
+	}
+}
+
+func TestLocationLatency(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagLocation: locationAuto,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.Location != "" || !d.locationAuto || d.locationStrategy != locationStrategyLatency {
+		t.Errorf("expected automatic location by latency, but got %v (strategy %v)", d.Location, d.locationStrategy)
+	}
+
+	serverType := &hcloud.ServerType{ID: 1}
+	locations := availableLocations([]*hcloud.Datacenter{
+		{Location: &hcloud.Location{Name: "fsn1"}, ServerTypes: hcloud.DatacenterServerTypes{Available: []*hcloud.ServerType{{ID: 2}}}},
+		{Location: &hcloud.Location{Name: "nbg1"}, ServerTypes: hcloud.DatacenterServerTypes{Available: []*hcloud.ServerType{{ID: 1}}}},
+		{Location: &hcloud.Location{Name: "nbg1"}, ServerTypes: hcloud.DatacenterServerTypes{Available: []*hcloud.ServerType{{ID: 1}}}},
+		{Location: &hcloud.Location{Name: "hel1"}, ServerTypes: hcloud.DatacenterServerTypes{Available: []*hcloud.ServerType{{ID: 2}, {ID: 1}}}},
+	}, serverType)
+	if strings.Join(locations, ",") != "nbg1,hel1" {
+		t.Errorf("expected locations nbg1,hel1, but got %v", locations)
+	}
+
+	probe := probeLocationLatency
+	defer func() { probeLocationLatency = probe }()
+	probeLocationLatency = func(location string) (time.Duration, error) {
+		switch location {
+		case "nbg1":
+			return 20 * time.Millisecond, nil
+		case "hel1":
+			return 10 * time.Millisecond, nil
+		}
+		return 0, errors.New("unreachable")
+	}
+
+	d.locationCandidates = []string{"fsn1", "nbg1", "hel1"}
+	if err = d.selectLocation(); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.Location != "hel1" {
+		t.Errorf("expected closest location hel1, but got %v", d.Location)
+	}
+
+	d.locationCandidates = []string{"ash", "fsn1"}
+	if err = d.selectLocation(); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.Location != "ash" {
+		t.Errorf("expected fallback to first location ash, but got %v", d.Location)
+	}
+}
+
+func TestLocationList(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagLocation:         "fsn1, nbg1,hel1,nbg1",
+		flagLocationStrategy: locationStrategySpread,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.Location != "" || strings.Join(d.locationCandidates, ",") != "fsn1,nbg1,hel1" {
+		t.Errorf("expected candidates fsn1,nbg1,hel1, but got %v (location %v)", d.locationCandidates, d.Location)
+	}
+
+	d.locationStrategy = locationStrategyRandom
+	if err = d.selectLocation(); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.Location != "fsn1" && d.Location != "nbg1" && d.Location != "hel1" {
+		t.Errorf("expected one of the candidates to be selected, but got %v", d.Location)
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagLocation:         "fsn1,nbg1",
+		flagLocationStrategy: "round-robin",
+	}))
+	if err == nil {
+		t.Error("expected unknown strategy to fail, but no error was thrown")
+	}
+}
+
+func TestAutoSpreadGroup(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAutoSpread:      true,
+		flagAutoSpreadName:  "ci runners",
+		flagAutoSpreadLabel: []string{"team=ci"},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.autoSpreadName != "ci runners" || d.autoSpreadLabels["team"] != "ci" {
+		t.Errorf("unexpected auto-spread group %v %v", d.autoSpreadName, d.autoSpreadLabels)
+	}
+
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagAutoSpreadName: "ci runners"}))
+	if err == nil {
+		t.Error("expected error for auto-spread group name without auto-spread")
+	}
+
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAutoSpread:      true,
+		flagAutoSpreadLabel: []string{"docker-machine/auto-spread=other"},
+	}))
+	if err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Errorf("expected label of the driver's namespace to be rejected, but got %v", err)
+	}
+}
+
+func TestAntiAffinityLabel(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAntiAffinity: "role=manager",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.ServerLabels["role"] != "manager" {
+		t.Errorf("expected anti-affinity label to be assigned, but got %v", d.ServerLabels)
+	}
+	if d.placementGroup != antiAffinityPgName {
+		t.Errorf("expected anti-affinity placement group, but got %v", d.placementGroup)
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAntiAffinity:   "role=manager",
+		flagPlacementGroup: "managers",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.placementGroup != "managers" {
+		t.Errorf("expected explicit placement group to be kept, but got %v", d.placementGroup)
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAntiAffinity: "role=manager",
+		flagServerLabel:  []string{"role=worker"},
+	}))
+	if err == nil {
+		t.Error("expected conflicting server label to fail, but no error was thrown")
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAntiAffinity: "manager",
+	}))
+	if err == nil {
+		t.Error("expected label without value to fail, but no error was thrown")
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	d := NewDriver("1.2.3")
+	d.MachineName = "meta"
+	d.Image = "ubuntu-24.04"
+	d.ServerLabels = map[string]string{"team": "web"}
+
+	if userData, err := d.withGeneratedCloudConfig("#!/bin/sh"); err != nil || userData != "#!/bin/sh" {
+		t.Errorf("expected user data to be unchanged without metadata file, but got %q (%v)", userData, err)
+	}
+
+	d.metadataFile = "/etc/docker-machine-info.json"
+	userData, err := d.withGeneratedCloudConfig("")
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	var config cloudConfig
+	if err := yaml.Unmarshal([]byte(userData), &config); err != nil || !strings.HasPrefix(userData, "#cloud-config\n") {
+		t.Fatalf("expected cloud-config, but got %q (%v)", userData, err)
+	}
+	var metadata machineMetadata
+	if err := json.Unmarshal([]byte(config.WriteFiles[0].Content), &metadata); err != nil {
+		t.Fatal(err)
+	}
+	if metadata.MachineName != "meta" || metadata.DriverVersion != "1.2.3" || metadata.Labels["team"] != "web" {
+		t.Errorf("unexpected metadata %+v", metadata)
+	}
+
+	userData, err = d.withGeneratedCloudConfig("#!/bin/sh\necho hi\n")
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(userData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parts []string
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for part, err := mr.NextPart(); err == nil; part, err = mr.NextPart() {
+		parts = append(parts, part.Header.Get("Content-Type"))
+	}
+	if len(parts) != 2 || !strings.HasPrefix(parts[1], "text/cloud-config") {
+		t.Errorf("expected user data and metadata parts, but got %v", parts)
+	}
+
+	if _, err := d.withGeneratedCloudConfig("Content-Type: multipart/mixed; boundary=x\n"); err == nil {
+		t.Error("expected error for multipart user data")
+	}
+}
+
+func TestUserDataFiles(t *testing.T) {
+	dir := t.TempDir()
+	base, overlay := filepath.Join(dir, "base.yaml"), filepath.Join(dir, "overlay.yaml")
+	for _, file := range []string{base, overlay} {
+		if err := os.WriteFile(file, []byte("#cloud-config\nruncmd:\n  - echo "+filepath.Base(file)+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagUserDataMergeFile: []string{overlay},
+	}))
+	if err == nil {
+		t.Error("expected files to merge without user data to be rejected")
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagUserDataFile:      base,
+		flagUserDataMergeFile: []string{overlay, overlay + ":replace"},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	userData, err := d.getUserData()
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(userData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mergeTypes []string
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for part, err := mr.NextPart(); err == nil; part, err = mr.NextPart() {
+		mergeTypes = append(mergeTypes, part.Header.Get("Merge-Type"))
+	}
+	expected := []string{"", userDataMergeTypes[userDataMergeAppend], userDataMergeTypes[userDataMergeReplace]}
+	if strings.Join(mergeTypes, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected merge types %v, but got %v", expected, mergeTypes)
+	}
+}
+
+func TestAutoShutdownCron(t *testing.T) {
+	for expr, valid := range map[string]bool{
+		"0 20 * * 1-5":  true,
+		"*/15 18 * * *": true,
+		"@daily":        true,
+		"0 20 * *":      false,
+		"0 20 * * 1;rm": false,
+	} {
+		d := NewDriver("test")
+		err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+			flagAutoShutdownCron: expr,
+		}))
+		if valid && err != nil {
+			t.Errorf("unexpected error for %q, %v", expr, err)
+		} else if !valid && err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+
+	d := NewDriver("test")
+	d.autoShutdownCron = "0 20 * * 1-5"
+	config, err := d.generateCloudConfig()
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if len(config.WriteFiles) != 1 || !strings.Contains(config.WriteFiles[0].Content, "0 20 * * 1-5 root shutdown -h now") {
+		t.Errorf("unexpected cloud-config %+v", config)
+	}
+}
+
+func TestSwapSize(t *testing.T) {
+	d := NewDriver("test")
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagSwapSize: "2g"})); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	userData, err := d.withGeneratedCloudConfig("")
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !strings.Contains(userData, "swap:\n    filename: /swapfile\n    size: 2G\n") {
+		t.Errorf("unexpected cloud-config %q", userData)
+	}
+
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagSwapSize: "2 GB"})); err == nil {
+		t.Error("expected error for invalid swap size")
+	}
+}
+
+func TestPackageMirror(t *testing.T) {
+	d := NewDriver("test")
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagPackageMirror: "hetzner"})); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	d.cachedImage = &hcloud.Image{Name: "debian-12", OSFlavor: "debian"}
+	userData, err := d.withGeneratedCloudConfig("")
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !strings.Contains(userData, "uri: https://mirror.hetzner.com/debian/packages\n") ||
+		!strings.Contains(userData, "uri: https://mirror.hetzner.com/debian/security\n") {
+		t.Errorf("unexpected cloud-config %q", userData)
+	}
+
+	d.cachedImage = &hcloud.Image{Name: "rocky-9", OSFlavor: "rocky"}
+	userData, err = d.withGeneratedCloudConfig("")
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !strings.Contains(userData, "path: /etc/dnf/dnf.conf\n") || !strings.Contains(userData, "append: true\n") {
+		t.Errorf("unexpected cloud-config %q", userData)
+	}
+
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagPackageMirror: "mirror.example.com"})); err == nil {
+		t.Error("expected error for mirror without scheme")
+	}
+}
+
+func TestPhoneHome(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagPhoneHomeListen: "127.0.0.1:0"}))
+	if err == nil {
+		t.Error("expected error for listening without URL")
+	}
+
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagPhoneHomeURL:    "http://203.0.113.1:8080/",
+		flagPhoneHomeListen: "127.0.0.1:0",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if err = d.listenPhoneHome(); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	defer d.closePhoneHome()
+
+	userData, err := d.withGeneratedCloudConfig("")
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !strings.Contains(userData, "phone_home:\n    url: http://203.0.113.1:8080/"+d.phoneHome.token+"\n") {
+		t.Errorf("unexpected cloud-config %q", userData)
+	}
+
+	base := "http://" + d.phoneHome.listener.Addr().String() + "/"
+	resp, err := http.PostForm(base+"wrong", map[string][]string{"instance_id": {"1"}})
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected notification with wrong token to be rejected, but got %v", resp.Status)
+	}
+
+	resp, err = http.PostForm(base+d.phoneHome.token, map[string][]string{"instance_id": {"1"}})
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	resp.Body.Close()
+
+	d.WaitForRunningTimeout = 5
+	if !d.waitForPhoneHome() {
+		t.Error("expected phone home to be received")
+	}
+}
+
+func TestNoStartAfterCreate(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagNoStartAfterCreate: true}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !d.startPoweredOff {
+		t.Error("expected server to be created powered off")
+	}
+
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagNoStartAfterCreate: true,
+		flagEngineLabels:       true,
+	}))
+	if err == nil {
+		t.Error("expected error for engine labels on a powered off server")
+	}
+
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagNoStartAfterCreate: true,
+		flagFloatingIP:         "1.2.3.4",
+	}))
+	assertMutualExclusion(t, err, flagNoStartAfterCreate, flagFloatingIP)
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagNoStartAfterCreate: true,
+		flagCreateFloatingIP:   true,
+	}))
+	assertMutualExclusion(t, err, flagNoStartAfterCreate, flagCreateFloatingIP)
+}
+
+func TestReachability(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	addr := listener.Addr().String()
+	if err = probeTCP(addr); err != nil {
+		t.Errorf("expected listening port to be reachable, but got %v", err)
+	}
+	_ = listener.Close()
+	if err = probeTCP(addr); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("expected closed port to refuse connections, but got %v", err)
+	}
+
+	srv := &hcloud.Server{Name: "test", ID: 1, Status: hcloud.ServerStatusStarting}
+	if err = reachabilityError(srv, 22, os.ErrDeadlineExceeded); !strings.Contains(err.Error(), "never booted") {
+		t.Errorf("expected server not to be booted, but got %v", err)
+	}
+	srv.Status = hcloud.ServerStatusRunning
+	srv.PublicNet.Firewalls = []*hcloud.ServerFirewallStatus{{Firewall: hcloud.Firewall{ID: 42}}}
+	if err = reachabilityError(srv, 22, os.ErrDeadlineExceeded); !strings.Contains(err.Error(), "firewalls [42]") {
+		t.Errorf("expected firewall to be named, but got %v", err)
+	}
+
+	d := NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagNoStartAfterCreate:  true,
+		flagReachabilityTimeout: 30,
+	}))
+	assertMutualExclusion(t, err, flagNoStartAfterCreate, flagReachabilityTimeout)
+}
+
+func TestStandbyPool(t *testing.T) {
+	d := NewDriver("test")
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagStandbyPool: "ci"})); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.standbyPool != "ci" {
+		t.Errorf("expected standby pool ci, but got %v", d.standbyPool)
+	}
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagStandbyPool: "ci pool"})); err == nil {
+		t.Error("expected error for invalid pool name")
+	}
+
+	d = NewDriver("test")
+	d.StorePath = standbyStorePath(t.TempDir(), "ci")
+	d.MachineName = "ci-standby-1"
+	if _, locked, err := d.tryLockStandby(); err != nil || locked {
+		t.Errorf("expected standby machine without local state not to be claimable, but got %v, %v", locked, err)
+	}
+
+	if err := os.MkdirAll(d.ResolveStorePath("."), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(d.GetSSHKeyPath(), []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	unlock, locked, err := d.tryLockStandby()
+	if err != nil || !locked {
+		t.Fatalf("expected standby machine to be claimable, but got %v, %v", locked, err)
+	}
+	defer unlock()
+	if _, locked, err = d.tryLockStandby(); err != nil || locked {
+		t.Errorf("expected locked standby machine not to be claimable, but got %v, %v", locked, err)
+	}
+
+	stype := &hcloud.ServerType{ID: 1, Name: "cx22"}
+	image := &hcloud.Image{ID: 2, Name: "ubuntu-24.04"}
+	srv := &hcloud.Server{
+		ServerType: stype,
+		Image:      image,
+		Datacenter: &hcloud.Datacenter{Location: &hcloud.Location{Name: "fsn1"}},
+		Labels:     map[string]string{"docker-machine/standby-config": "abc"},
+	}
+	for location, matches := range map[string]bool{"": true, "fsn1": true, "hel1": false} {
+		d.Location = location
+		if mismatch := d.standbyMismatch(srv, stype, image, "abc"); (mismatch == "") != matches {
+			t.Errorf("expected standby server match in location %q to be %v, but got %q", location, matches, mismatch)
+		}
+	}
+	d.Location = ""
+	if mismatch := d.standbyMismatch(srv, &hcloud.ServerType{ID: 3, Name: "cax11"}, image, "abc"); mismatch == "" {
+		t.Error("expected standby server of another server type not to match")
+	}
+	if mismatch := d.standbyMismatch(srv, stype, &hcloud.Image{ID: 4, Name: "debian-12"}, "abc"); mismatch == "" {
+		t.Error("expected standby server of another image not to match")
+	}
+	if mismatch := d.standbyMismatch(&hcloud.Server{ServerType: stype}, stype, image, "abc"); mismatch == "" {
+		t.Error("expected standby server without image not to match")
+	}
+	if mismatch := d.standbyMismatch(srv, stype, image, "def"); mismatch == "" {
+		t.Error("expected standby server created with other settings not to match")
+	}
+
+	config, err := d.standbyConfig()
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	d.Networks = []string{"backend"}
+	if other, _ := d.standbyConfig(); other == config {
+		t.Error("expected networks to change the standby settings")
+	}
+	d.Networks, d.userData = nil, "#cloud-config"
+	if other, _ := d.standbyConfig(); other == config {
+		t.Error("expected user data to change the standby settings")
+	}
+	d.userData, d.EnableBackup, d.FloatingIP = "", true, "1.2.3.4"
+	if other, _ := d.standbyConfig(); other != config {
+		t.Error("expected settings applied when claiming not to change the standby settings")
+	}
+}
+
+func TestClaimStandbyRollback(t *testing.T) {
+	d := NewDriver("test")
+	config, err := d.standbyConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := newFakeAPI(t, map[string]string{
+		"GET /servers": `{"servers": [{"id": 1, "name": "ci-standby-1", "status": "off",
+			"server_type": {"id": 1, "name": "cx22"}, "image": {"id": 2, "name": "ubuntu-24.04"},
+			"labels": {"docker-machine/standby": "ci", "docker-machine/standby-config": "` + config + `"}}]}`,
+		"GET /ssh_keys":                   `{"ssh_keys": [{"id": 5, "name": "ci-standby-1", "labels": {"docker-machine/machine": "ci-standby-1"}}]}`,
+		"PUT /ssh_keys/5":                 `{"ssh_key": {"id": 5, "name": "runner-1"}}`,
+		"PUT /servers/1":                  `{"server": {"id": 1, "name": "runner-1"}}`,
+		"POST /servers/1/actions/poweron": `{"error": {"code": "locked", "message": "server is locked"}}`,
+	})
+
+	d = api.driver()
+	d.StorePath, d.MachineName, d.standbyPool = t.TempDir(), "runner-1", "ci"
+	d.cachedType, d.cachedImage = &hcloud.ServerType{ID: 1, Name: "cx22"}, &hcloud.Image{ID: 2, Name: "ubuntu-24.04"}
+	d.setMachineLabel()
+	standby := d.standbyMachine("ci-standby-1")
+	for _, path := range []string{standby.GetSSHKeyPath(), d.GetSSHKeyPath()} {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, suffix := range []string{"", ".pub"} {
+		if err := os.WriteFile(standby.GetSSHKeyPath()+suffix, []byte("key"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv, err := d.claimStandby()
+	if err == nil || srv != nil {
+		t.Fatalf("expected failed power on to fail the claim, but got %v, %v", srv, err)
+	}
+	if d.ServerID != 0 || d.KeyID != 0 {
+		t.Errorf("expected standby server and key not to be taken over, but got %d, %d", d.ServerID, d.KeyID)
+	}
+	// the renaming is reverted by a second update of the same resources
+	if body := api.bodies["PUT /servers/1"]; !strings.Contains(body, `"ci-standby-1"`) || !strings.Contains(body, `"docker-machine/standby":"ci"`) {
+		t.Errorf("expected standby server to be restored, but got %v", body)
+	}
+	if body := api.bodies["PUT /ssh_keys/5"]; !strings.Contains(body, `"name":"ci-standby-1"`) || !strings.Contains(body, `"docker-machine/machine":"ci-standby-1"`) {
+		t.Errorf("expected standby ssh key to be restored, but got %v", body)
+	}
+	if _, err := os.Stat(standby.GetSSHKeyPath()); err != nil {
+		t.Errorf("expected standby machine to remain claimable, but got %v", err)
+	}
+}
+
+func TestRecoverBoot(t *testing.T) {
+	for name, tc := range map[string]struct {
+		server   string
+		expected []string
+	}{
+		"rescue enabled": {
+			server:   `{"id": 1, "name": "test", "status": "running", "rescue_enabled": true}`,
+			expected: []string{"POST /servers/1/actions/disable_rescue", "POST /servers/1/actions/reset"},
+		},
+		"ISO attached": {
+			server:   `{"id": 1, "name": "test", "status": "off", "iso": {"id": 2, "name": "ubuntu"}}`,
+			expected: []string{"POST /servers/1/actions/detach_iso", "POST /servers/1/actions/poweron"},
+		},
+		"nothing to revert": {
+			server: `{"id": 1, "name": "test", "status": "running"}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := newFakeAPI(t, map[string]string{
+				"GET /servers/1":                         `{"server": ` + tc.server + `}`,
+				"POST /servers/1/actions/disable_rescue": `{"action": {"id": 1, "status": "running"}}`,
+				"POST /servers/1/actions/detach_iso":     `{"action": {"id": 1, "status": "running"}}`,
+				"POST /servers/1/actions/reset":          `{"action": {"id": 1, "status": "running"}}`,
+				"POST /servers/1/actions/poweron":        `{"action": {"id": 1, "status": "running"}}`,
+			})
+			d := api.driver()
+			d.ServerID = 1
+			if err := d.RecoverBoot(); err != nil {
+				t.Fatalf("unexpected error, %v", err)
+			}
+
+			var actions []string
+			for _, request := range api.requests {
+				if strings.HasPrefix(request, "POST ") {
+					actions = append(actions, request)
+				}
+			}
+			if strings.Join(actions, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("expected actions %v, but got %v", tc.expected, actions)
+			}
+		})
+	}
+}
+
+func TestTrafficUsage(t *testing.T) {
+	for n, expected := range map[uint64]string{
+		512:                    "512 B",
+		1536:                   "1.5 KiB",
+		20 * 1024 * 1024 << 20: "20.0 TiB",
+	} {
+		if formatted := formatBytes(n); formatted != expected {
+			t.Errorf("expected %v to be formatted as %v, but got %v", n, expected, formatted)
+		}
+	}
+
+	srv := &hcloud.Server{IncludedTraffic: 1000, OutgoingTraffic: 850}
+	if percentage := trafficPercentage(srv); percentage != 85 {
+		t.Errorf("expected 85%% traffic used, but got %v", percentage)
+	}
+
+	d := NewDriver("test")
+	d.TrafficWarning = 90
+	d.warnTrafficUsage(srv)
+	if d.trafficWarned {
+		t.Error("expected no warning below threshold")
+	}
+	d.TrafficWarning = 80
+	d.warnTrafficUsage(srv)
+	if !d.trafficWarned {
+		t.Error("expected warning above threshold")
+	}
+}
+
+func TestCloudInitSupport(t *testing.T) {
+	image := &hcloud.Image{Name: "snapshot", Labels: map[string]string{"docker-machine/cloud-init": "false"}}
+
+	d := NewDriver("test")
+	d.userData = "#!/bin/sh\necho hi"
+	d.strictUserData = true
+	if err := d.verifyCloudInitSupport(image); err != nil {
+		t.Errorf("expected script to be accepted, but got %v", err)
+	}
+
+	d.userData = "#cloud-config\npackages: [htop]"
+	if err := d.verifyCloudInitSupport(image); err == nil {
+		t.Error("expected cloud-config to be rejected in strict mode")
+	}
+	if err := d.verifyCloudInitSupport(&hcloud.Image{Name: "ubuntu-24.04"}); err != nil {
+		t.Errorf("expected unlabeled image to be accepted, but got %v", err)
+	}
+
+	d.strictUserData = false
+	if err := d.verifyCloudInitSupport(image); err != nil {
+		t.Errorf("expected only a warning without strict mode, but got %v", err)
+	}
+}
+
+func TestCreationSummaryPrices(t *testing.T) {
+	srv := &hcloud.Server{
+		PublicNet: hcloud.ServerPublicNet{IPv4: hcloud.ServerPublicNetIPv4{IP: net.ParseIP("203.0.113.1")}},
+	}
+	pricing := hcloud.Pricing{
+		Image: hcloud.ImagePricing{PerGBMonth: hcloud.Price{Currency: "EUR"}},
+		ServerTypes: []hcloud.ServerTypePricing{{
+			ServerType: &hcloud.ServerType{Name: "cx22"},
+			Pricings: []hcloud.ServerTypeLocationPricing{
+				{Location: &hcloud.Location{Name: "nbg1"}, Monthly: hcloud.Price{Gross: "5.00"}},
+				{Location: &hcloud.Location{Name: "fsn1"}, Hourly: hcloud.Price{Gross: "0.01"}, Monthly: hcloud.Price{Gross: "4.51"}},
+			},
+		}},
+		PrimaryIPs: []hcloud.PrimaryIPPricing{
+			{Type: "ipv4", Pricings: []hcloud.PrimaryIPTypePricing{{Location: "fsn1", Monthly: hcloud.PrimaryIPPrice{Gross: "0.60"}}}},
+			{Type: "ipv6", Pricings: []hcloud.PrimaryIPTypePricing{{Location: "fsn1", Monthly: hcloud.PrimaryIPPrice{Gross: "0.00"}}}},
+		},
+	}
+
+	summary := &CreationSummary{ServerType: "cx22", Location: "fsn1"}
+	summary.addPrices(pricing, srv)
+	if summary.Currency != "EUR" || summary.HourlyPrice != "0.01" || summary.MonthlyPrice != "4.51" {
+		t.Errorf("unexpected server prices %+v", summary)
+	}
+	if summary.PrimaryIPv4Monthly != "0.60" || summary.PrimaryIPv6Monthly != "" {
+		t.Errorf("unexpected primary IP prices %+v", summary)
+	}
+}
+
+func TestRemovePlan(t *testing.T) {
+	if labels := formatLabels(map[string]string{"b": "2", "a": "1"}); labels != " (a=1, b=2)" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	if labels := formatLabels(nil); labels != "" {
+		t.Errorf("expected no labels, but got %v", labels)
+	}
+
+	d := NewDriver("test")
+	auto := &hcloud.PlacementGroup{Labels: map[string]string{d.labelName(labelAutoCreated): "true"}, Servers: []int64{1}}
+	if !d.isRemovablePlacementGroup(auto) {
+		t.Error("expected auto-created group to be removable")
+	}
+	auto.Servers = append(auto.Servers, 2)
+	if d.isRemovablePlacementGroup(auto) {
+		t.Error("expected group with other servers to be kept")
+	}
+
+	d.Robot = true
+	d.RobotServerNumber = 4711
+	var buf bytes.Buffer
+	if err := d.RemovePlan(&buf); err != nil || !strings.Contains(buf.String(), "dedicated server 4711") {
+		t.Errorf("unexpected plan %v, %v", buf.String(), err)
+	}
+}
+
+func TestRemovalGuard(t *testing.T) {
+	d := NewDriver("test")
+	srv := &hcloud.Server{Name: "test", ID: 1, Labels: map[string]string{"docker-machine/protect": "true"}}
+	if err := d.checkRemovalGuard(srv); err == nil {
+		t.Error("expected server with default guard label to be protected")
+	}
+	d.ForceRemove = true
+	if err := d.checkRemovalGuard(srv); err == nil {
+		t.Errorf("expected --%v not to bypass the guard label", flagForceRemove)
+	}
+	if err := d.checkDeleteProtection(&hcloud.Server{Protection: hcloud.ServerProtection{Delete: true}}); err != nil {
+		t.Errorf("expected --%v to bypass the protection, but got %v", flagForceRemove, err)
+	}
+	d.ForceRemove = false
+	t.Setenv(envForceRemove, "true")
+	if err := d.checkRemovalGuard(srv); err != nil {
+		t.Errorf("expected forced removal to pass, but got %v", err)
+	}
+
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagGuardLabel: "keep"}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.isGuarded(srv) {
+		t.Error("expected default guard label to be replaced")
+	}
+	srv.Labels["keep"] = "anything"
+	if !d.isGuarded(srv) {
+		t.Error("expected guard label key to match any value")
+	}
+}
+
+func TestRequestSlots(t *testing.T) {
+	slots, err := newRequestSlots(t.Name()+strconv.FormatInt(time.Now().UnixNano(), 10), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(slots.dir)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	first, err := slots.acquire(req)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err = slots.acquire(req.WithContext(ctx)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected second request to wait for a free slot, but got %v", err)
+	}
+
+	slots.release(first)
+	second, err := slots.acquire(req)
+	if err != nil {
+		t.Fatalf("expected released slot to be reusable, but got %v", err)
+	}
+	slots.release(second)
+}
+
+func TestMachineLock(t *testing.T) {
+	d := NewDriver("test")
+	d.StorePath = t.TempDir()
+	d.MachineName = "locked"
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+
+	f, err := os.OpenFile(d.ResolveStorePath(machineLockFile), os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if locked, err := tryLockFile(f); locked || err != nil {
+		t.Errorf("expected machine to be locked, but got %v (%v)", locked, err)
+	}
+
+	unlock()
+	if locked, err := tryLockFile(f); !locked || err != nil {
+		t.Errorf("expected machine to be unlocked, but got %v (%v)", locked, err)
+	}
+}
+
+func TestUsageTracking(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RateLimit-Remaining", "3599")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	d := NewDriver("test")
+	httpClient := &http.Client{}
+	d.setupClientUsageTracking(httpClient)
+
+	end := d.trackUsage("Test")
+	usage := d.usage
+	for i := 0; i < 2; i++ {
+		resp, err := httpClient.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	// a nested operation adds to the usage of the outermost one
+	endNested := d.trackUsage("Nested")
+	d.recordRetry()
+	endNested()
+	if d.usage != usage {
+		t.Error("nested operation reset the usage")
+	}
+
+	if summary := d.usage.String(); !strings.HasPrefix(summary, "API usage: 2 calls, 1 retries, rate limit remaining 3599, took ") {
+		t.Errorf("unexpected summary %v", summary)
+	}
+	end()
+	if d.usage != nil {
+		t.Error("usage tracking was not stopped")
+	}
+}
+
+func TestRetryClassification(t *testing.T) {
+	cases := map[error]bool{
+		hcloud.Error{Code: hcloud.ErrorCodeServiceError}:                           true,
+		hcloud.Error{Code: hcloud.ErrorCodeRateLimitExceeded}:                      true,
+		hcloud.Error{Code: hcloud.ErrorCodeInvalidInput}:                           false,
+		hcloud.Error{Code: hcloud.ErrorCodeUniquenessError}:                        false,
+		hcloud.ActionError{Code: "server_error"}:                                   true,
+		hcloud.ActionError{Code: "invalid_input"}:                                  false,
+		fmt.Errorf("wrapped: %w", hcloud.Error{Code: hcloud.ErrorCodeLocked}):      true,
+		&net.OpError{Op: "dial", Err: errors.New("connection refused")}:            true,
+		errors.New("something else"):                                               false,
+		withCorrelationID(hcloud.Error{Code: hcloud.ErrorCodeResourceUnavailable}): true,
+	}
+
+	for err, expected := range cases {
+		if isRetryableError(err) != expected {
+			t.Errorf("expected retryable=%v for %v", expected, err)
+		}
+	}
+
+	d := NewDriver("test")
+	attempts := 0
+	err := d.retry("test", func() error {
+		attempts++
+		return hcloud.Error{Code: hcloud.ErrorCodeInvalidInput}
+	})
+	if err == nil || attempts != 1 {
+		t.Errorf("expected terminal error not to be retried, but got %v after %d attempts", err, attempts)
+	}
+
+	attempts = 0
+	err = d.retry("test", func() error {
+		attempts++
+		if attempts == 1 {
+			return hcloud.Error{Code: hcloud.ErrorCodeConflict}
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Errorf("expected transient error to be retried, but got %v after %d attempts", err, attempts)
+	}
+}
+
+func TestResyncNames(t *testing.T) {
+	names := map[string]string{"1": "alpha", "2": "beta", "alpha": "alpha", "beta": "beta"}
+	resolve := func(idOrName string) (string, error) {
+		return names[idOrName], nil
+	}
+
+	if live, err := resyncNames([]string{"beta", "1"}, []int64{1, 2}, resolve); err != nil || live != nil {
+		t.Errorf("expected no difference, but got %v (%v)", live, err)
+	}
+	if live, err := resyncNames([]string{"alpha"}, []int64{2}, resolve); err != nil || len(live) != 1 || live[0] != "beta" {
+		t.Errorf("expected [beta], but got %v (%v)", live, err)
+	}
+	if live, err := resyncNames([]string{"alpha"}, nil, resolve); err != nil || live == nil || len(live) != 0 {
+		t.Errorf("expected empty difference, but got %v (%v)", live, err)
+	}
+}
+
+func TestVSwitchFlags(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagVSwitchID: "4711",
+	}))
+	if err == nil {
+		t.Fatal("expected error, but vSwitch without network was accepted")
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagNetworks:      []string{"hybrid"},
+		flagVSwitchSubnet: "10.0.1.0/24",
+	}))
+	if err == nil {
+		t.Fatal("expected error, but vSwitch subnet without vSwitch ID was accepted")
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagNetworks:      []string{"hybrid"},
+		flagVSwitchID:     "4711",
+		flagVSwitchSubnet: "10.0.1.0/33",
+	}))
+	if err == nil {
+		t.Fatal("expected error, but invalid vSwitch subnet was accepted")
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagNetworks:      []string{"hybrid"},
+		flagVSwitchID:     "4711",
+		flagVSwitchSubnet: "10.0.1.0/24",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.VSwitchID != 4711 {
+		t.Errorf("expected vSwitch 4711, but got %v", d.VSwitchID)
+	}
+}
+
+func TestImageFlagExclusions(t *testing.T) {
+	// both id and name given
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagImageID: "42",
+		flagImage:   "answer",
+	}))
+	assertMutualExclusion(t, err, flagImageID, flagImage)
+
+	// both id and arch given
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagImageID:   "42",
+		flagImageArch: string(hcloud.ArchitectureX86),
+	}))
+	assertMutualExclusion(t, err, flagImageID, flagImageArch)
+}
+
+func TestNumericIDs(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagImage: "12345"}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.ImageID != 12345 || d.Image != "" {
+		t.Errorf("expected numeric image to be used as ID, but got %v and %v", d.ImageID, d.Image)
+	}
+
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagImage: "12345", flagImageID: "678"}))
+	assertMutualExclusion(t, err, flagImage, flagImageID)
+
+	if err = notFoundError("network", "42"); !strings.Contains(err.Error(), "treated as IDs") {
+		t.Errorf("expected hint for numeric reference, but got %v", err)
+	}
+	if err = notFoundError("network", "net42"); err.Error() != "network 'net42' not found" {
+		t.Errorf("unexpected error for named reference: %v", err)
+	}
+}
+
+func TestImageArch(t *testing.T) {
+	// no explicit arch
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagImage: "answer",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+
+	if d.ImageArch != emptyImageArchitecture {
+		t.Errorf("expected empty architecture, but got %v", d.ImageArch)
+	}
+
+	// existing architectures
+	testArchFlag(t, hcloud.ArchitectureARM)
+	testArchFlag(t, hcloud.ArchitectureX86)
+
+	// invalid
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagImage:     "answer",
+		flagImageArch: "hal9000",
+	}))
+	if err == nil {
+		t.Fatal("expected error, but invalid arch was accepted")
+	}
+}
+
+func TestHcloudAccessors(t *testing.T) {
+	d := NewDriver("test")
+	if d.GetHcloudClient() == nil {
+		t.Error("expected client")
+	}
+
+	d.Robot = true
+	if _, err := d.GetHcloudServer(); err == nil {
+		t.Error("expected error for robot server")
+	}
+}
+
+func TestBogusId(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagImageID: "answer",
+	}))
+	if err == nil {
+		t.Fatal("expected error, but invalid arch was accepted")
+	}
+}
+
+func TestLongId(t *testing.T) {
+	var testId int64 = 79871865169581
+
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagImageID: strconv.FormatInt(testId, 10),
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+
+	if d.ImageID != testId {
+		t.Errorf("expected %v id, but got %v", testId, d.ImageArch)
+	}
+}
+
+func TestAdditionalKeySources(t *testing.T) {
+	first, second := makeTestPublicKey(t), makeTestPublicKey(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/alice.keys" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%v\n\n%v\n", first, second)
+	}))
+	defer srv.Close()
+
+	original := keySourceURLs["github"]
+	keySourceURLs["github"] = srv.URL + "/%s.keys"
+	defer func() { keySourceURLs["github"] = original }()
+
+	literal := makeTestPublicKey(t) + " foo@bar"
+	d := NewDriver("test")
+	d.AdditionalKeys = []string{literal, "github:alice"}
+	keys, err := d.resolveAdditionalKeys()
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+
+	expected := []string{literal, first, second}
+	if strings.Join(keys, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %v, but got %v", expected, keys)
+	}
+
+	d.AdditionalKeys = []string{"github:bob"}
+	if _, err = d.resolveAdditionalKeys(); err == nil {
+		t.Error("expected error for unknown user")
+	}
+
+	if _, _, ok := splitKeySource("codeberg:alice"); ok {
+		t.Error("unknown key source was accepted")
+	}
+}
+
+func TestAdditionalKeyParsing(t *testing.T) {
+	first, second := makeTestPublicKey(t), makeTestPublicKey(t)
+
+	file := t.TempDir() + string(os.PathSeparator) + "keys"
+	err := os.WriteFile(file, []byte("# team keys\n"+first+" alice\n\n"+second+" bob # laptop\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDriver("test")
+	d.AdditionalKeys = []string{file, first + "\n" + second}
+	keys, err := d.resolveAdditionalKeys()
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if len(keys) != 4 {
+		t.Errorf("expected 4 keys, but got %v", keys)
+	}
+
+	d.AdditionalKeys = []string{first, first + "\nssh-ed25519 garbage"}
+	_, err = d.resolveAdditionalKeys()
+	if err == nil {
+		t.Fatal("expected error, but invalid key was accepted")
+	}
+	if !strings.Contains(err.Error(), "additional key #2 of 2, line 2") {
+		t.Errorf("error does not point to malformed entry: %v", err)
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAdditionalKeys: []string{"github:alice", file, "ssh-rsa garbage"},
+	}))
+	if err == nil {
+		t.Fatal("expected error, but invalid key was accepted")
+	}
+	if !strings.Contains(err.Error(), "additional key #3 of 3, line 1") {
+		t.Errorf("error does not point to malformed entry: %v", err)
+	}
+}
+
+func TestAdditionalKeyFingerprints(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAdditionalKeyFPs: []string{
+			"d7:2c:5c:43:4b:c7:2f:5f:0b:63:24:c8:60:9a:6c:5c",
+			"MD5:D7:2C:5C:43:4B:C7:2F:5F:0B:63:24:C8:60:9A:6C:5C",
+			"SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8",
+		},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAdditionalKeyFPs: []string{"d7:2c:5c"},
+	}))
+	if err == nil {
+		t.Fatal("expected error, but invalid fingerprint was accepted")
+	}
+}
+
+func TestSSHAgentKey(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagSSHAgentKey: "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8",
+		flagExKeyPath:   "/tmp/foo",
+	}))
+	assertMutualExclusion(t, err, flagSSHAgentKey, flagExKeyPath)
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagSSHAgentKey: "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.GetSSHKeyPath() != "" {
+		t.Errorf("expected no key path in ssh-agent mode, but got %v", d.GetSSHKeyPath())
+	}
+
+	pubk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(makeTestPublicKey(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matchesFingerprint(pubk, ssh.FingerprintSHA256(pubk)) {
+		t.Error("SHA256 fingerprint did not match")
+	}
+	if !matchesFingerprint(pubk, "MD5:"+strings.ToUpper(ssh.FingerprintLegacyMD5(pubk))) {
+		t.Error("MD5 fingerprint did not match")
+	}
+}
+
+func TestSecurityKeys(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire := ssh.Marshal(struct {
+		Name        string
+		KeyBytes    []byte
+		Application string
+	}{ssh.KeyAlgoSKED25519, pub, "ssh:"})
+	skKey, err := ssh.ParsePublicKey(wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(skKey))) + " yubikey"
+
+	d := NewDriver("test")
+	d.AdditionalKeys = []string{authorized}
+	if _, err := d.resolveAdditionalKeys(); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+
+	if !isSecurityKey(skKey) {
+		t.Error("expected key to be detected as security key")
+	}
+	if !remoteKeyMatches(&hcloud.SSHKey{Fingerprint: "unrelated", PublicKey: authorized}, skKey) {
+		t.Error("expected remote key with identical key material to match")
+	}
+	if remoteKeyMatches(&hcloud.SSHKey{Fingerprint: "unrelated", PublicKey: makeTestPublicKey(t)}, skKey) {
+		t.Error("remote key with different key material matched")
+	}
+}
+
+func TestPayloadRedaction(t *testing.T) {
+	raw := redactJSON([]byte(`{"name":"m","user_data":"#cloud-config","nested":[{"RobotPassword":"secret"}]}`))
+	if strings.Contains(string(raw), "cloud-config") || strings.Contains(string(raw), "secret\"") || !strings.Contains(string(raw), `"name":"m"`) {
+		t.Errorf("unexpected redacted JSON %v", string(raw))
+	}
+
+	dump := redactPayload("Authorization: Bearer abc123\n\n" + `{"name":"m","user_data":"#!/bin/sh\necho \"hi\""}`)
+	if strings.Contains(dump, "abc123") || strings.Contains(dump, "echo") || !strings.Contains(dump, `"name":"m"`) {
+		t.Errorf("unexpected redacted dump %v", dump)
+	}
+}
+
+func TestFailedActionError(t *testing.T) {
+	action := &hcloud.Action{
+		ID:           42,
+		Command:      "attach_volume",
+		Status:       hcloud.ActionStatusError,
+		ErrorCode:    "volume_limit_exceeded",
+		ErrorMessage: "too many volumes",
+		Resources: []*hcloud.ActionResource{
+			{ID: 1, Type: hcloud.ActionResourceTypeServer},
+			{ID: 7, Type: hcloud.ActionResourceTypeVolume},
+		},
+	}
+	err := failedActionError(action, func(res *hcloud.ActionResource) string {
+		return fmt.Sprintf("data[%d]", res.ID)
+	})
+
+	expected := "attach_volume[42] of volume data[7] failed: too many volumes (volume_limit_exceeded)"
+	if err.Error() != expected {
+		t.Errorf("expected %q, but got %q", expected, err.Error())
+	}
+	var actionErr hcloud.ActionError
+	if !errors.As(err, &actionErr) || actionErr.Code != "volume_limit_exceeded" {
+		t.Error("error does not unwrap to the action error")
+	}
+}
+
+func TestNetworkAttachConflict(t *testing.T) {
+	locked := fmt.Errorf("could not attach: %w", hcloud.Error{Code: hcloud.ErrorCodeLocked})
+	taken := hcloud.ActionError{Code: string(hcloud.ErrorCodeIPNotAvailable)}
+
+	if !isNetworkAttachConflict(locked, true) {
+		t.Error("expected locked network to be retried")
+	}
+	if !isNetworkAttachConflict(taken, false) {
+		t.Error("expected taken automatic IP to be retried")
+	}
+	if isNetworkAttachConflict(taken, true) {
+		t.Error("expected taken static IP not to be retried")
+	}
+	if isNetworkAttachConflict(hcloud.Error{Code: hcloud.ErrorCodeNotFound}, false) {
+		t.Error("expected unknown network not to be retried")
+	}
+}
+
+func TestActionTimeout(t *testing.T) {
+	d := NewDriver("test")
+	ctx, cancel := d.actionContext()
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("expected no deadline without action timeout")
+	}
+
+	d.ActionTimeout = 30
+	ctx, cancel = d.actionContext()
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > 30*time.Second {
+		t.Errorf("expected deadline within 30 seconds, but got %v", deadline)
+	}
+}
+
+func TestCorrelationID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(correlationIDHeader, "d41d8cd98f00b204")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = fmt.Fprint(w, `{"error": {"code": "uniqueness_error", "message": "SSH key not unique"}}`)
+	}))
+	defer srv.Close()
+
+	client := hcloud.NewClient(hcloud.WithEndpoint(srv.URL), hcloud.WithToken("foo"))
+	_, _, err := client.SSHKey.Create(context.Background(), hcloud.SSHKeyCreateOpts{Name: "foo", PublicKey: "bar"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	wrapped := withCorrelationID(fmt.Errorf("could not create ssh key: %w", err))
+	if !strings.HasSuffix(wrapped.Error(), "(correlation ID: d41d8cd98f00b204)") {
+		t.Errorf("correlation ID missing from error: %v", wrapped)
+	}
+	var apiErr hcloud.Error
+	if !errors.As(wrapped, &apiErr) || apiErr.Code != hcloud.ErrorCodeUniquenessError {
+		t.Error("wrapped error does not unwrap to the API error")
+	}
+	if withCorrelationID(wrapped).Error() != wrapped.Error() {
+		t.Error("correlation ID was added twice")
+	}
+}
+
+func TestReadOnlyTokenHint(t *testing.T) {
+	forbidden := fmt.Errorf("could not create ssh key: %w", hcloud.Error{Code: hcloud.ErrorCodeForbidden, Message: "forbidden"})
+	if msg := withTokenHint(forbidden).Error(); !strings.Contains(msg, "read-only") {
+		t.Errorf("expected read-only hint, but got %v", msg)
+	}
+
+	notFound := hcloud.Error{Code: hcloud.ErrorCodeNotFound, Message: "not found"}
+	if msg := withTokenHint(notFound).Error(); strings.Contains(msg, "read-only") {
+		t.Errorf("unexpected read-only hint for %v", msg)
+	}
+
+	if withTokenHint(errReadOnlyToken) != errReadOnlyToken {
+		t.Error("read-only error was amended with hint")
+	}
+}
+
+func makeTestPublicKey(t *testing.T) string {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+}
+
+func testArchFlag(t *testing.T, arch hcloud.Architecture) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagImage:     "answer",
+		flagImageArch: string(arch),
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+
+	if d.ImageArch != arch {
+		t.Errorf("expected %v architecture, but got %v", arch, d.ImageArch)
+	}
+}
+
+func assertMutualExclusion(t *testing.T, err error, flag1, flag2 string) {
+	if err == nil {
+		t.Errorf("expected mutually exclusive flags to fail, but no error was thrown: %v %v", flag1, flag2)
+		return
+	}
+
+	errstr := err.Error()
+	if !(strings.Contains(errstr, flag1) && strings.Contains(errstr, flag2) && strings.Contains(errstr, "mutually exclusive")) {
+		t.Errorf("expected mutually exclusive flags to fail, but message differs: %v %v %v", flag1, flag2, errstr)
+	}
+}
+
+func TestInstanceGroup(t *testing.T) {
+	configure := func(d *Driver) error {
+		return d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagAPIToken: "foo"}))
+	}
+	if _, err := NewInstanceGroup("ci pool", t.TempDir(), "test", configure); err == nil {
+		t.Error("expected error for invalid group name")
+	}
+	if _, err := NewInstanceGroup("ci", t.TempDir(), "test", func(d *Driver) error {
+		return d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagDisablePublic4: true, flagDisablePublic6: true}))
+	}); err == nil {
+		t.Error("expected configuration error")
+	}
+
+	g, err := NewInstanceGroup("ci", t.TempDir(), "test", configure)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if g.labelSelector() != "docker-machine/instance-group=ci" {
+		t.Errorf("unexpected label selector %v", g.labelSelector())
+	}
+	if _, err = g.Driver("ci-1"); err == nil {
+		t.Error("expected error for unknown instance")
+	}
+
+	d := g.newDriver("ci-1")
+	d.ServerID = 42
+	if err = os.MkdirAll(d.ResolveStorePath("."), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err = g.save(d); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	loaded, err := g.Driver("ci-1")
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if loaded.ServerID != 42 || loaded.MachineName != "ci-1" {
+		t.Errorf("unexpected instance driver %v[%d]", loaded.MachineName, loaded.ServerID)
+	}
+}
+
+func TestNetworkIPStrategy(t *testing.T) {
+	d := NewDriver("test")
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagNetworkIPStrategy: "fancy"})); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagNetworkIPStrategy: ipStrategyWebhook})); err == nil {
+		t.Error("expected error for webhook strategy without URL")
+	}
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagNetworkIPWebhook: "https://ipam.example.com"})); err == nil {
+		t.Error("expected error for webhook URL without webhook strategy")
+	}
+
+	_, ipRange, _ := net.ParseCIDR("10.0.0.0/16")
+	_, subnet, _ := net.ParseCIDR("10.0.1.0/29")
+	network := &hcloud.Network{ID: 1, Name: "backend", IPRange: ipRange, Subnets: []hcloud.NetworkSubnet{
+		{Type: hcloud.NetworkSubnetTypeVSwitch, IPRange: ipRange},
+		{Type: hcloud.NetworkSubnetTypeCloud, IPRange: subnet, Gateway: net.ParseIP("10.0.0.1")},
+	}}
+	used := map[string]bool{"10.0.1.1": true, "10.0.1.2": true}
+
+	ip, err := sequentialIPAllocator{}.allocateIP("m", network, used)
+	if err != nil || ip.String() != "10.0.1.3" {
+		t.Errorf("expected 10.0.1.3, but got %v, %v", ip, err)
+	}
+	for i := 0; i < 20; i++ {
+		ip, err = randomIPAllocator{}.allocateIP("m", network, used)
+		if err != nil || !subnet.Contains(ip) || used[ip.String()] || ip.String() == "10.0.1.0" || ip.String() == "10.0.1.7" {
+			t.Fatalf("unexpected random IP %v, %v", ip, err)
+		}
+	}
+	for n := 3; n <= 6; n++ {
+		used["10.0.1."+strconv.Itoa(n)] = true
+	}
+	if ip, err = (sequentialIPAllocator{}).allocateIP("m", network, used); err == nil {
+		t.Errorf("expected error for exhausted subnet, but got %v", ip)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ipWebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Machine != "m" || req.Network != "backend" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ip": "10.0.2.10"}`))
+	}))
+	defer srv.Close()
+	ip, err = webhookIPAllocator{url: srv.URL}.allocateIP("m", network, used)
+	if err != nil || ip.String() != "10.0.2.10" {
+		t.Errorf("expected 10.0.2.10, but got %v, %v", ip, err)
+	}
+	if _, err = (webhookIPAllocator{url: srv.URL}).allocateIP("other", network, used); err == nil {
+		t.Error("expected error for failed webhook request")
+	}
+
+	// allocations are attached like static IPs, but neither stored nor exported, so clones do not collide on them
+	d = NewDriver("test")
+	d.Networks, d.allocatedIPs = []string{"backend"}, map[string]string{"backend": "10.0.1.2"}
+	if d.networkIP("backend") != "10.0.1.2" || !d.hasNetworkIPs() {
+		t.Errorf("expected allocated IP to be attached, but got %q", d.networkIP("backend"))
+	}
+	if networks := d.exportedFlags()["networks"]; len(networks.([]string)) != 1 || networks.([]string)[0] != "backend" {
+		t.Errorf("expected network to be exported without allocated IP, but got %v", networks)
+	}
+	if len(d.NetworkIPs) != 0 {
+		t.Errorf("expected allocation not to be stored as static IP, but got %v", d.NetworkIPs)
+	}
+}
+
+func TestPlacementGroupMove(t *testing.T) {
+	a, b := &hcloud.PlacementGroup{ID: 1}, &hcloud.PlacementGroup{ID: 2}
+	if needsPlacementGroupMove(nil, nil) || needsPlacementGroupMove(a, &hcloud.PlacementGroup{ID: 1}) {
+		t.Error("expected no move within the same placement group")
+	}
+	if !needsPlacementGroupMove(a, b) || !needsPlacementGroupMove(nil, b) || !needsPlacementGroupMove(a, nil) {
+		t.Error("expected move between different placement groups")
+	}
+
+	d := NewDriver("test")
+	d.Robot = true
+	if err := d.MovePlacementGroup("spread"); err == nil {
+		t.Error("expected error for robot server")
+	}
+}
+
+func TestIncompleteCleanupError(t *testing.T) {
+	cause := errors.New("locked")
+	var err error = &IncompleteCleanupError{Keys: []KeyCleanupFailure{{ID: 42, Name: "my-machine", Err: cause}}}
+	err = fmt.Errorf("remove failed: %w", err)
+
+	var cleanupErr *IncompleteCleanupError
+	if !errors.As(err, &cleanupErr) || len(cleanupErr.Keys) != 1 {
+		t.Fatalf("expected incomplete cleanup error, but got %v", err)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("expected error to wrap the key deletion error")
+	}
+	if !strings.Contains(err.Error(), "my-machine[42]: locked") {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+}
+
+func TestRemoveMachineKeys(t *testing.T) {
+	deleted := make(map[string]bool)
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodGet || r.URL.Path == "/ssh_keys/2" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		switch {
+		case r.Method == http.MethodGet && !deleted[r.URL.Path]:
+			_, _ = fmt.Fprintf(w, `{"ssh_key": {"id": %v, "name": "key"}}`, strings.TrimPrefix(r.URL.Path, "/ssh_keys/"))
+		case r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"error": {"code": "not_found", "message": "not found"}}`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/ssh_keys/2":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = fmt.Fprint(w, `{"error": {"code": "forbidden", "message": "insufficient permissions"}}`)
+		case r.Method == http.MethodDelete:
+			deleted[r.URL.Path] = true
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	d := NewDriver("test")
+	d.AccessToken, d.endpoint = "foo", srv.URL
+	d.KeyID = 1
+
+	// a verified deletion needs no further round
+	if err := d.removeMachineKeys(); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	expected := "GET /ssh_keys/1,DELETE /ssh_keys/1,GET /ssh_keys/1"
+	if strings.Join(requests, ",") != expected {
+		t.Errorf("expected requests %v, but got %v", expected, requests)
+	}
+
+	// only keys whose deletion failed are returned for another round
+	failures := make(map[int64]error)
+	remaining := d.deleteKeys([]int64{1, 2, 3}, make(map[int64]string), failures)
+	if len(remaining) != 1 || remaining[0] != 2 || !hcloud.IsError(failures[2], hcloud.ErrorCodeForbidden) {
+		t.Errorf("expected only key 2 to remain, but got %v, %v", remaining, failures)
+	}
+}
+
+func TestDestroyDangling(t *testing.T) {
+	d := NewDriver("test")
+	cause := errors.New("locked")
+	var destroyed []string
+	d.trackDangling("ssh key", func() error { destroyed = append(destroyed, "ssh key"); return nil })
+	d.trackDangling("primary IP", func() error { destroyed = append(destroyed, "primary IP"); return cause })
+
+	err := d.destroyDangling()
+	if len(destroyed) != 2 {
+		t.Errorf("expected all dangling resources to be destroyed, but got %v", destroyed)
+	}
+	var leaked *LeakedResourcesError
+	if !errors.As(errors.Join(errors.New("create failed"), err), &leaked) || len(leaked.Resources) != 1 {
+		t.Fatalf("expected leaked resources error, but got %v", err)
+	}
+	if leaked.Resources[0].Kind != "primary IP" || !errors.Is(err, cause) {
+		t.Errorf("unexpected leaked resources %v", leaked.Resources)
+	}
+	if d.cleaning {
+		t.Error("expected cleanup to be finished")
+	}
+
+	d.dangling = nil
+	d.trackDangling("ssh key", func() error { return nil })
+	if err = d.destroyDangling(); err != nil {
+		t.Errorf("expected clean failure, but got %v", err)
+	}
+}
+
+func TestActionHistory(t *testing.T) {
+	started := time.Date(2024, 5, 2, 3, 12, 45, 0, time.UTC)
+	var buf bytes.Buffer
+	err := writeActionHistory(&buf, []*hcloud.Action{
+		{ID: 2, Command: "reboot_server", Status: hcloud.ActionStatusRunning, Started: started},
+		{ID: 1, Command: "attach_volume", Status: hcloud.ActionStatusError, Started: started, Finished: started.Add(3 * time.Second),
+			ErrorCode: "locked", ErrorMessage: "server is locked"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "reboot_server  running  2024-05-02T03:12:45Z  -") ||
+		!strings.Contains(out, "3s        locked: server is locked") {
+		t.Errorf("unexpected action history %q", out)
+	}
+
+	d := NewDriver("test")
+	if err = d.ActionHistory(&buf, 0); err == nil {
+		t.Error("expected error for invalid number of actions")
+	}
+
+	api := newFakeAPI(t, map[string]string{
+		"GET /servers/1": `{"server": {"id": 1, "name": "test"}}`,
+		"GET /servers/actions": `{"actions": [
+			{"id": 5, "command": "reboot_server", "status": "success", "resources": [{"id": 2, "type": "server"}]},
+			{"id": 4, "command": "attach_volume", "status": "success", "resources": [{"id": 1, "type": "server"}, {"id": 9, "type": "volume"}]},
+			{"id": 3, "command": "create_server", "status": "success", "resources": [{"id": 1, "type": "server"}]}
+		], "meta": {"pagination": {"page": 1, "per_page": 50, "next_page": null}}}`,
+	})
+	d = api.driver()
+	d.ServerID = 1
+	buf.Reset()
+	if err = d.ActionHistory(&buf, 1); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	out = buf.String()
+	if !strings.Contains(out, "attach_volume") || strings.Contains(out, "reboot_server") || strings.Contains(out, "create_server") {
+		t.Errorf("expected only the latest action of the server, but got %q", out)
+	}
+}
+
+func TestPinImage(t *testing.T) {
+	api := newFakeAPI(t, map[string]string{
+		"GET /images": `{"images": [{"id": 42, "name": "ubuntu-24.04", "type": "system", "architecture": "x86"}]}`,
+		"GET /images/43": `{"image": {"id": 43, "name": "", "description": "golden", "type": "snapshot",
+			"architecture": "x86"}}`,
+	})
+
+	// an image resolved by name is pinned to its ID
+	d := api.driver()
+	d.Image, d.ImageArch = "ubuntu-24.04", hcloud.ArchitectureX86
+	if _, err := d.getImage(); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.ImageID != 42 || d.Image != "ubuntu-24.04" {
+		t.Errorf("expected image to be pinned to ID 42, but got %v[%d]", d.Image, d.ImageID)
+	}
+
+	// an image given by ID is used as is
+	d = api.driver()
+	d.ImageID = 43
+	if _, err := d.getImage(); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.ImageID != 43 || d.Image != "" || d.ImageArch != emptyImageArchitecture {
+		t.Errorf("expected image given by ID not to be pinned, but got %v[%d] (%v)", d.Image, d.ImageID, d.ImageArch)
+	}
+}
+
+func TestParseServerLabelFlag(t *testing.T) {
+	d := NewDriver("test")
+	d.ServerLabels = map[string]string{"role": "web"}
+	if key, value, err := d.parseServerLabelFlag(flagFirewallLabel, "team=ci"); err != nil || key != "team" || value != "ci" {
+		t.Errorf("unexpected label %v=%v, %v", key, value, err)
+	}
+	if _, _, err := d.parseServerLabelFlag(flagFirewallLabel, "role=web"); err != nil {
+		t.Errorf("expected label equal to a server label to be accepted, but got %v", err)
+	}
+	for _, label := range []string{"team", "=ci", "team=c i", "role=db"} {
+		if _, _, err := d.parseServerLabelFlag(flagLoadBalancerLabel, label); err == nil || !strings.Contains(err.Error(), flagLoadBalancerLabel) {
+			t.Errorf("expected label %q to be rejected naming the flag, but got %v", label, err)
+		}
+	}
+}
+
+func TestFirewallLabel(t *testing.T) {
+	d := NewDriver("test")
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagFirewallLabel: "role=ci"})); err == nil {
+		t.Error("expected error for firewall label without firewalls")
+	}
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagFirewalls:     []string{"ci"},
+		flagFirewallLabel: "role",
+	})); err == nil {
+		t.Error("expected error for firewall label without value")
+	}
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagFirewalls:     []string{"ci"},
+		flagFirewallLabel: "role=ci",
+		flagServerLabel:   []string{"role=web"},
+	})); err == nil {
+		t.Error("expected error for firewall label conflicting with server label")
+	}
+
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagFirewalls:     []string{"ci"},
+		flagFirewallLabel: "role=ci",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.ServerLabels["role"] != "ci" {
+		t.Errorf("expected server label role=ci, but got %v", d.ServerLabels)
+	}
+
+	firewall := &hcloud.Firewall{AppliedTo: []hcloud.FirewallResource{
+		{Type: hcloud.FirewallResourceTypeServer, Server: &hcloud.FirewallResourceServer{ID: 1}},
+		{Type: hcloud.FirewallResourceTypeLabelSelector, LabelSelector: &hcloud.FirewallResourceLabelSelector{Selector: "role=ci"}},
+	}}
+	if !hasLabelSelectorResource(firewall, "role=ci") || hasLabelSelectorResource(firewall, "role=web") {
+		t.Error("unexpected label selector resources")
+	}
+}
+
+func TestValidateFlags(t *testing.T) {
+	d := NewDriver("test")
+	t.Setenv(envHcloudToken, "")
+	if err := d.ValidateFlags(&commandstest.FakeFlagger{Data: map[string]interface{}{flagImage: "ubuntu-24.04"}}); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if err := d.ValidateFlags(&commandstest.FakeFlagger{Data: map[string]interface{}{
+		flagProject:      "staging",
+		flagProjectsFile: filepath.Join(t.TempDir(), "missing.yaml"),
+	}}); err != nil {
+		t.Fatalf("expected projects file not to be read, but got %v", err)
+	}
+	if err := d.ValidateFlags(&commandstest.FakeFlagger{Data: map[string]interface{}{
+		flagImage:   "ubuntu-24.04",
+		flagImageID: "42",
+	}}); err == nil {
+		t.Error("expected error for mutually exclusive flags")
+	}
+
+	if err := d.SetConfigFromFlags(&commandstest.FakeFlagger{Data: map[string]interface{}{}}); err == nil {
+		t.Error("expected token to be required outside of validation")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	d := NewDriver("test")
+	if err := d.interrupted(); err != nil {
+		t.Fatalf("unexpected interruption before shutdown, %v", err)
+	}
+
+	var opErr error
+	finish := d.operation("Create")
+	if d.Shutdown(10 * time.Millisecond) {
+		t.Error("expected shutdown to wait for the in-flight operation")
+	}
+	if err := d.interrupted(); !errors.Is(err, errShutdown) {
+		t.Errorf("expected shutdown error, but got %v", err)
+	}
+	ctx, cancel := d.actionContext()
+	if ctx.Err() == nil {
+		t.Error("expected action context to be cancelled on shutdown")
+	}
+	cancel()
+
+	done := d.cleanup()
+	if err := d.interrupted(); err != nil {
+		t.Errorf("expected cleanup not to be interrupted, but got %v", err)
+	}
+	if ctx, cancel := d.actionContext(); ctx.Err() != nil {
+		t.Error("expected action context of cleanup not to be cancelled")
+	} else {
+		cancel()
+	}
+	done()
+
+	finish(&opErr)
+	if !d.Shutdown(time.Second) {
+		t.Error("expected shutdown to complete once the operation finished")
+	}
+}
+
+func TestInventory(t *testing.T) {
+	_, ipv6Net, _ := net.ParseCIDR("2001:db8::/64")
+	srv := &hcloud.Server{
+		ID:     4711,
+		Name:   "runner",
+		Status: hcloud.ServerStatusRunning,
+		PublicNet: hcloud.ServerPublicNet{
+			IPv4: hcloud.ServerPublicNetIPv4{ID: 815, IP: net.ParseIP("203.0.113.1")},
+			IPv6: hcloud.ServerPublicNetIPv6{ID: 816, IP: ipv6Net.IP, Network: ipv6Net},
+		},
+		PrivateNet: []hcloud.ServerPrivateNet{{Network: &hcloud.Network{ID: 42}, IP: net.ParseIP("10.0.0.2")}},
+	}
+
+	entry := inventoryServer(srv, map[int64]string{42: "internal"}, map[int64]string{815: "runner-v4"})
+	if entry.PublicIPv4 != "203.0.113.1" || entry.PublicIPv6 != "2001:db8::/64" {
+		t.Errorf("unexpected public IPs %v and %v", entry.PublicIPv4, entry.PublicIPv6)
+	}
+	if len(entry.PrimaryIPs) != 2 || entry.PrimaryIPs[0].Name != "runner-v4" || entry.PrimaryIPs[1].Type != "ipv6" {
+		t.Errorf("unexpected primary IPs %+v", entry.PrimaryIPs)
+	}
+	if len(entry.Networks) != 1 || entry.Networks[0].Name != "internal" || entry.Networks[0].IP != "10.0.0.2" {
+		t.Errorf("unexpected networks %+v", entry.Networks)
+	}
+	if entry.Labels == nil {
+		t.Error("expected labels to be serialized as an empty object")
+	}
+
+	d := NewDriver("test")
+	d.MachineName = "runner"
+	d.setMachineLabel()
+	if d.ServerLabels["docker-machine/machine"] != "runner" {
+		t.Errorf("expected server to be labeled with the machine name, but got %v", d.ServerLabels)
+	}
+
+	// without a selector, the servers created by the driver are listed
+	var selector string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/servers":
+			selector = r.URL.Query().Get("label_selector")
+			_, _ = fmt.Fprint(w, `{"servers": []}`)
+		case "/networks":
+			_, _ = fmt.Fprint(w, `{"networks": []}`)
+		case "/primary_ips":
+			_, _ = fmt.Fprint(w, `{"primary_ips": []}`)
+		default:
+			t.Errorf("unexpected request %v %v", r.Method, r.URL)
+		}
+	}))
+	defer api.Close()
+	d.AccessToken, d.endpoint = "foo", api.URL
+	var buf bytes.Buffer
+	if err := d.Inventory(&buf, ""); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if selector != "docker-machine/machine" {
+		t.Errorf("expected servers to be selected by the machine label, but got %q", selector)
+	}
+}
+
+func TestImageArchitectureMismatchError(t *testing.T) {
+	err := imageArchitectureMismatchError("my-snapshot", "cax11", hcloud.ArchitectureARM, hcloud.ArchitectureX86)
+	for _, part := range []string{"my-snapshot", "architecture arm of server type cax11", "only for x86", "--hetzner-image-arch x86"} {
+		if !strings.Contains(err.Error(), part) {
+			t.Errorf("expected error %q to contain %q", err.Error(), part)
+		}
+	}
+}
+
+func TestFloatingIP(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagFloatingIP:        "web",
+		flagUsePrivateNetwork: true,
+	}))
+	assertMutualExclusion(t, err, flagFloatingIP, flagUsePrivateNetwork)
+
+	_, ipv6Net, _ := net.ParseCIDR("2001:db8:1::/64")
+	ipv4 := &hcloud.FloatingIP{Type: hcloud.FloatingIPTypeIPv4, IP: net.ParseIP("203.0.113.7")}
+	ipv6 := &hcloud.FloatingIP{Type: hcloud.FloatingIPTypeIPv6, IP: ipv6Net.IP, Network: ipv6Net}
+	if !floatingIPMatches(ipv4, net.ParseIP("203.0.113.7")) || floatingIPMatches(ipv4, net.ParseIP("203.0.113.8")) {
+		t.Error("expected IPv4 floating IP to match its address only")
+	}
+	if !floatingIPMatches(ipv6, net.ParseIP("2001:db8:1::1")) {
+		t.Error("expected IPv6 floating IP to match addresses of its network")
+	}
+
+	if addr, prefix := floatingIPAddress(ipv4); addr != "203.0.113.7" || prefix != 32 {
+		t.Errorf("unexpected IPv4 address %v/%d", addr, prefix)
+	}
+	if addr, prefix := floatingIPAddress(ipv6); addr != "2001:db8:1::1" || prefix != 64 {
+		t.Errorf("unexpected IPv6 address %v/%d", addr, prefix)
+	}
+
+	cmd := floatingIPCommand("sudo ", hcloud.FloatingIPTypeIPv6, "2001:db8:1::1", 64)
+	if !strings.HasPrefix(cmd, "sudo ip addr replace 2001:db8:1::1/64 dev eth0") || !strings.Contains(cmd, "iface eth0:1 inet6 static") {
+		t.Errorf("unexpected command %q", cmd)
+	}
+}
+
+func TestDescription(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagDescription: "CI runner for team: web/api",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.Description != "CI runner for team: web/api" {
+		t.Errorf("expected description to be kept as given, got %q", d.Description)
+	}
+	if value := d.ServerLabels["docker-machine/description"]; value != "CI_runner_for_team_web_api" {
+		t.Errorf("unexpected description label %q", value)
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagDescription: "???",
+	}))
+	if err == nil {
+		t.Error("expected description without letters or digits to be rejected")
+	}
+}
+
+func TestCreateFloatingIP(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagCreateFloatingIP: true,
+		flagFloatingIP:       "web",
+	}))
+	assertMutualExclusion(t, err, flagCreateFloatingIP, flagFloatingIP)
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagCreateFloatingIP:  true,
+		flagUsePrivateNetwork: true,
+	}))
+	assertMutualExclusion(t, err, flagCreateFloatingIP, flagUsePrivateNetwork)
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagCreateFloatingIP: true,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if err = d.removeFloatingIP(); err != nil {
+		t.Errorf("expected removal without a created floating IP to do nothing, but got %v", err)
+	}
+}
+
+func TestFirewallPreset(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagFirewallSSHSource: []string{"198.51.100.0/24"},
+	}))
+	if err == nil || !strings.Contains(err.Error(), flagFirewallPreset) {
+		t.Errorf("expected sources without preset to be rejected, but got %v", err)
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagFirewallPreset: "open",
+	}))
+	if err == nil {
+		t.Error("expected unknown preset to be rejected")
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagFirewallPreset:    firewallPresetEgressOnly,
+		flagFirewallSSHSource: []string{"198.51.100.7", "2001:db8::/48"},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if strings.Join(d.FirewallSources, ",") != "198.51.100.7/32,2001:db8::/48" {
+		t.Errorf("unexpected sources %v", d.FirewallSources)
+	}
+
+	rules, err := egressOnlyRules(d.FirewallSources, 2222)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if len(rules) != 2 || *rules[0].Port != "2222" || *rules[1].Port != "2376" || len(rules[0].SourceIPs) != 2 {
+		t.Errorf("unexpected rules %+v", rules)
+	}
+	for _, rule := range rules {
+		if rule.Direction != hcloud.FirewallRuleDirectionIn {
+			t.Errorf("expected only inbound rules, got %v", rule.Direction)
+		}
+	}
+
+	name := presetFirewallName(firewallPresetEgressOnly, []string{"b", "a"}, 22)
+	if name != presetFirewallName(firewallPresetEgressOnly, []string{"a", "b"}, 22) || !strings.HasPrefix(name, "docker-machine-egress-only-") {
+		t.Errorf("expected name independent of source order, got %v", name)
+	}
+}
+
+func TestControllerIPDetection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, "198.51.100.23")
+	}))
+	defer srv.Close()
+
+	ip, err := detectControllerIP(srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if ip.String() != "198.51.100.23" {
+		t.Errorf("unexpected controller IP %v", ip)
+	}
+
+	d := NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagFirewallPreset:  firewallPresetEgressOnly,
+		flagControllerIPURL: controllerIPDetectionOff,
+	}))
+	if err == nil || !strings.Contains(err.Error(), flagFirewallSSHSource) {
+		t.Errorf("expected disabled detection without sources to be rejected, but got %v", err)
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagControllerIPURL: "ipify.org",
+	}))
+	if err == nil {
+		t.Error("expected URL without scheme to be rejected")
+	}
+}
+
+func TestEnableBackups(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagEnableBackups: true,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !d.EnableBackup {
+		t.Error("expected backups to be enabled")
+	}
+	if value := d.exportedFlags()["enable-backups"]; value != true {
+		t.Errorf("expected enabled backups to be exported, got %v", value)
+	}
+}
+
+func TestBootRescue(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagBootRescue:         true,
+		flagNoStartAfterCreate: true,
+	}))
+	assertMutualExclusion(t, err, flagBootRescue, flagNoStartAfterCreate)
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagBootRescue: true,
+		flagSshUser:    "ubuntu",
+	}))
+	if err == nil || !strings.Contains(err.Error(), flagSshUser) {
+		t.Errorf("expected non-root SSH user to be rejected, but got %v", err)
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagBootRescue: true,
+		flagSshUser:    defaultSSHUser,
+		flagSshPort:    defaultSSHPort,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !d.BootRescue {
+		t.Error("expected rescue boot to be enabled")
+	}
+
+	d.Robot = true
+	if err = d.EnableRescue(); err == nil {
+		t.Error("expected error for robot server")
+	}
+}
+
+func TestISO(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagISO:        "installer.iso",
+		flagBootRescue: true,
+	}))
+	assertMutualExclusion(t, err, flagISO, flagBootRescue)
+
+	arm := hcloud.ArchitectureARM
+	d = NewDriver("test")
+	d.ISO = "installer.iso"
+	d.cachedISO = &hcloud.ISO{ID: 42, Name: "installer.iso", Architecture: &arm}
+	if err = d.verifyISO(hcloud.ArchitectureX86); err == nil || !strings.Contains(err.Error(), "installer.iso") {
+		t.Errorf("expected architecture mismatch to be rejected, but got %v", err)
+	}
+	if err = d.verifyISO(hcloud.ArchitectureARM); err != nil {
+		t.Errorf("unexpected error, %v", err)
+	}
+
+	// architecture-independent ISOs fit any server type
+	d.cachedISO.Architecture = nil
+	if err = d.verifyISO(hcloud.ArchitectureX86); err != nil {
+		t.Errorf("unexpected error, %v", err)
+	}
+}
+
+func TestDeleteProtection(t *testing.T) {
+	t.Setenv(envForceRemove, "")
+	srv := &hcloud.Server{ID: 42, Name: "protected", Protection: hcloud.ServerProtection{Delete: true, Rebuild: true}}
+
+	d := NewDriver("test")
+	err := d.checkDeleteProtection(srv)
+	if err == nil || !strings.Contains(err.Error(), flagForceRemove) {
+		t.Errorf("expected protected server to be refused, but got %v", err)
+	}
+	if err = d.checkDeleteProtection(&hcloud.Server{ID: 43}); err != nil {
+		t.Errorf("unexpected error for unprotected server, %v", err)
+	}
+
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagProtection:  true,
+		flagForceRemove: true,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !d.Protection || d.checkDeleteProtection(srv) != nil {
+		t.Error("expected protection to be enabled and removal to be forced")
+	}
+
+	d = NewDriver("test")
+	t.Setenv(envForceRemove, "true")
+	if err = d.checkDeleteProtection(srv); err != nil {
+		t.Errorf("expected removal to be forced by environment, but got %v", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	d := NewDriver("1.2.3")
+	var buf bytes.Buffer
+	if err := d.PrintCapabilities(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var capabilities map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &capabilities); err != nil {
+		t.Fatal(err)
+	}
+	if capabilities["schema-version"] != float64(capabilitiesSchemaVersion) || capabilities["driver-version"] != "1.2.3" {
+		t.Errorf("unexpected capabilities %v", capabilities)
+	}
+	if capabilities["supports-robot"] != true || capabilities["supports-adopt"] != false {
+		t.Errorf("unexpected supported features %v", capabilities)
+	}
+
+	if !sort.StringsAreSorted(features) {
+		t.Errorf("expected features to be sorted, but got %v", features)
+	}
+	d.Capabilities().Features[0] = "modified"
+	if features[0] == "modified" {
+		t.Error("expected features to be copied")
+	}
+}
+
+func TestLoadBalancers(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagLoadBalancerLabel: "role=worker",
+	}))
+	if err == nil || !strings.Contains(err.Error(), flagLoadBalancers) {
+		t.Errorf("expected label without load balancers to be rejected, but got %v", err)
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagLoadBalancers:     []string{"web"},
+		flagLoadBalancerLabel: "role",
+	}))
+	if err == nil {
+		t.Error("expected label without value to be rejected")
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagLoadBalancers:     []string{"web", "api"},
+		flagLoadBalancerLabel: "role=worker",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if len(d.LoadBalancers) != 2 || d.ServerLabels["role"] != "worker" {
+		t.Errorf("unexpected load balancers %v or labels %v", d.LoadBalancers, d.ServerLabels)
+	}
+
+	// nothing was registered, so there is nothing to deregister
+	d.deregisterLoadBalancers()
+}
+
+func TestAutoLoadBalancer(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAutoLoadBalancer: "!!!",
+	}))
+	if err == nil {
+		t.Error("expected name without label characters to be rejected")
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAutoLoadBalancer: "ci workers",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.ServerLabels["docker-machine/auto-load-balancer"] != labelValue("ci workers") {
+		t.Errorf("expected server to be labeled for the load balancer, but got %v", d.ServerLabels)
+	}
+	if d.autoLoadBalancerSelector() != "docker-machine/auto-load-balancer="+labelValue("ci workers") {
+		t.Errorf("unexpected selector %v", d.autoLoadBalancerSelector())
+	}
+
+	// without --hetzner-auto-load-balancer, nothing is created or removed
+	d = NewDriver("test")
+	if err = d.ensureAutoLoadBalancer(&hcloud.Server{}); err != nil {
+		t.Error(err)
+	}
+	if err = d.removeAutoLoadBalancer(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAutoLoadBalancerCreatedConcurrently(t *testing.T) {
+	lists, creates := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/load_balancers":
+			// the load balancer only shows up after another machine created it
+			lists++
+			if lists == 1 {
+				_, _ = fmt.Fprint(w, `{"load_balancers": []}`)
+			} else {
+				_, _ = fmt.Fprint(w, `{"load_balancers": [{"id": 7, "name": "ci"}]}`)
+			}
+		case r.Method == http.MethodPost && r.URL.Path == "/load_balancers":
+			creates++
+			w.WriteHeader(http.StatusConflict)
+			_, _ = fmt.Fprint(w, `{"error": {"code": "uniqueness_error", "message": "name is already used"}}`)
+		default:
+			t.Errorf("unexpected request %v %v", r.Method, r.URL)
+		}
+	}))
+	defer srv.Close()
+
+	d := NewDriver("test")
+	d.AccessToken, d.endpoint = "foo", srv.URL
+	if err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{flagAutoLoadBalancer: "ci"})); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	server := &hcloud.Server{Datacenter: &hcloud.Datacenter{Location: &hcloud.Location{NetworkZone: "eu-central"}}}
+	if err := d.ensureAutoLoadBalancer(server); err != nil {
+		t.Errorf("expected concurrently created load balancer to be used, but got %v", err)
+	}
+	if creates != 1 || lists != 2 {
+		t.Errorf("expected a creation and two listings, but got %d and %d", creates, lists)
+	}
+	if len(d.dangling) != 0 {
+		t.Errorf("expected the load balancer of another machine not to be tracked for cleanup, but got %v", d.dangling)
+	}
+}
+
+func TestAPITLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAPITLSMinVersion: "1.1",
+	}))
+	if err == nil {
+		t.Error("expected unsupported TLS version to be rejected")
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAPICABundle: filepath.Join(t.TempDir(), "missing.pem"),
+	}))
+	if err == nil {
+		t.Error("expected missing CA bundle to be rejected")
+	}
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagAPICABundle:      bundle,
+		flagAPITLSMinVersion: "1.2",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+
+	client := &http.Client{}
+	d.setupClientTLS(client)
+	transport := client.Transport
+	d.setupClientTLS(client)
+	if client.Transport != transport {
+		t.Error("expected the transport to be shared by the clients of the driver")
+	}
+
+	// API metrics are collected via the custom transport
+	d.MetricsFile = filepath.Join(t.TempDir(), "metrics.prom")
+	d.setupClientMetrics(client)
+	resp, err := client.Get(srv.URL + "/v1/servers/42/actions/poweron")
+	if err != nil {
+		t.Fatalf("expected server certified by the CA bundle to be trusted, but got %v", err)
+	}
+	_ = resp.Body.Close()
+
+	families, err := metricsRegistry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counted := false
+	for _, family := range families {
+		if family.GetName() != "hcloud_api_requests_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				counted = counted || (label.GetName() == "api_endpoint" && label.GetValue() == "/servers/actions/poweron")
+			}
+		}
+	}
+	if !counted {
+		t.Error("expected the API request to be counted")
+	}
+}
+
+func TestServerTypeSelection(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagType:     "cpx21",
+		flagMinCores: 2,
+	}))
+	assertMutualExclusion(t, err, flagType, flagMinCores)
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagMinMemory: 4,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.typeRequirements.memory != 4 {
+		t.Errorf("expected minimum memory 4, got %v", d.typeRequirements.memory)
+	}
+
+	priced := func(name, location, gross string) hcloud.ServerTypePricing {
+		return hcloud.ServerTypePricing{
+			ServerType: &hcloud.ServerType{Name: name},
+			Pricings: []hcloud.ServerTypeLocationPricing{{
+				Location: &hcloud.Location{Name: location},
+				Monthly:  hcloud.Price{Gross: gross},
+			}},
+		}
+	}
+	types := []*hcloud.ServerType{
+		{ID: 1, Name: "small", Cores: 1, Memory: 2, Disk: 20, Architecture: hcloud.ArchitectureX86},
+		{ID: 2, Name: "medium", Cores: 2, Memory: 4, Disk: 40, Architecture: hcloud.ArchitectureX86},
+		{ID: 3, Name: "large", Cores: 4, Memory: 8, Disk: 80, Architecture: hcloud.ArchitectureX86},
+		{ID: 4, Name: "arm", Cores: 2, Memory: 4, Disk: 40, Architecture: hcloud.ArchitectureARM},
+	}
+	pricing := hcloud.Pricing{ServerTypes: []hcloud.ServerTypePricing{
+		priced("small", "fsn1", "4.00"),
+		priced("medium", "fsn1", "8.00"),
+		priced("large", "fsn1", "16.00"),
+		priced("arm", "fsn1", "5.00"),
+	}}
+	requirements := serverTypeRequirements{cores: 2, memory: 4}
+
+	stype, price := cheapestServerType(types, pricing, nil, "fsn1", hcloud.ArchitectureX86, requirements)
+	if stype == nil || stype.Name != "medium" || price != 8 {
+		t.Errorf("expected medium for 8.00, got %v for %v", stype, price)
+	}
+	stype, _ = cheapestServerType(types, pricing, nil, "fsn1", hcloud.ArchitectureARM, requirements)
+	if stype == nil || stype.Name != "arm" {
+		t.Errorf("expected arm, got %v", stype)
+	}
+
+	// unavailable or unpriced types in the location are skipped
+	stype, _ = cheapestServerType(types, pricing, map[int64]bool{3: true}, "fsn1", hcloud.ArchitectureX86, requirements)
+	if stype == nil || stype.Name != "large" {
+		t.Errorf("expected large, got %v", stype)
+	}
+	if stype, _ = cheapestServerType(types, pricing, nil, "nbg1", hcloud.ArchitectureX86, requirements); stype != nil {
+		t.Errorf("expected no type in a location without prices, got %v", stype)
+	}
+}
+
+func TestServerLocationFallback(t *testing.T) {
+	d := NewDriver("test")
+	err := d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagLocation:        "fsn1",
+		flagServerLocations: []string{"nbg1", "hel1"},
+	}))
+	assertMutualExclusion(t, err, flagLocation, flagServerLocations)
+
+	d = NewDriver("test")
+	err = d.setConfigFromFlagsImpl(makeFlags(map[string]interface{}{
+		flagServerLocations: []string{"fsn1", "nbg1"},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.Location != "fsn1" {
+		t.Errorf("expected first location fsn1, got %v", d.Location)
+	}
+
+	unavailable := hcloud.Error{Code: hcloud.ErrorCodeResourceUnavailable, Message: "no capacity"}
+	if d.shouldFallBack(hcloud.Error{Code: hcloud.ErrorCodeInvalidInput}) {
+		t.Error("expected no fallback on unrelated errors")
+	}
+	if !d.shouldFallBack(fmt.Errorf("giving up after 3 attempts: %w", unavailable)) {
+		t.Error("expected fallback on unavailable resources")
+	}
+
+	d.cachedLocation = &hcloud.Location{Name: "fsn1"}
+	if err = d.fallBack(unavailable); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.Location != "nbg1" || d.cachedLocation != nil {
+		t.Errorf("expected fallback to uncached nbg1, got %v", d.Location)
+	}
+	if d.shouldFallBack(hcloud.Error{Code: hcloud.ErrorCodePlacementError}) {
+		t.Error("expected no fallback beyond the last location")
+	}
+
+	d.Location = "fsn1"
+	d.Volumes = []string{"data"}
+	if err = d.fallBack(unavailable); !errors.Is(err, unavailable) {
+		t.Errorf("expected fallback with volumes to be refused with the original error, but got %v", err)
+	}
+
+	d.Volumes = nil
+	d.reusePrimaryIPOf = "previous"
+	if err = d.fallBack(unavailable); !errors.Is(err, unavailable) {
+		t.Errorf("expected fallback reusing primary IPs to be refused with the original error, but got %v", err)
+	}
+}
+
+func TestServerLocationFallbackNetworkZone(t *testing.T) {
+	api := newFakeAPI(t, map[string]string{
+		"GET /networks/1": `{"network": {"id": 1, "name": "backend", "ip_range": "10.0.0.0/16",
+			"subnets": [{"type": "cloud", "ip_range": "10.0.1.0/24", "network_zone": "eu-central"}]}}`,
+		"GET /locations": `{"locations": [{"id": 1, "name": "fsn1", "network_zone": "eu-central"},
+			{"id": 2, "name": "ash", "network_zone": "us-east"}, {"id": 3, "name": "nbg1", "network_zone": "eu-central"}]}`,
+	})
+	unavailable := hcloud.Error{Code: hcloud.ErrorCodeResourceUnavailable, Message: "no capacity"}
+
+	d := api.driver()
+	d.Networks, d.ServerLocations, d.Location = []string{"1"}, []string{"fsn1", "ash", "nbg1"}, "fsn1"
+	if err := d.fallBack(unavailable); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if d.Location != "nbg1" {
+		t.Errorf("expected location outside the network zone to be skipped, but got %v", d.Location)
+	}
+
+	d.ServerLocations, d.Location = []string{"fsn1", "ash"}, "fsn1"
+	if err := d.fallBack(unavailable); !errors.Is(err, unavailable) || d.Location != "fsn1" {
+		t.Errorf("expected fallback without location in the network zone to fail in fsn1, but got %v in %v", err, d.Location)
+	}
+
+	// lacking capacity is not retried in the same location if another one may have it
+	if !d.isRetryableCreationError(hcloud.Error{Code: hcloud.ErrorCodeServiceError}) {
+		t.Error("expected service errors to be retried")
+	}
+	if d.isRetryableCreationError(unavailable) {
+		t.Error("expected lack of capacity not to be retried with fallback locations")
+	}
+	d.ServerLocations = nil
+	if !d.isRetryableCreationError(unavailable) {
+		t.Error("expected lack of capacity to be retried without fallback locations")
+	}
+}
+
+func TestServerCreationNotRetriedWhenCreated(t *testing.T) {
+	creates := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/servers":
+			creates++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = fmt.Fprint(w, `{"error": {"code": "service_error", "message": "timeout"}}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/servers":
+			_, _ = fmt.Fprint(w, `{"servers": [{"id": 42, "name": "test"}]}`)
+		default:
+			t.Errorf("unexpected request %v %v", r.Method, r.URL)
+		}
+	}))
+	defer srv.Close()
+
+	d := NewDriver("test")
+	d.AccessToken, d.endpoint = "foo", srv.URL
+	err := d.retry("server creation", func() error {
+		_, _, err := d.getClient().Server.Create(context.Background(), hcloud.ServerCreateOpts{
+			Name: "test", ServerType: &hcloud.ServerType{Name: "cx22"}, Image: &hcloud.Image{Name: "ubuntu-24.04"}})
+		return d.checkServerCreated("test", err)
+	})
+	if err == nil || !strings.Contains(err.Error(), "test[42]") {
+		t.Errorf("expected creation to fail for the server created nonetheless, but got %v", err)
+	}
+	if creates != 1 {
+		t.Errorf("expected a single creation request, but got %d", creates)
+	}
+	if len(d.dangling) != 1 || d.dangling[0].kind != "server" {
+		t.Errorf("expected the server to be tracked for cleanup, but got %v", d.dangling)
+	}
+}
+
+func TestVSwitchSetup(t *testing.T) {
+	api := newFakeAPI(t, map[string]string{
+		"GET /networks/1": `{"network": {"id": 1, "name": "backend", "ip_range": "10.0.0.0/16",
+			"subnets": [{"type": "cloud", "ip_range": "10.0.1.0/24", "network_zone": "eu-central"}]}}`,
+		"POST /networks/1/actions/add_subnet":    `{"action": {"id": 1, "status": "running"}}`,
+		"PUT /networks/1":                        `{"network": {"id": 1, "name": "backend"}}`,
+		"POST /networks/1/actions/delete_subnet": `{"action": {"id": 1, "status": "running"}}`,
+	})
+	d := api.driver()
+	d.Networks, d.VSwitchID, d.VSwitchSubnet, d.VSwitchExposeRoutes = []string{"1"}, 4711, "10.0.2.0/24", true
+
+	// checking before creation must not touch the network
+	if _, err := d.checkVSwitch(); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if len(api.requests) != 1 {
+		t.Errorf("expected only the network to be read, but got %v", api.requests)
+	}
+
+	if err := d.setupVSwitch(); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !api.requested("POST /networks/1/actions/add_subnet") || !api.requested("PUT /networks/1") {
+		t.Errorf("expected subnet to be added and routes to be exposed, but got %v", api.requests)
+	}
+
+	// failed creation reverts the coupling
+	if err := d.destroyDangling(); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !api.requested("POST /networks/1/actions/delete_subnet") {
+		t.Errorf("expected subnet to be removed, but got %v", api.requests)
 	}
 }
@@ -0,0 +1,89 @@
+package driver
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	engineLabelPrefix = "hetzner."
+
+	// engineLabelsGenerator is a systemd generator merging the engine labels into the dockerd command line set up by
+	// docker-machine's provisioner, which only exists after the driver is done; it runs on the provisioner's daemon-reload
+	// and overrides ExecStart by a drop-in sorting after the provisioner's one
+	engineLabelsGenerator = "/etc/systemd/system-generators/hetzner-engine-labels"
+	engineLabelsScript    = `
+conf=/etc/systemd/system/docker.service.d/10-machine.conf
+[ -f "$conf" ] || exit 0
+cmd=$(sed -n 's/^ExecStart=\(..*\)$/\1/p' "$conf" | tail -n 1)
+[ -n "$cmd" ] || exit 0
+mkdir -p "$1/docker.service.d"
+printf '[Service]\nExecStart=\nExecStart=%s %s\n' "$cmd" "$labels" >"$1/docker.service.d/20-hetzner-engine-labels.conf"
+`
+)
+
+// engineLabels translates the server labels as well as the server's topology into Docker engine labels
+func (d *Driver) engineLabels(srv *hcloud.Server) []string {
+	labels := make([]string, 0, len(d.ServerLabels)+4)
+	for key, value := range d.ServerLabels {
+		labels = append(labels, key+"="+value)
+	}
+	sort.Strings(labels)
+
+	labels = append(labels, engineLabelPrefix+"server-id="+strconv.FormatInt(srv.ID, 10))
+	if srv.ServerType != nil {
+		labels = append(labels, engineLabelPrefix+"server-type="+srv.ServerType.Name)
+	}
+	if srv.Datacenter != nil {
+		labels = append(labels, engineLabelPrefix+"datacenter="+srv.Datacenter.Name)
+		if srv.Datacenter.Location != nil {
+			labels = append(labels, engineLabelPrefix+"location="+srv.Datacenter.Location.Name)
+			labels = append(labels, engineLabelPrefix+"network-zone="+string(srv.Datacenter.Location.NetworkZone))
+		}
+	}
+	return labels
+}
+
+// applyEngineLabels installs a systemd generator adding the engine labels to the dockerd command line before the engine
+// is provisioned; labels passed by docker-machine via --engine-label are kept, and no daemon configuration is written
+func (d *Driver) applyEngineLabels(srv *hcloud.Server) error {
+	if !d.EngineLabels {
+		return nil
+	}
+
+	labels := d.engineLabels(srv)
+	script := engineLabelsGeneratorScript(labels)
+
+	if err := d.waitForSSH(); err != nil {
+		return err
+	}
+
+	sudo := ""
+	if d.GetSSHUsername() != "root" {
+		sudo = "sudo "
+	}
+	cmd := fmt.Sprintf("%[1]vmkdir -p %[2]v && printf '%%s' '%[3]v' | %[1]vtee %[4]v >/dev/null && %[1]vchmod 755 %[4]v",
+		sudo, path.Dir(engineLabelsGenerator), strings.ReplaceAll(script, "'", `'\''`), engineLabelsGenerator)
+
+	log.Infof(" -> Applying engine labels %v...", strings.Join(labels, ", "))
+	if _, err := drivers.RunSSHCommandFromDriver(d, cmd); err != nil {
+		return fmt.Errorf("could not install engine labels: %w", err)
+	}
+	return nil
+}
+
+// engineLabelsGeneratorScript returns the generator script passing the labels as --label flags
+func engineLabelsGeneratorScript(labels []string) string {
+	flags := make([]string, 0, len(labels))
+	for _, label := range labels {
+		flags = append(flags, "--label "+label)
+	}
+	return "#!/bin/sh\nlabels='" + strings.Join(flags, " ") + "'" + engineLabelsScript
+}
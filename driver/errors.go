@@ -0,0 +1,44 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const correlationIDHeader = "X-Correlation-Id"
+
+// correlatedError amends an API error with the correlation ID of the failed request, which Hetzner support can use to
+// look it up
+type correlatedError struct {
+	err           error
+	correlationID string
+}
+
+func (e correlatedError) Error() string {
+	return fmt.Sprintf("%v (correlation ID: %v)", e.err, e.correlationID)
+}
+
+func (e correlatedError) Unwrap() error {
+	return e.err
+}
+
+// withCorrelationID adds the correlation ID to the error if it was caused by a failed API request
+func withCorrelationID(err error) error {
+	var correlated correlatedError
+	if err == nil || errors.As(err, &correlated) {
+		return err
+	}
+
+	var apiErr hcloud.Error
+	if !errors.As(err, &apiErr) || apiErr.Response() == nil || apiErr.Response().Response == nil {
+		return err
+	}
+
+	id := apiErr.Response().Header.Get(correlationIDHeader)
+	if id == "" {
+		return err
+	}
+	return correlatedError{err: err, correlationID: id}
+}
@@ -0,0 +1,61 @@
+package driver
+
+import (
+	"os"
+	"regexp"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/mcnflag"
+)
+
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)}`)
+
+// withEnvExpansion expands ${VAR} references in all string flag values if --hetzner-expand-env is set; references to
+// undefined variables are rejected rather than silently replaced with empty strings
+func (d *Driver) withEnvExpansion(opts drivers.DriverOptions) (drivers.DriverOptions, error) {
+	if !opts.Bool(flagExpandEnv) {
+		return opts, nil
+	}
+
+	values := make(map[string]interface{})
+	for _, flag := range d.GetCreateFlags() {
+		name := flag.String()
+		switch flag.(type) {
+		case mcnflag.StringFlag:
+			value, err := d.expandEnv(name, opts.String(name))
+			if err != nil {
+				return nil, err
+			}
+			values[name] = value
+		case mcnflag.StringSliceFlag:
+			slice := opts.StringSlice(name)
+			expanded := make([]string, len(slice))
+			for i, value := range slice {
+				var err error
+				if expanded[i], err = d.expandEnv(name, value); err != nil {
+					return nil, err
+				}
+			}
+			values[name] = expanded
+		}
+	}
+
+	return &overriddenOptions{DriverOptions: opts, values: values}, nil
+}
+
+func (d *Driver) expandEnv(flag, value string) (string, error) {
+	var missing string
+	ret := envReference.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envReference.FindStringSubmatch(ref)[1]
+		env, ok := os.LookupEnv(name)
+		if !ok && missing == "" {
+			missing = name
+		}
+		return env
+	})
+
+	if missing != "" {
+		return "", d.flagFailure("--%v references undefined environment variable %v", flag, missing)
+	}
+	return ret, nil
+}
@@ -0,0 +1,87 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const labelProject = "project"
+
+// setFailoverProjectFlag sets the project to fail over to when the current one exceeds its resource limits; explicit key
+// IDs cannot be combined with it, as they are project-specific
+func (d *Driver) setFailoverProjectFlag(project string) error {
+	d.FailoverProject = project
+	if project != "" && d.IsExistingKey {
+		return d.flagFailure("--%v and --%v are mutually exclusive", flagFailoverProject, flagExKeyID)
+	}
+	return nil
+}
+
+// verifyFailoverFlags rejects references to project-specific resources by ID along with a failover project, as IDs
+// cannot be resolved in another project, while names are looked up again in the failover project
+func (d *Driver) verifyFailoverFlags() error {
+	if d.FailoverProject == "" {
+		return nil
+	}
+	if d.ImageID != 0 {
+		return d.flagFailure("--%v and --%v are mutually exclusive, reference the image by name instead", flagFailoverProject, flagImageID)
+	}
+
+	refs := []struct {
+		flag   string
+		values []string
+	}{
+		{flagNetworks, d.Networks},
+		{flagVolumes, d.Volumes},
+		{flagFirewalls, d.Firewalls},
+		{flagLoadBalancers, d.LoadBalancers},
+		{flagFloatingIP, []string{d.FloatingIP}},
+		{flagPlacementGroup, []string{d.placementGroup}},
+		{flagISO, []string{d.ISO}},
+	}
+	for _, ref := range refs {
+		for _, idOrName := range ref.values {
+			if _, ok := numericID(idOrName); ok {
+				return d.flagFailure("--%v %v references a resource by ID, which is not supported along with --%v; use its name instead",
+					ref.flag, idOrName, flagFailoverProject)
+			}
+		}
+	}
+	return nil
+}
+
+// shouldFailOver checks whether server creation failed due to the project's resource limits and a failover project is set
+func (d *Driver) shouldFailOver(err error) bool {
+	var apiErr hcloud.Error
+	return d.FailoverProject != "" && errors.As(err, &apiErr) && apiErr.Code == hcloud.ErrorCodeResourceLimitExceeded
+}
+
+// failOver switches to the failover project, cleaning up the resources created in the current one so far and dropping
+// all cached resources; the server is labeled with the project alias
+func (d *Driver) failOver() error {
+	if d.PrimaryIPv4 != "" || d.PrimaryIPv6 != "" || d.PrimaryIPPool != "" || d.reusePrimaryIPOf != "" || d.VSwitchID != 0 {
+		return errors.New("cannot fail over with primary IPs or a vSwitch configured, as they are project-specific")
+	}
+
+	log.Warnf("Resource limit of the current project exceeded, failing over to project %v ...", d.FailoverProject)
+	// resources left behind in the current project are logged, as they cannot be cleaned up from the failover project
+	_ = d.destroyDangling()
+	d.dangling = nil
+
+	d.Project, d.FailoverProject = d.FailoverProject, ""
+	d.AccessToken, d.projectToken = "", ""
+	d.KeyID, d.IsExistingKey, d.KeyReused, d.cachedKey = 0, false, false, nil
+	d.AdditionalKeyIDs, d.cachedAdditionalKeys = nil, nil
+	// all other resources are referenced by name, so they are resolved again in the failover project
+	d.cachedPGrp, d.cachedImage, d.cachedISO, d.cachedFloatingIP, d.allocatedIPs = nil, nil, nil, nil, nil
+	d.ImageID = 0 // pinned by name in the current project, see pinImage
+	d.ServerLabels[d.labelName(labelProject)] = labelValue(d.Project)
+
+	if d.apiToken() == "" {
+		return fmt.Errorf("could not get API token of failover project %v", d.Project)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// setFirewallLabelFlag parses --hetzner-firewall-label, which is assigned to the server and replaces attaching the
+// firewalls of --hetzner-firewalls directly by applying them to all servers carrying the label
+func (d *Driver) setFirewallLabelFlag(label string) error {
+	d.FirewallLabel = ""
+	if label == "" {
+		return nil
+	}
+	if len(d.Firewalls) == 0 {
+		return d.flagFailure("--%v requires --%v", flagFirewallLabel, flagFirewalls)
+	}
+
+	key, value, err := d.parseServerLabelFlag(flagFirewallLabel, label)
+	if err != nil {
+		return err
+	}
+
+	d.FirewallLabel = label
+	d.ServerLabels[key] = value
+	return nil
+}
+
+// applyFirewallsByLabel applies the firewall to all servers carrying the label of --hetzner-firewall-label, unless
+// it already is, so the server is protected from its first boot on, as are machines created later with the same label
+func (d *Driver) applyFirewallsByLabel(firewall *hcloud.Firewall) error {
+	if hasLabelSelectorResource(firewall, d.FirewallLabel) {
+		return nil
+	}
+
+	log.Infof(" -> Applying firewall %v[%d] to servers labeled %v ...", firewall.Name, firewall.ID, d.FirewallLabel)
+	actions, _, err := d.getClient().Firewall.ApplyResources(context.Background(), firewall, []hcloud.FirewallResource{{
+		Type:          hcloud.FirewallResourceTypeLabelSelector,
+		LabelSelector: &hcloud.FirewallResourceLabelSelector{Selector: d.FirewallLabel},
+	}})
+	if hcloud.IsError(err, hcloud.ErrorCodeFirewallAlreadyApplied) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("could not apply firewall %v to label selector %v: %w", firewall.Name, d.FirewallLabel, err)
+	}
+	if err = d.waitForMultipleActions("firewall.ApplyResources", actions); err != nil {
+		return fmt.Errorf("could not wait for firewall %v to be applied: %w", firewall.Name, err)
+	}
+	return nil
+}
+
+// hasLabelSelectorResource reports whether the firewall is applied to the given label selector
+func hasLabelSelectorResource(firewall *hcloud.Firewall, selector string) bool {
+	for _, res := range firewall.AppliedTo {
+		if res.Type == hcloud.FirewallResourceTypeLabelSelector && res.LabelSelector != nil && res.LabelSelector.Selector == selector {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// ApplyFirewalls applies the firewall given by ID or name, or all firewalls matching the label selector, to the existing
+// server and adds them to the configured firewalls; firewalls already applied are skipped
+func (d *Driver) ApplyFirewalls(idOrName, selector string) (err error) {
+	defer d.operation("ApplyFirewalls")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	srv, firewalls, err := d.getServerAndFirewalls(idOrName, selector)
+	if err != nil {
+		return err
+	}
+
+	for _, firewall := range firewalls {
+		log.Infof("Applying firewall %v[%d] to server %v[%d] ...", firewall.Name, firewall.ID, srv.Name, srv.ID)
+		actions, _, err := d.getClient().Firewall.ApplyResources(context.Background(), firewall, serverFirewallResource(srv))
+		if hcloud.IsError(err, hcloud.ErrorCodeFirewallAlreadyApplied) {
+			log.Infof(" -> already applied")
+		} else if err != nil {
+			return fmt.Errorf("could not apply firewall %v: %w", firewall.Name, err)
+		} else if err = d.waitForMultipleActions("firewall.ApplyResources", actions); err != nil {
+			return fmt.Errorf("could not wait for firewall %v to be applied: %w", firewall.Name, err)
+		}
+
+		d.Firewalls = append(withoutResource(d.Firewalls, firewall.ID, firewall.Name), firewall.Name)
+	}
+	return nil
+}
+
+// RemoveFirewalls removes the firewall given by ID or name, or all firewalls matching the label selector, from the
+// existing server and the configured firewalls; firewalls not applied are skipped
+func (d *Driver) RemoveFirewalls(idOrName, selector string) (err error) {
+	defer d.operation("RemoveFirewalls")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	srv, firewalls, err := d.getServerAndFirewalls(idOrName, selector)
+	if err != nil {
+		return err
+	}
+
+	for _, firewall := range firewalls {
+		log.Infof("Removing firewall %v[%d] from server %v[%d] ...", firewall.Name, firewall.ID, srv.Name, srv.ID)
+		actions, _, err := d.getClient().Firewall.RemoveResources(context.Background(), firewall, serverFirewallResource(srv))
+		if hcloud.IsError(err, hcloud.ErrorCodeFirewallAlreadyRemoved) {
+			log.Infof(" -> already removed")
+		} else if err != nil {
+			return fmt.Errorf("could not remove firewall %v: %w", firewall.Name, err)
+		} else if err = d.waitForMultipleActions("firewall.RemoveResources", actions); err != nil {
+			return fmt.Errorf("could not wait for firewall %v to be removed: %w", firewall.Name, err)
+		}
+
+		d.Firewalls = withoutResource(d.Firewalls, firewall.ID, firewall.Name)
+	}
+	return nil
+}
+
+func serverFirewallResource(srv *hcloud.Server) []hcloud.FirewallResource {
+	return []hcloud.FirewallResource{{
+		Type:   hcloud.FirewallResourceTypeServer,
+		Server: &hcloud.FirewallResourceServer{ID: srv.ID},
+	}}
+}
+
+func (d *Driver) getServerAndFirewalls(idOrName, selector string) (*hcloud.Server, []*hcloud.Firewall, error) {
+	if d.Robot {
+		return nil, nil, errors.New("firewalls cannot be managed for robot servers")
+	}
+	if (idOrName == "") == (selector == "") {
+		return nil, nil, errors.New("exactly one of a firewall ID or name and a label selector is required")
+	}
+
+	srv, err := d.getServerHandle()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if selector != "" {
+		firewalls, err := d.getClient().Firewall.AllWithOpts(context.Background(), hcloud.FirewallListOpts{
+			ListOpts: hcloud.ListOpts{LabelSelector: selector},
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not list firewalls labeled %v: %w", selector, err)
+		}
+		if len(firewalls) == 0 {
+			return nil, nil, fmt.Errorf("no firewalls labeled %v found", selector)
+		}
+		return srv, firewalls, nil
+	}
+
+	firewall, _, err := d.getClient().Firewall.Get(context.Background(), idOrName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get firewall by ID or name: %w", err)
+	}
+	if firewall == nil {
+		return nil, nil, notFoundError("firewall", idOrName)
+	}
+	return srv, []*hcloud.Firewall{firewall}, nil
+}
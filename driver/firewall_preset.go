@@ -0,0 +1,204 @@
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	firewallPresetEgressOnly = "egress-only"
+	labelFirewallPreset      = "firewall-preset"
+
+	// dockerEnginePort is the port docker-machine reaches the Docker engine of its machines on
+	dockerEnginePort = 2376
+
+	// defaultControllerIPURL responds with the public IPv4 or IPv6 address of the requesting host
+	defaultControllerIPURL = "https://api64.ipify.org"
+	controllerIPTimeout    = 10 * time.Second
+
+	// controllerIPDetectionOff disables detecting the controller's address for firewall rules
+	controllerIPDetectionOff = "off"
+)
+
+// setFirewallPresetFlags validates --hetzner-firewall-preset, the additional sources of SSH access it allows and the
+// service detecting the controller's address, defaulting to defaultControllerIPURL
+func (d *Driver) setFirewallPresetFlags(preset string, sources []string, controllerIPURL string) error {
+	d.FirewallPreset, d.FirewallSources, d.ControllerIPURL = preset, nil, controllerIPURL
+	if d.ControllerIPURL == "" {
+		d.ControllerIPURL = defaultControllerIPURL
+	}
+	if d.ControllerIPURL != controllerIPDetectionOff {
+		if u, err := url.Parse(d.ControllerIPURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return d.flagFailure("--%v must be an http(s) URL or %v, got: %q", flagControllerIPURL, controllerIPDetectionOff, controllerIPURL)
+		}
+	}
+
+	switch preset {
+	case "":
+		if len(sources) > 0 {
+			return d.flagFailure("--%v requires --%v", flagFirewallSSHSource, flagFirewallPreset)
+		}
+		return nil
+	case firewallPresetEgressOnly:
+		if d.ControllerIPURL == controllerIPDetectionOff && len(sources) == 0 {
+			return d.flagFailure("--%v %v requires --%v", flagControllerIPURL, controllerIPDetectionOff, flagFirewallSSHSource)
+		}
+	default:
+		return d.flagFailure("--%v must be %v, got: %v", flagFirewallPreset, firewallPresetEgressOnly, preset)
+	}
+
+	for _, source := range sources {
+		cidr, err := parseFirewallSource(source)
+		if err != nil {
+			return d.flagFailure("--%v: %v", flagFirewallSSHSource, err)
+		}
+		d.FirewallSources = append(d.FirewallSources, cidr.String())
+	}
+	return nil
+}
+
+// parseFirewallSource parses a CIDR, or a single address which is turned into a host network
+func parseFirewallSource(source string) (*net.IPNet, error) {
+	if ip := net.ParseIP(source); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, cidr, err := net.ParseCIDR(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address or CIDR %q", source)
+	}
+	return cidr, nil
+}
+
+// presetFirewall returns the firewall of --hetzner-firewall-preset for the configured sources and the controller's
+// address, creating it if no machine created it before; as it is shared by all machines with the same sources, it is
+// kept when machines are removed
+func (d *Driver) presetFirewall() (*hcloud.Firewall, error) {
+	sources := append([]string{}, d.FirewallSources...)
+	if d.ControllerIPURL != controllerIPDetectionOff {
+		controller, err := detectControllerIP(d.ControllerIPURL)
+		if err != nil {
+			if len(sources) == 0 {
+				return nil, fmt.Errorf("could not detect controller IP, pass --%v: %w", flagFirewallSSHSource, err)
+			}
+			log.Warnf("could not detect controller IP, allowing access from --%v only: %v", flagFirewallSSHSource, err)
+		} else {
+			log.Infof(" -> Detected controller IP %v", controller)
+			sources = append(sources, controller.String())
+		}
+	}
+
+	rules, err := egressOnlyRules(sources, d.SSHPort)
+	if err != nil {
+		return nil, err
+	}
+	name := presetFirewallName(d.FirewallPreset, sources, d.SSHPort)
+
+	firewall, _, err := d.getClient().Firewall.GetByName(context.Background(), name)
+	if err != nil {
+		return nil, fmt.Errorf("could not get firewall %v: %w", name, err)
+	}
+	if firewall != nil {
+		return firewall, nil
+	}
+
+	log.Infof(" -> Creating firewall %v allowing SSH and Docker from %v ...", name, strings.Join(sources, ", "))
+	res, _, err := d.getClient().Firewall.Create(context.Background(), hcloud.FirewallCreateOpts{
+		Name:  name,
+		Rules: rules,
+		Labels: map[string]string{
+			d.labelName(labelFirewallPreset): d.FirewallPreset,
+			d.labelName(labelAutoCreated):    "true",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create firewall %v: %w", name, err)
+	}
+	if err = d.waitForMultipleActions("firewall.Create", res.Actions); err != nil {
+		return nil, fmt.Errorf("could not wait for firewall %v: %w", name, err)
+	}
+	return res.Firewall, nil
+}
+
+// egressOnlyRules allows inbound SSH and Docker engine connections from the sources only, while outbound traffic is
+// not restricted as there are no outbound rules
+func egressOnlyRules(sources []string, sshPort int) ([]hcloud.FirewallRule, error) {
+	var sourceIPs []net.IPNet
+	for _, source := range sources {
+		cidr, err := parseFirewallSource(source)
+		if err != nil {
+			return nil, err
+		}
+		sourceIPs = append(sourceIPs, *cidr)
+	}
+
+	var rules []hcloud.FirewallRule
+	for _, rule := range []struct {
+		port        int
+		description string
+	}{{sshPort, "SSH"}, {dockerEnginePort, "Docker engine"}} {
+		rules = append(rules, hcloud.FirewallRule{
+			Direction:   hcloud.FirewallRuleDirectionIn,
+			Protocol:    hcloud.FirewallRuleProtocolTCP,
+			Port:        hcloud.Ptr(strconv.Itoa(rule.port)),
+			SourceIPs:   sourceIPs,
+			Description: hcloud.Ptr(rule.description),
+		})
+	}
+	return rules, nil
+}
+
+// presetFirewallName names the firewall of a preset after a hash of its rules' parameters, so machines with the same
+// parameters share it
+func presetFirewallName(preset string, sources []string, sshPort int) string {
+	sorted := append([]string{}, sources...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v|%d", strings.Join(sorted, ","), sshPort)))
+	return fmt.Sprintf("%v-%v-%v", labelNamespace, preset, hex.EncodeToString(sum[:4]))
+}
+
+// detectControllerIP determines the public address of the host running docker-machine by querying the echo service at
+// serviceURL, which responds with the address of the requesting host as plain text
+func detectControllerIP(serviceURL string) (net.IP, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), controllerIPTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serviceURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not query %v: %w", serviceURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status querying %v: %v", serviceURL, resp.Status)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return nil, fmt.Errorf("could not read response: %w", err)
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(raw)))
+	if ip == nil {
+		return nil, fmt.Errorf("%v responded with invalid address %q", serviceURL, strings.TrimSpace(string(raw)))
+	}
+	return ip, nil
+}
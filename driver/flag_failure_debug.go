@@ -1,4 +1,4 @@
-//go:build flag_debug || instrumented
+//go:build flag_debug
 
 package driver
 
@@ -2,6 +2,7 @@ package driver
 
 import (
 	"fmt"
+	"net"
 	"strings"
 
 	"github.com/docker/machine/libmachine/drivers"
@@ -40,6 +41,12 @@ func (d *Driver) setImageArch(arch string) error {
 }
 
 func (d *Driver) verifyImageFlags() error {
+	if id, ok := numericID(d.Image); ok {
+		if d.ImageID != 0 {
+			return d.flagFailure("--%v and --%v are mutually exclusive", flagImage, flagImageID)
+		}
+		d.Image, d.ImageID = "", id
+	}
 	if d.ImageID != 0 && d.Image != "" && !isDefaultImageName(d.Image) /* support legacy behaviour */ {
 		return d.flagFailure("--%v and --%v are mutually exclusive", flagImage, flagImageID)
 	} else if d.ImageID != 0 && d.ImageArch != "" {
@@ -63,6 +70,92 @@ func (d *Driver) verifyNetworkFlags() error {
 	if d.DisablePublic6 && d.PrimaryIPv6 != "" {
 		return d.flagFailure("--%v and --%v are mutually exclusive", flagPrimary6, flagDisablePublic6)
 	}
+
+	if d.FloatingIP != "" && d.UsePrivateNetwork {
+		return d.flagFailure("--%v and --%v are mutually exclusive", flagFloatingIP, flagUsePrivateNetwork)
+	}
+	if d.CreateFloatingIP {
+		if d.FloatingIP != "" {
+			return d.flagFailure("--%v and --%v are mutually exclusive", flagCreateFloatingIP, flagFloatingIP)
+		}
+		if d.UsePrivateNetwork {
+			return d.flagFailure("--%v and --%v are mutually exclusive", flagCreateFloatingIP, flagUsePrivateNetwork)
+		}
+	}
+
+	if d.VSwitchID != 0 && len(d.Networks) == 0 {
+		return d.flagFailure("--%v requires at least one --%v", flagVSwitchID, flagNetworks)
+	}
+
+	if d.VSwitchSubnet != "" {
+		if d.VSwitchID == 0 {
+			return d.flagFailure("--%v requires --%v to be set", flagVSwitchSubnet, flagVSwitchID)
+		}
+		if _, _, err := net.ParseCIDR(d.VSwitchSubnet); err != nil {
+			return d.flagFailure("--%v: invalid subnet %v: %v", flagVSwitchSubnet, d.VSwitchSubnet, err)
+		}
+	}
+
+	if d.reusePrimaryIPOf != "" && d.PrimaryIPv4 != "" && d.PrimaryIPv6 != "" {
+		return d.flagFailure("--%v is pointless if both --%v and --%v are given", flagReusePrimaryIPOf, flagPrimary4, flagPrimary6)
+	}
+
+	if d.PrimaryIPPool != "" {
+		if d.Location == "" && len(d.locationCandidates) == 0 && !d.locationAuto {
+			return d.flagFailure("--%v requires --%v to be set", flagPrimaryIPPool, flagLocation)
+		}
+		if ok, err := hcloud.ValidateResourceLabels(map[string]interface{}{d.labelName(labelPrimaryIPPool): d.PrimaryIPPool}); !ok {
+			return d.flagFailure("--%v: invalid pool name: %v", flagPrimaryIPPool, err)
+		}
+	}
+	return nil
+}
+
+func (d *Driver) verifyKeyFingerprintFlags() error {
+	for _, fp := range d.AdditionalKeyFingerprints {
+		if !isValidFingerprint(fp) {
+			return d.flagFailure("--%v: %v is neither an MD5 nor a SHA256 fingerprint", flagAdditionalKeyFPs, fp)
+		}
+	}
+
+	if d.SSHAgentKey != "" {
+		if !isValidFingerprint(d.SSHAgentKey) {
+			return d.flagFailure("--%v: %v is neither an MD5 nor a SHA256 fingerprint", flagSSHAgentKey, d.SSHAgentKey)
+		}
+		if d.originalKey != "" {
+			return d.flagFailure("--%v and --%v are mutually exclusive", flagSSHAgentKey, flagExKeyPath)
+		}
+	}
+	return nil
+}
+
+// verifyAdditionalKeyFlags validates literal and file based additional keys; key source references are resolved during
+// the pre-create check
+func (d *Driver) verifyAdditionalKeyFlags() error {
+	for i, value := range d.AdditionalKeys {
+		if _, _, ok := splitKeySource(value); ok {
+			continue
+		}
+
+		origin, data := d.readLocalAdditionalKey(i, value)
+		keys, err := parseAuthorizedKeys(origin, data)
+		if err != nil {
+			return d.flagFailure("--%v: %v", flagAdditionalKeys, err)
+		}
+		if len(keys) == 0 {
+			return d.flagFailure("--%v: %v: no public keys found", flagAdditionalKeys, origin)
+		}
+	}
+	return nil
+}
+
+func (d *Driver) verifyRobotFlags() error {
+	if (d.RobotUser == "" || d.RobotPassword == "") && !d.offline {
+		return d.flagFailure("--%v requires --%v and --%v to be set", flagRobot, flagRobotUser, flagRobotPassword)
+	}
+	if d.RobotServerNumber == 0 {
+		return d.flagFailure("--%v requires --%v to be set", flagRobot, flagRobotServer)
+	}
 	return nil
 }
 
@@ -79,6 +172,11 @@ func (d *Driver) setUserDataFlags(opts drivers.DriverOptions) error {
 	userData := opts.String(flagUserData)
 	userDataFile := opts.String(flagUserDataFile)
 
+	d.userDataMerges = parseUserDataMergeFiles(opts.StringSlice(flagUserDataMergeFile))
+	if len(d.userDataMerges) != 0 && userData == "" && userDataFile == "" {
+		return d.flagFailure("--%v requires --%v or --%v to merge into", flagUserDataMergeFile, flagUserData, flagUserDataFile)
+	}
+
 	if opts.Bool(legacyFlagUserDataFromFile) {
 		if userDataFile != "" {
 			return d.flagFailure("--%v and --%v are mutually exclusive", flagUserDataFile, legacyFlagUserDataFromFile)
@@ -0,0 +1,212 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExportFlags writes the stored configuration of the machine to w as a config file, see --hetzner-config-file, so
+// machines with the same settings can be created from it; the API token, machine-specific resources like primary IPs
+// and options only used during creation are not stored and therefore not exported, and options choosing among
+// alternatives, like location lists or minimum resources, are exported as the choice made
+func (d *Driver) ExportFlags(w io.Writer) error {
+	if d.Robot {
+		return errors.New("flags of robot servers cannot be exported")
+	}
+
+	buf, err := yaml.Marshal(d.exportedFlags())
+	if err != nil {
+		return fmt.Errorf("could not serialize flags: %w", err)
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// ImportFlags reads the config file at path and writes the equivalent command line flags for `docker-machine create`
+// to w, one per line
+func (d *Driver) ImportFlags(w io.Writer, path string) error {
+	raw, err := readConfigFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file %v: %w", path, err)
+	}
+	values, err := d.configFileValues(raw, "config file "+path, flagConfigFile)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range commandLineFlags(values) {
+		if _, err = fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportedFlags returns the config file values, keyed by flag name without prefix, of the stored configuration, omitting
+// those which are not set or match the default
+func (d *Driver) exportedFlags() map[string]interface{} {
+	flags := map[string]interface{}{
+		flagProject:           d.Project,
+		flagProjectsFile:      d.ProjectsFile,
+		flagFailoverProject:   d.FailoverProject,
+		flagType:              d.Type,
+		flagLocation:          d.Location,
+		flagServerLocations:   d.ServerLocations,
+		flagSSHAgentKey:       d.SSHAgentKey,
+		flagSSHKeyName:        d.SSHKeyName,
+		flagKeyLimit:          d.KeyLimit,
+		flagPackageMirror:     d.PackageMirror,
+		flagVolumes:           d.Volumes,
+		flagNetworkIPStrategy: d.NetworkIPStrategy,
+		flagNetworkIPWebhook:  d.NetworkIPWebhook,
+		flagFirewalls:         d.Firewalls,
+		flagFirewallLabel:     d.FirewallLabel,
+		flagLoadBalancers:     d.LoadBalancers,
+		flagLoadBalancerLabel: d.LoadBalancerLabel,
+		flagAutoLoadBalancer:  d.AutoLoadBalancer,
+		flagFirewallPreset:    d.FirewallPreset,
+		flagFirewallSSHSource: d.FirewallSources,
+		flagControllerIPURL:   d.ControllerIPURL,
+		flagUsePrivateNetwork: d.UsePrivateNetwork,
+		flagDisablePublic4:    d.DisablePublic4,
+		flagDisablePublic6:    d.DisablePublic6,
+		flagPrimaryIPPool:     d.PrimaryIPPool,
+		flagFloatingIP:        d.FloatingIP,
+		flagCreateFloatingIP:  d.CreateFloatingIP,
+		flagEngineLabels:      d.EngineLabels,
+		flagEnableBackups:     d.EnableBackup,
+		flagBootRescue:        d.BootRescue,
+		flagISO:               d.ISO,
+		flagProtection:        d.Protection,
+		flagForceRemove:       d.ForceRemove,
+		flagGuardLabel:        d.GuardLabel,
+		flagDescription:       d.Description,
+		flagVSwitchSubnet:     d.VSwitchSubnet,
+		flagVSwitchExpose:     d.VSwitchExposeRoutes,
+		flagAdditionalKeys:    d.AdditionalKeys,
+		flagAdditionalKeyFPs:  d.AdditionalKeyFingerprints,
+		flagSshUser:           d.SSHUser,
+		flagSshPort:           d.SSHPort,
+
+		flagWaitOnError:           d.WaitOnError,
+		flagWaitOnPolling:         d.WaitOnPolling,
+		flagWaitForRunningTimeout: d.WaitForRunningTimeout,
+		flagActionTimeout:         d.ActionTimeout,
+		flagMaxConcurrentRequests: d.MaxConcurrentRequests,
+		flagAPICABundle:           d.APICABundle,
+		flagAPITLSMinVersion:      d.APITLSMinVersion,
+		flagStateGracePeriod:      d.StateGracePeriod,
+		flagTrafficWarning:        d.TrafficWarning,
+		flagMetricsFile:           d.MetricsFile,
+		flagMetricsPushgateway:    d.MetricsPushgateway,
+		flagDebugAPIPayloads:      d.DebugAPIPayloads,
+	}
+
+	if len(d.ServerLocations) > 0 {
+		// the location reached would conflict with the locations it was chosen from
+		delete(flags, flagLocation)
+	}
+	if d.Image != "" {
+		flags[flagImage] = d.Image
+		flags[flagImageArch] = string(d.ImageArch)
+	} else if d.ImageID != 0 {
+		flags[flagImageID] = strconv.FormatInt(d.ImageID, 10)
+	}
+	if d.IsExistingKey {
+		flags[flagExKeyID] = strconv.FormatInt(d.KeyID, 10)
+	}
+	if d.VSwitchID != 0 {
+		flags[flagVSwitchID] = strconv.FormatInt(d.VSwitchID, 10)
+	}
+
+	networks := make([]string, 0, len(d.Networks))
+	for _, network := range d.Networks {
+		if ip := d.NetworkIPs[network]; ip != "" {
+			network += ":" + ip
+		}
+		networks = append(networks, network)
+	}
+	flags[flagNetworks] = networks
+
+	// labels managed by the driver, e.g. controller labels, are set again according to the flags
+	labels := make(map[string]string)
+	for key, value := range d.ServerLabels {
+		if !strings.HasPrefix(key, labelNamespace+"/") {
+			labels[key] = value
+		}
+	}
+	flags[flagServerLabel] = labels
+
+	defaults := make(map[string]interface{})
+	for _, flag := range d.GetCreateFlags() {
+		defaults[flag.String()] = flag.Default()
+	}
+
+	ret := make(map[string]interface{}, len(flags))
+	for name, value := range flags {
+		if isUnsetFlagValue(value, defaults[name]) {
+			continue
+		}
+		ret[strings.TrimPrefix(name, flagPrefix)] = value
+	}
+	return ret
+}
+
+func isUnsetFlagValue(value, def interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return v == "" || v == def
+	case int:
+		return v == 0 || v == def
+	case bool:
+		return !v
+	case []string:
+		return len(v) == 0
+	case map[string]string:
+		return len(v) == 0
+	}
+	return false
+}
+
+// commandLineFlags returns the command line flags setting the given flag values, sorted by flag name
+func commandLineFlags(values map[string]interface{}) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ret []string
+	for _, name := range names {
+		switch v := values[name].(type) {
+		case bool:
+			if v {
+				ret = append(ret, "--"+name)
+			}
+		case []string:
+			for _, elem := range v {
+				ret = append(ret, fmt.Sprintf("--%v %v", name, shellQuote(elem)))
+			}
+		default:
+			ret = append(ret, fmt.Sprintf("--%v %v", name, shellQuote(fmt.Sprint(v))))
+		}
+	}
+	return ret
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./:=,@+%-]+$`)
+
+// shellQuote quotes s for POSIX shells unless it consists of safe characters only
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
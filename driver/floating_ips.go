@@ -0,0 +1,203 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// floatingIPInterface is the public interface of Hetzner Cloud servers, which floating IPs are routed to
+const floatingIPInterface = "eth0"
+
+// getFloatingIP resolves the existing floating IP of --hetzner-floating-ip by ID, name or address
+func (d *Driver) getFloatingIP() (*hcloud.FloatingIP, error) {
+	if d.FloatingIP == "" || d.cachedFloatingIP != nil {
+		return d.cachedFloatingIP, nil
+	}
+
+	ip, _, err := d.getClient().FloatingIP.Get(context.Background(), d.FloatingIP)
+	if err != nil {
+		return nil, fmt.Errorf("could not get floating IP by ID or name: %w", err)
+	}
+	if addr := net.ParseIP(d.FloatingIP); ip == nil && addr != nil {
+		ips, err := d.getClient().FloatingIP.All(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("could not list floating IPs: %w", err)
+		}
+		for _, candidate := range ips {
+			if floatingIPMatches(candidate, addr) {
+				ip = candidate
+				break
+			}
+		}
+	}
+	if ip == nil {
+		return nil, notFoundError("floating IP", d.FloatingIP)
+	}
+
+	d.cachedFloatingIP = ip
+	return ip, nil
+}
+
+// floatingIPMatches reports whether addr is the floating IPv4 address, or within the floating IPv6 network
+func floatingIPMatches(ip *hcloud.FloatingIP, addr net.IP) bool {
+	if ip.Network != nil {
+		return ip.Network.Contains(addr)
+	}
+	return ip.IP.Equal(addr)
+}
+
+// floatingIPAddress returns the address of the floating IP the machine is reached by, which is the first host address
+// for IPv6, and its prefix length
+func floatingIPAddress(ip *hcloud.FloatingIP) (string, int) {
+	if ip.Type == hcloud.FloatingIPTypeIPv6 && ip.Network != nil {
+		ones, _ := ip.Network.Mask.Size()
+		return publicIPv6Address(hcloud.ServerPublicNetIPv6{IP: ip.IP, Network: ip.Network}), ones
+	}
+	return ip.IP.String(), 32
+}
+
+// verifyFloatingIP makes sure the floating IP exists and is not assigned to another server, which would lose it
+func (d *Driver) verifyFloatingIP() error {
+	ip, err := d.getFloatingIP()
+	if err != nil || ip == nil {
+		return err
+	}
+	if ip.Server != nil && ip.Server.ID != 0 {
+		return fmt.Errorf("floating IP %v[%d] is already assigned to server %d", ip.IP, ip.ID, ip.Server.ID)
+	}
+	return nil
+}
+
+// assignFloatingIP assigns the floating IP of --hetzner-floating-ip, or one created for the machine with
+// --hetzner-create-floating-ip, to the server and configures it on the server's public interface, then makes it the
+// machine's address
+func (d *Driver) assignFloatingIP(srv *hcloud.Server) error {
+	var ip *hcloud.FloatingIP
+	var err error
+	if d.CreateFloatingIP {
+		ip, err = d.createMachineFloatingIP(srv)
+	} else {
+		ip, err = d.assignExistingFloatingIP(srv)
+	}
+	if err != nil || ip == nil {
+		return err
+	}
+	d.FloatingIPID = ip.ID
+
+	// the server is still reached by its primary IP until the floating IP is configured
+	addr, prefix := floatingIPAddress(ip)
+	if err = d.waitForSSH(); err != nil {
+		return err
+	}
+	sudo := ""
+	if d.GetSSHUsername() != "root" {
+		sudo = "sudo "
+	}
+	if _, err = drivers.RunSSHCommandFromDriver(d, floatingIPCommand(sudo, ip.Type, addr, prefix)); err != nil {
+		return fmt.Errorf("could not configure floating IP on server: %w", err)
+	}
+
+	d.IPAddress = addr
+	return nil
+}
+
+func (d *Driver) assignExistingFloatingIP(srv *hcloud.Server) (*hcloud.FloatingIP, error) {
+	ip, err := d.getFloatingIP()
+	if err != nil || ip == nil {
+		return nil, err
+	}
+
+	log.Infof(" -> Assigning floating IP %v[%d] ...", ip.IP, ip.ID)
+	act, _, err := d.getClient().FloatingIP.Assign(context.Background(), ip, srv)
+	if err != nil {
+		return nil, fmt.Errorf("could not assign floating IP: %w", err)
+	}
+	if err = d.waitForAction(act); err != nil {
+		return nil, fmt.Errorf("could not wait for floating IP assignment: %w", err)
+	}
+	return ip, nil
+}
+
+// createMachineFloatingIP creates an IPv4 floating IP assigned to the server, labeled with the machine's name; it is
+// deleted again if the creation fails, and when the machine is removed
+func (d *Driver) createMachineFloatingIP(srv *hcloud.Server) (*hcloud.FloatingIP, error) {
+	res, _, err := d.getClient().FloatingIP.Create(context.Background(), hcloud.FloatingIPCreateOpts{
+		Type:        hcloud.FloatingIPTypeIPv4,
+		Server:      srv,
+		Description: hcloud.Ptr(d.GetMachineName()),
+		Labels: map[string]string{
+			d.labelName(labelMachine):     d.GetMachineName(),
+			d.labelName(labelAutoCreated): "true",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create floating IP: %w", err)
+	}
+	ip := res.FloatingIP
+	log.Infof(" -> Created floating IP %v[%d]", ip.IP, ip.ID)
+
+	d.FloatingIPCreated = true
+	d.trackDangling("floating IP", func() error {
+		_, err := d.getClient().FloatingIP.Delete(context.Background(), ip)
+		return err
+	})
+
+	if res.Action != nil {
+		if err = d.waitForAction(res.Action); err != nil {
+			return nil, fmt.Errorf("could not wait for floating IP assignment: %w", err)
+		}
+	}
+	return ip, nil
+}
+
+// removeFloatingIP deletes the floating IP created for the machine; floating IPs given by --hetzner-floating-ip are
+// kept, as they were merely unassigned along with the server
+func (d *Driver) removeFloatingIP() error {
+	if !d.FloatingIPCreated || d.FloatingIPID == 0 {
+		return nil
+	}
+
+	ip, _, err := d.getClient().FloatingIP.GetByID(context.Background(), d.FloatingIPID)
+	if err != nil {
+		return fmt.Errorf("could not get floating IP %d: %w", d.FloatingIPID, err)
+	}
+	if ip == nil {
+		return nil
+	}
+
+	log.Infof(" -> Destroying floating IP %v[%d] ...", ip.IP, ip.ID)
+	err = d.retry("floating IP deletion", func() error {
+		_, err := d.getClient().FloatingIP.Delete(context.Background(), ip)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not delete floating IP: %w", err)
+	}
+	return nil
+}
+
+// floatingIPCommand returns the shell command adding the floating IP to the public interface, persisting it with
+// netplan or ifupdown, whichever the image uses
+func floatingIPCommand(sudo string, ipType hcloud.FloatingIPType, addr string, prefix int) string {
+	family := "inet"
+	if ipType == hcloud.FloatingIPTypeIPv6 {
+		family = "inet6"
+	}
+	netplan := fmt.Sprintf("network:\n  version: 2\n  ethernets:\n    %v:\n      addresses:\n      - %v/%d\n",
+		floatingIPInterface, addr, prefix)
+	ifupdown := fmt.Sprintf("auto %[1]v:1\niface %[1]v:1 %[2]v static\n    address %[3]v\n    netmask %[4]d\n",
+		floatingIPInterface, family, addr, prefix)
+
+	return strings.Join([]string{
+		fmt.Sprintf("%vip addr replace %v/%d dev %v", sudo, addr, prefix, floatingIPInterface),
+		fmt.Sprintf("if [ -d /etc/netplan ]; then printf '%%s' '%v' | %vtee /etc/netplan/60-floating-ip.yaml >/dev/null && %vchmod 600 /etc/netplan/60-floating-ip.yaml; "+
+			"elif [ -d /etc/network/interfaces.d ]; then printf '%%s' '%v' | %vtee /etc/network/interfaces.d/60-floating-ip.cfg >/dev/null; fi",
+			netplan, sudo, sudo, ifupdown, sudo),
+	}, " && ")
+}
@@ -0,0 +1,53 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	labelProtect = "protect"
+
+	// envForceRemove forces a single removal, as docker-machine passes no flags to Remove; it is deliberately not the
+	// environment variable of --hetzner-force-remove, which would otherwise be stored with every machine created
+	// while it is set
+	envForceRemove = "HETZNER_FORCE_REMOVE"
+)
+
+// setGuardLabelFlag validates --hetzner-guard-label, given as key=value or as key to match any value
+func (d *Driver) setGuardLabelFlag(label string) error {
+	d.GuardLabel = label
+	if key, _, _ := strings.Cut(label, "="); label != "" && key == "" {
+		return d.flagFailure("--%v must be a key or key=value pair: %v", flagGuardLabel, label)
+	}
+	return nil
+}
+
+// guardLabel returns the configured guard label, defaulting to docker-machine/protect=true also for machines created
+// before the label was configurable
+func (d *Driver) guardLabel() string {
+	if d.GuardLabel == "" {
+		return d.labelName(labelProtect) + "=true"
+	}
+	return d.GuardLabel
+}
+
+// isGuarded reports whether the server carries the guard label
+func (d *Driver) isGuarded(srv *hcloud.Server) bool {
+	key, value, hasValue := strings.Cut(d.guardLabel(), "=")
+	actual, ok := srv.Labels[key]
+	return ok && (!hasValue || actual == value)
+}
+
+// checkRemovalGuard refuses to remove a server carrying the guard label, unless the removal is forced by
+// HETZNER_FORCE_REMOVE; unlike the protection, --hetzner-force-remove does not bypass it, as the label is meant to guard
+// the server against removal whatever the machine was created with
+func (d *Driver) checkRemovalGuard(srv *hcloud.Server) error {
+	if srv == nil || !d.isGuarded(srv) || forceRemoveOnce() {
+		return nil
+	}
+	return fmt.Errorf("server %v[%d] is protected by label %v; remove the label or set %v=true to remove it anyway",
+		srv.Name, srv.ID, d.guardLabel(), envForceRemove)
+}
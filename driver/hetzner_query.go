@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/docker/machine/libmachine/log"
@@ -12,15 +14,29 @@ import (
 )
 
 func (d *Driver) getClient() *hcloud.Client {
+	httpClient := &http.Client{}
+	d.setupClientTLS(httpClient)
 	opts := []hcloud.ClientOption{
-		hcloud.WithToken(d.AccessToken),
+		hcloud.WithToken(d.apiToken()),
 		hcloud.WithApplication("docker-machine-driver", d.version),
 		hcloud.WithPollBackoffFunc(hcloud.ConstantBackoff(time.Duration(d.WaitOnPolling) * time.Second)),
+		hcloud.WithHTTPClient(httpClient),
+	}
+	if d.endpoint != "" {
+		opts = append(opts, hcloud.WithEndpoint(d.endpoint))
 	}
 
 	opts = d.setupClientInstrumentation(opts)
 
-	return hcloud.NewClient(opts...)
+	client := hcloud.NewClient(opts...)
+
+	// wrap the transport set up for TLS, or the default one
+	d.setupClientMetrics(httpClient)
+	d.setupClientConcurrencyLimit(httpClient)
+	d.setupClientTracing(httpClient)
+	d.setupClientUsageTracking(httpClient)
+
+	return client
 }
 
 func (d *Driver) getLocationNullable() (*hcloud.Location, error) {
@@ -36,7 +52,7 @@ func (d *Driver) getLocationNullable() (*hcloud.Location, error) {
 		return nil, fmt.Errorf("could not get location by name: %w", err)
 	}
 	if location == nil {
-		return nil, fmt.Errorf("unknown location: %v", d.Location)
+		return nil, fmt.Errorf("unknown location: %v%v", d.Location, d.suggestLocations(d.Location))
 	}
 	d.cachedLocation = location
 	return location, nil
@@ -52,7 +68,7 @@ func (d *Driver) getType() (*hcloud.ServerType, error) {
 		return nil, fmt.Errorf("could not get type by name: %w", err)
 	}
 	if stype == nil {
-		return nil, fmt.Errorf("unknown server type: %v", d.Type)
+		return nil, fmt.Errorf("unknown server type: %v%v", d.Type, d.suggestServerTypes(d.Type))
 	}
 	d.cachedType = stype
 	return instrumented(stype), nil
@@ -82,17 +98,35 @@ func (d *Driver) getImage() (*hcloud.Image, error) {
 
 		image, _, err = d.getClient().Image.GetByNameAndArchitecture(context.Background(), d.Image, arch)
 		if err != nil {
-			return nil, fmt.Errorf("could not get image by name %v: %w", d.Image, err)
+			return nil, fmt.Errorf("could not get image %v for architecture %v: %w", d.Image, arch, err)
 		}
 		if image == nil {
-			return nil, fmt.Errorf("image not found: %v[%v]", d.Image, arch)
+			if d.ImageArch == emptyImageArchitecture {
+				if err = d.checkImageArchitectureMismatch(arch); err != nil {
+					return nil, err
+				}
+			}
+			return nil, fmt.Errorf("image %v not found for architecture %v%v", d.Image, arch, d.suggestImages(d.Image, arch))
 		}
+		d.pinImage(image)
 	}
 
 	d.cachedImage = image
 	return instrumented(image), nil
 }
 
+// pinImage stores the concrete image resolved by name in the machine config, so later operations and audits refer to
+// exactly the image used at creation even after its name has been moved to a newer build
+func (d *Driver) pinImage(image *hcloud.Image) {
+	log.Infof(" -> Using image %v[%d] (%v, created %v)", image.Name, image.ID, image.Architecture, image.Created.Format(time.RFC3339))
+
+	d.ImageID = image.ID
+	if image.Name != "" {
+		d.Image = image.Name
+	}
+	d.ImageArch = image.Architecture
+}
+
 func (d *Driver) getImageArchitectureForLookup() (hcloud.Architecture, error) {
 	if d.ImageArch != emptyImageArchitecture {
 		return d.ImageArch, nil
@@ -106,6 +140,31 @@ func (d *Driver) getImageArchitectureForLookup() (hcloud.Architecture, error) {
 	return serverType.Architecture, nil
 }
 
+// checkImageArchitectureMismatch returns an error if the image, which does not exist for the architecture of the server
+// type, exists for another architecture, as then the server type rather than the image name is likely wrong
+func (d *Driver) checkImageArchitectureMismatch(arch hcloud.Architecture) error {
+	for _, other := range []hcloud.Architecture{hcloud.ArchitectureX86, hcloud.ArchitectureARM} {
+		if other == arch {
+			continue
+		}
+		image, _, err := d.getClient().Image.GetByNameAndArchitecture(context.Background(), d.Image, other)
+		if err != nil {
+			log.Debugf("could not get image %v for architecture %v: %v", d.Image, other, err)
+			continue
+		}
+		if image != nil {
+			return imageArchitectureMismatchError(d.Image, d.Type, arch, other)
+		}
+	}
+	return nil
+}
+
+func imageArchitectureMismatchError(image, serverType string, typeArch, imageArch hcloud.Architecture) error {
+	return fmt.Errorf("image %v is not available for architecture %v of server type %v, only for %v; choose a server "+
+		"type of architecture %v and pass --%v %v to select the image explicitly", image, typeArch, serverType, imageArch,
+		imageArch, flagImageArch, imageArch)
+}
+
 func (d *Driver) getKey() (*hcloud.SSHKey, error) {
 	key, err := d.getKeyNullable()
 	if err != nil {
@@ -142,9 +201,58 @@ func (d *Driver) getRemoteKeyWithSameFingerprintNullable(publicKeyBytes []byte)
 	if err != nil {
 		return remoteKey, fmt.Errorf("could not get sshkey by fingerprint: %w", err)
 	}
+
+	if remoteKey == nil && isSecurityKey(publicKey) {
+		// fingerprints of security keys are not reliably matched by the API, so compare the key material instead
+		return d.getRemoteKeyWithSameKeyMaterialNullable(publicKey)
+	}
 	return instrumented(remoteKey), nil
 }
 
+func (d *Driver) getRemoteKeyWithSameKeyMaterialNullable(publicKey ssh.PublicKey) (*hcloud.SSHKey, error) {
+	keys, err := d.getClient().SSHKey.All(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("could not list ssh keys: %w", err)
+	}
+
+	for _, key := range keys {
+		if remoteKeyMatches(key, publicKey) {
+			return instrumented(key), nil
+		}
+	}
+	return nil, nil
+}
+
+func (d *Driver) getRemoteKeyByFingerprint(fp string) (*hcloud.SSHKey, error) {
+	if !isSHA256Fingerprint(fp) {
+		key, _, err := d.getClient().SSHKey.GetByFingerprint(context.Background(), strings.ToLower(strings.TrimPrefix(fp, md5FingerprintPrefix)))
+		if err != nil {
+			return nil, fmt.Errorf("could not get sshkey by fingerprint: %w", err)
+		}
+		if key == nil {
+			return nil, fmt.Errorf("no key with fingerprint %v", fp)
+		}
+		return instrumented(key), nil
+	}
+
+	// the API only supports filtering by MD5, so SHA256 fingerprints have to be matched locally
+	keys, err := d.getClient().SSHKey.All(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("could not list ssh keys: %w", err)
+	}
+	for _, key := range keys {
+		pubk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key.PublicKey))
+		if err != nil {
+			log.Debugf("could not parse remote key %v[%d]: %v", key.Name, key.ID, err)
+			continue
+		}
+		if ssh.FingerprintSHA256(pubk) == fp {
+			return instrumented(key), nil
+		}
+	}
+	return nil, fmt.Errorf("no key with fingerprint %v", fp)
+}
+
 func (d *Driver) getServerHandle() (*hcloud.Server, error) {
 	srv, err := d.getServerHandleNullable()
 	if err != nil {
@@ -174,52 +282,144 @@ func (d *Driver) getServerHandleNullable() (*hcloud.Server, error) {
 	return srv, nil
 }
 
-func (d *Driver) waitForAction(a *hcloud.Action) error {
-	progress, done := d.getClient().Action.WatchProgress(context.Background(), a)
+// getServerForState retrieves the server for state queries, retrying not found and transient errors during the
+// configured grace period to ride out API inconsistencies
+func (d *Driver) getServerForState() (*hcloud.Server, error) {
+	deadline := time.Now().Add(time.Duration(d.StateGracePeriod) * time.Second)
+	for {
+		srv, _, err := d.getClient().Server.GetByID(context.Background(), d.ServerID)
+		if (err == nil && srv != nil) || (err != nil && !isRetryableError(err)) || time.Now().After(deadline) {
+			return srv, err
+		}
 
-	running := true
-	var ret error
+		log.Debugf("server %d not found or transient error (%v), retrying within grace period", d.ServerID, err)
+		time.Sleep(time.Duration(max(d.WaitOnPolling, 1)) * time.Second)
+	}
+}
 
-	for running {
-		select {
-		case <-done:
-			ret = <-done
-			running = false
-		case <-progress:
-			log.Debugf(" -> %s[%d]: %d %%", a.Command, a.ID, <-progress)
+func (d *Driver) waitForAction(a *hcloud.Action) error {
+	err := d.watchActions(func(update *hcloud.Action) error {
+		log.Debugf(" -> %s[%d]: %d %%", update.Command, update.ID, update.Progress)
+		if update.Status == hcloud.ActionStatusError {
+			return update.Error()
 		}
+		return nil
+	}, a)
+	if err != nil {
+		return err
 	}
 
-	if ret == nil {
-		log.Debugf(" -> finished %s[%d]", a.Command, a.ID)
+	log.Debugf(" -> finished %s[%d]", a.Command, a.ID)
+	return nil
+}
+
+func (d *Driver) waitForMultipleActions(step string, a []*hcloud.Action) error {
+	var ret error
+	err := d.watchActions(func(update *hcloud.Action) error {
+		log.Debugf(" -> %s: %s[%d]: %d %%", step, update.Command, update.ID, update.Progress)
+		if update.Status == hcloud.ActionStatusError {
+			ret = errors.Join(ret, update.Error())
+		}
+		return nil
+	}, a...)
+	if err != nil {
+		return err
 	}
 
-	return ret
+	if ret != nil {
+		return d.describeActionFailures(a, ret)
+	}
+	log.Debugf(" -> finished %s", step)
+	return nil
 }
 
-func (d *Driver) waitForMultipleActions(step string, a []*hcloud.Action) error {
-	ctx, cancel := context.WithCancel(context.Background())
+// watchActions polls the actions at the --hetzner-wait-on-polling interval until all of them completed, passing each
+// update to handleUpdate, for at most --hetzner-action-timeout seconds if set
+func (d *Driver) watchActions(handleUpdate func(update *hcloud.Action) error, actions ...*hcloud.Action) error {
+	ctx, cancel := d.actionContext()
 	defer cancel()
-	progress, watchErr := d.getClient().Action.WatchOverallProgress(ctx, a)
 
-	running := true
-	var ret error
+	err := d.getClient().Action.WaitForFunc(ctx, handleUpdate, actions...)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("actions did not complete within %d seconds: %w", d.ActionTimeout, err)
+	}
+	return err
+}
+
+// actionContext returns the context for waiting for actions, which expires after --hetzner-action-timeout seconds if set
+// and is cancelled on shutdown
+func (d *Driver) actionContext() (context.Context, context.CancelFunc) {
+	if d.ActionTimeout > 0 {
+		return context.WithTimeout(d.shutdownContext(), time.Duration(d.ActionTimeout)*time.Second)
+	}
+	return context.WithCancel(d.shutdownContext())
+}
 
-	for running {
-		select {
-		case <-watchErr:
-			ret = errors.Join(ret, <-watchErr)
-			cancel()
-		case <-progress:
-			log.Debugf(" -> %s: %d %%", step, <-progress)
-		default:
-			running = false
+// describeActionFailures replaces the error of watching the actions with one naming the failed actions and the
+// resources they affected; if the actions cannot be queried, the original error is returned
+func (d *Driver) describeActionFailures(actions []*hcloud.Action, watchErr error) error {
+	failed, err := d.failedActions(actions)
+	if err != nil {
+		log.Debugf("could not query failed actions: %v", err)
+		return watchErr
+	}
+	if len(failed) == 0 {
+		return watchErr
+	}
+
+	errs := make([]error, 0, len(failed))
+	for _, a := range failed {
+		errs = append(errs, failedActionError(a, d.actionResourceName))
+	}
+	return errors.Join(errs...)
+}
+
+// failedActions returns the current state of those of the given actions which failed
+func (d *Driver) failedActions(actions []*hcloud.Action) ([]*hcloud.Action, error) {
+	ids := make([]int64, 0, len(actions))
+	for _, a := range actions {
+		ids = append(ids, a.ID)
+	}
+	current, err := d.getClient().Action.AllWithOpts(context.Background(), hcloud.ActionListOpts{ID: ids})
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []*hcloud.Action
+	for _, a := range current {
+		if a.Status == hcloud.ActionStatusError {
+			failed = append(failed, a)
 		}
 	}
+	return failed, nil
+}
 
-	if ret == nil {
-		log.Debugf(" -> finished %s", step)
+// failedActionError describes a failed action by its command and the resources it affected besides the server
+func failedActionError(a *hcloud.Action, resourceName func(*hcloud.ActionResource) string) error {
+	var resources []string
+	for _, res := range a.Resources {
+		if res.Type != hcloud.ActionResourceTypeServer {
+			resources = append(resources, fmt.Sprintf("%v %v", res.Type, resourceName(res)))
+		}
+	}
+	if len(resources) == 0 {
+		return fmt.Errorf("%v[%d] failed: %w", a.Command, a.ID, a.Error())
 	}
+	return fmt.Errorf("%v[%d] of %v failed: %w", a.Command, a.ID, strings.Join(resources, ", "), a.Error())
+}
 
-	return ret
+// actionResourceName returns the name and ID of a volume or network affected by an action, or only its ID
+func (d *Driver) actionResourceName(res *hcloud.ActionResource) string {
+	var name string
+	switch res.Type {
+	case hcloud.ActionResourceTypeVolume:
+		if volume, _, err := d.getClient().Volume.GetByID(context.Background(), res.ID); err == nil && volume != nil {
+			name = volume.Name
+		}
+	case hcloud.ActionResourceType("network"):
+		if network, _, err := d.getClient().Network.GetByID(context.Background(), res.ID); err == nil && network != nil {
+			name = network.Name
+		}
+	}
+	return fmt.Sprintf("%v[%d]", name, res.ID)
 }
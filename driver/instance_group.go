@@ -0,0 +1,209 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	labelInstanceGroup = "instance-group"
+
+	// instanceGroupStoreDir is the directory within the docker-machine store holding the local state of instance groups
+	instanceGroupStoreDir = "hetzner-instance-groups"
+
+	// instanceDriverFile holds the driver configuration of an instance within its machine directory
+	instanceDriverFile = "driver.json"
+)
+
+// InstanceGroup manages a group of machines for autoscalers embedding the driver, e.g. the Nomad autoscaler or custom
+// controllers, without going through docker-machine; the servers of the group carry a label naming it, while the driver
+// configuration and SSH key of each instance are kept in a subdirectory of the docker-machine store
+type InstanceGroup struct {
+	name      string
+	storePath string
+	version   string
+	configure func(d *Driver) error
+	client    *hcloud.Client
+}
+
+// Instance is a member of an instance group, identified by its machine name
+type Instance struct {
+	ID       string
+	ServerID int64
+	Status   hcloud.ServerStatus
+}
+
+// NewInstanceGroup returns the instance group of the given name, whose instances are configured by configure, usually
+// calling [Driver.SetConfigFromFlags]; configure is validated once on a scratch driver, which also provides the API
+// client of the group
+func NewInstanceGroup(name, storePath, version string, configure func(d *Driver) error) (*InstanceGroup, error) {
+	d := NewDriver(version)
+	if name == "" {
+		return nil, errors.New("a group name is required")
+	}
+	if ok, err := hcloud.ValidateResourceLabels(map[string]interface{}{d.labelName(labelInstanceGroup): name}); !ok {
+		return nil, fmt.Errorf("invalid group name: %w", err)
+	}
+	if err := configure(d); err != nil {
+		return nil, err
+	}
+
+	g := &InstanceGroup{
+		name:      name,
+		storePath: filepath.Join(storePath, instanceGroupStoreDir, name),
+		version:   version,
+		configure: configure,
+		client:    d.getClient(),
+	}
+	if err := os.MkdirAll(g.storePath, 0700); err != nil {
+		return nil, fmt.Errorf("could not create instance group directory: %w", err)
+	}
+	return g, nil
+}
+
+// Name returns the name of the group
+func (g *InstanceGroup) Name() string {
+	return g.name
+}
+
+// List returns the instances of the group, as given by the labels of the servers
+func (g *InstanceGroup) List() ([]Instance, error) {
+	servers, err := g.client.Server.AllWithOpts(context.Background(), hcloud.ServerListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: g.labelSelector()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list servers of group %v: %w", g.name, err)
+	}
+
+	ret := make([]Instance, 0, len(servers))
+	for _, srv := range servers {
+		ret = append(ret, Instance{ID: srv.Name, ServerID: srv.ID, Status: srv.Status})
+	}
+	return ret, nil
+}
+
+// ScaleUp creates n instances concurrently and returns the IDs of those created successfully
+func (g *InstanceGroup) ScaleUp(n int) ([]string, error) {
+	var (
+		mu      sync.Mutex
+		created []string
+		errs    []error
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := g.create()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			created = append(created, id)
+		}()
+	}
+	wg.Wait()
+
+	return created, errors.Join(errs...)
+}
+
+// ScaleDown removes the given instances and returns the IDs of those removed successfully; guarded servers, see
+// --hetzner-guard-label, are not removed
+func (g *InstanceGroup) ScaleDown(ids []string) ([]string, error) {
+	var errs []error
+	removed := make([]string, 0, len(ids))
+	for _, id := range ids {
+		d, err := g.Driver(id)
+		if err == nil {
+			err = d.Remove()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not remove instance %v: %w", id, err))
+			continue
+		}
+		_ = os.RemoveAll(d.ResolveStorePath("."))
+		removed = append(removed, id)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// Driver returns the driver of an instance created by the group, e.g. to connect to it
+func (g *InstanceGroup) Driver(id string) (*Driver, error) {
+	d := g.newDriver(id)
+	raw, err := os.ReadFile(d.ResolveStorePath(instanceDriverFile))
+	if err != nil {
+		return nil, fmt.Errorf("could not read config of instance %v: %w", id, err)
+	}
+	if err = json.Unmarshal(raw, d); err != nil {
+		return nil, fmt.Errorf("could not parse config of instance %v: %w", id, err)
+	}
+	return d, nil
+}
+
+// create creates a single instance named after the group and stores its driver configuration
+func (g *InstanceGroup) create() (string, error) {
+	id := g.name + "-" + mcnutils.GenerateRandomID()[:8]
+
+	d := g.newDriver(id)
+	if err := g.configure(d); err != nil {
+		return "", err
+	}
+	if d.ServerLabels == nil {
+		d.ServerLabels = make(map[string]string)
+	}
+	d.ServerLabels[d.labelName(labelInstanceGroup)] = g.name
+
+	if err := os.MkdirAll(d.ResolveStorePath("."), 0700); err != nil {
+		return "", fmt.Errorf("could not create machine directory: %w", err)
+	}
+	err := d.PreCreateCheck()
+	if err == nil {
+		err = d.Create()
+	}
+	if err != nil {
+		_ = os.RemoveAll(d.ResolveStorePath("."))
+		return "", fmt.Errorf("could not create instance %v: %w", id, err)
+	}
+
+	if err = g.save(d); err != nil {
+		// without its stored configuration, the instance could never be scaled down, so it is removed right away; the
+		// machine directory holding its key is only removed along with it
+		err = fmt.Errorf("could not create instance %v: %w", id, err)
+		if rmErr := d.Remove(); rmErr != nil {
+			return "", errors.Join(err, fmt.Errorf("could not remove instance %v, remove it manually: %w", id, rmErr))
+		}
+		_ = os.RemoveAll(d.ResolveStorePath("."))
+		return "", err
+	}
+	return id, nil
+}
+
+func (g *InstanceGroup) newDriver(id string) *Driver {
+	d := NewDriver(g.version)
+	d.MachineName = id
+	d.StorePath = g.storePath
+	return d
+}
+
+func (g *InstanceGroup) save(d *Driver) error {
+	raw, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("could not serialize driver config: %w", err)
+	}
+	return os.WriteFile(d.ResolveStorePath(instanceDriverFile), raw, 0600)
+}
+
+func (g *InstanceGroup) labelSelector() string {
+	return labelNamespace + "/" + labelInstanceGroup + "=" + g.name
+}
@@ -0,0 +1,101 @@
+package driver
+
+import (
+	"encoding/json"
+	"regexp"
+	"runtime/debug"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const redacted = "[REDACTED]"
+
+// debugAPIPayloads enables logging of API payloads via instrumented; it is process-wide, as instrumented is called from
+// generic code paths, and set from --hetzner-debug-api-payloads at the start of each operation
+var debugAPIPayloads bool
+
+var (
+	// sensitiveKeys matches JSON keys whose values must not be logged
+	sensitiveKeys = regexp.MustCompile(`(?i)token|password|secret|user_?data`)
+
+	// sensitivePayload matches credentials and sensitive JSON values in raw HTTP dumps
+	sensitivePayload = regexp.MustCompile(`(?i)(authorization: \w+ )\S+|("[a-z_]*(?:token|password|secret|user_data)[a-z_]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+)
+
+// instrumented logs the given API input along with the calling stack if --hetzner-debug-api-payloads is set, redacting
+// sensitive values, and returns it unchanged
+func instrumented[T any](input T) T {
+	if !debugAPIPayloads {
+		return input
+	}
+
+	j, err := json.Marshal(input)
+	if err != nil {
+		log.Debugf("could not encode API payload: %v", err)
+		return input
+	}
+	log.Debugf("%v\n%v\n", string(debug.Stack()), string(redactJSON(j)))
+	return input
+}
+
+// redactJSON replaces the values of sensitive keys anywhere in the given JSON document; documents which cannot be
+// parsed are returned as-is
+func redactJSON(raw []byte) []byte {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return raw
+	}
+
+	var redact func(v interface{}) interface{}
+	redact = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, value := range v {
+				if sensitiveKeys.MatchString(key) && value != nil && value != "" {
+					v[key] = redacted
+				} else {
+					v[key] = redact(value)
+				}
+			}
+		case []interface{}:
+			for i, value := range v {
+				v[i] = redact(value)
+			}
+		}
+		return v
+	}
+
+	ret, err := json.Marshal(redact(doc))
+	if err != nil {
+		return raw
+	}
+	return ret
+}
+
+// redactPayload replaces credentials and sensitive JSON values in a raw HTTP dump
+func redactPayload(dump string) string {
+	return sensitivePayload.ReplaceAllStringFunc(dump, func(match string) string {
+		groups := sensitivePayload.FindStringSubmatch(match)
+		if groups[1] != "" {
+			return groups[1] + redacted
+		}
+		return groups[2] + `"` + redacted + `"`
+	})
+}
+
+type debugLogWriter struct {
+}
+
+func (x debugLogWriter) Write(data []byte) (int, error) {
+	log.Debug(strings.TrimRight(redactPayload(string(data)), "\n"))
+	return len(data), nil
+}
+
+func (d *Driver) setupClientInstrumentation(opts []hcloud.ClientOption) []hcloud.ClientOption {
+	if d.DebugAPIPayloads {
+		opts = append(opts, hcloud.WithDebugWriter(debugLogWriter{}))
+	}
+	return opts
+}
@@ -0,0 +1,132 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// InventoryServer describes the addresses, networks and labels of a server in the output of [Driver.Inventory]
+type InventoryServer struct {
+	ID         int64                `json:"id"`
+	Name       string               `json:"name"`
+	Status     string               `json:"status"`
+	Location   string               `json:"location,omitempty"`
+	PublicIPv4 string               `json:"public_ipv4,omitempty"`
+	PublicIPv6 string               `json:"public_ipv6,omitempty"`
+	PrimaryIPs []InventoryPrimaryIP `json:"primary_ips"`
+	Networks   []InventoryNetwork   `json:"networks"`
+	Labels     map[string]string    `json:"labels"`
+}
+
+// InventoryPrimaryIP describes a primary IP assigned to a server
+type InventoryPrimaryIP struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+	IP   string `json:"ip"`
+}
+
+// InventoryNetwork describes the membership of a server in a private network
+type InventoryNetwork struct {
+	ID      int64    `json:"id"`
+	Name    string   `json:"name,omitempty"`
+	IP      string   `json:"ip"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// Inventory prints all servers created by the driver, i.e. carrying the machine label, or those matching the label
+// selector if given, as a JSON array sorted by name, to feed external IPAM or DNS reconciliation
+func (d *Driver) Inventory(w io.Writer, selector string) error {
+	if selector == "" {
+		selector = d.labelName(labelMachine)
+	}
+	servers, err := d.getClient().Server.AllWithOpts(context.Background(), hcloud.ServerListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: selector},
+	})
+	if err != nil {
+		return fmt.Errorf("could not list servers: %w", err)
+	}
+	networks, err := d.getClient().Network.All(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not list networks: %w", err)
+	}
+	primaryIPs, err := d.getClient().PrimaryIP.All(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not list primary IPs: %w", err)
+	}
+
+	networkNames := make(map[int64]string, len(networks))
+	for _, network := range networks {
+		networkNames[network.ID] = network.Name
+	}
+	primaryIPNames := make(map[int64]string, len(primaryIPs))
+	for _, ip := range primaryIPs {
+		primaryIPNames[ip.ID] = ip.Name
+	}
+
+	inventory := make([]InventoryServer, 0, len(servers))
+	for _, srv := range servers {
+		inventory = append(inventory, inventoryServer(srv, networkNames, primaryIPNames))
+	}
+	sort.Slice(inventory, func(i, j int) bool { return inventory[i].Name < inventory[j].Name })
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(inventory)
+}
+
+// inventoryServer describes the server, naming its networks and primary IPs by the given names by ID
+func inventoryServer(srv *hcloud.Server, networkNames, primaryIPNames map[int64]string) InventoryServer {
+	entry := InventoryServer{
+		ID:         srv.ID,
+		Name:       srv.Name,
+		Status:     string(srv.Status),
+		PrimaryIPs: []InventoryPrimaryIP{},
+		Networks:   []InventoryNetwork{},
+		Labels:     srv.Labels,
+	}
+	if entry.Labels == nil {
+		entry.Labels = map[string]string{}
+	}
+	if srv.Datacenter != nil && srv.Datacenter.Location != nil {
+		entry.Location = srv.Datacenter.Location.Name
+	}
+
+	if ipv4 := srv.PublicNet.IPv4; !ipv4.IsUnspecified() {
+		entry.PublicIPv4 = ipv4.IP.String()
+		entry.PrimaryIPs = append(entry.PrimaryIPs, InventoryPrimaryIP{
+			ID: ipv4.ID, Name: primaryIPNames[ipv4.ID], Type: string(hcloud.PrimaryIPTypeIPv4), IP: entry.PublicIPv4,
+		})
+	}
+	if ipv6 := srv.PublicNet.IPv6; !ipv6.IsUnspecified() {
+		// servers get an IPv6 network assigned, so report it in CIDR notation
+		entry.PublicIPv6 = ipv6.IP.String()
+		if ipv6.Network != nil {
+			entry.PublicIPv6 = ipv6.Network.String()
+		}
+		entry.PrimaryIPs = append(entry.PrimaryIPs, InventoryPrimaryIP{
+			ID: ipv6.ID, Name: primaryIPNames[ipv6.ID], Type: string(hcloud.PrimaryIPTypeIPv6), IP: entry.PublicIPv6,
+		})
+	}
+
+	for _, privateNet := range srv.PrivateNet {
+		if privateNet.Network == nil {
+			continue
+		}
+		network := InventoryNetwork{
+			ID:   privateNet.Network.ID,
+			Name: networkNames[privateNet.Network.ID],
+			IP:   privateNet.IP.String(),
+		}
+		for _, alias := range privateNet.Aliases {
+			network.Aliases = append(network.Aliases, alias.String())
+		}
+		entry.Networks = append(entry.Networks, network)
+	}
+	return entry
+}
@@ -0,0 +1,296 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	ipStrategyAPI        = "api"
+	ipStrategySequential = "sequential"
+	ipStrategyRandom     = "random"
+	ipStrategyWebhook    = "webhook"
+
+	// ipWebhookTimeout limits a request to the webhook of --hetzner-network-ip-webhook
+	ipWebhookTimeout = 30 * time.Second
+
+	// randomIPAttempts bounds the attempts of the random strategy to hit a free IP
+	randomIPAttempts = 100
+)
+
+// ipAllocator chooses the IP of a new machine in a private network, given the IPs already used in it
+type ipAllocator interface {
+	allocateIP(machine string, network *hcloud.Network, used map[string]bool) (net.IP, error)
+}
+
+// setNetworkIPFlags validates --hetzner-network-ip-strategy, defaulting to api, and the webhook it may require
+func (d *Driver) setNetworkIPFlags(strategy, webhook string) error {
+	d.NetworkIPStrategy, d.NetworkIPWebhook = strategy, webhook
+	switch strategy {
+	case "", ipStrategyAPI, ipStrategySequential, ipStrategyRandom:
+		if webhook != "" {
+			return d.flagFailure("--%v requires --%v %v", flagNetworkIPWebhook, flagNetworkIPStrategy, ipStrategyWebhook)
+		}
+	case ipStrategyWebhook:
+		if u, err := url.Parse(webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return d.flagFailure("--%v requires --%v to be an http(s) URL, got: %q", flagNetworkIPStrategy, flagNetworkIPWebhook, webhook)
+		}
+	default:
+		return d.flagFailure("--%v must be one of %v, %v, %v, %v", flagNetworkIPStrategy,
+			ipStrategyAPI, ipStrategySequential, ipStrategyRandom, ipStrategyWebhook)
+	}
+	return nil
+}
+
+func (d *Driver) ipAllocator() ipAllocator {
+	switch d.NetworkIPStrategy {
+	case ipStrategySequential:
+		return sequentialIPAllocator{}
+	case ipStrategyRandom:
+		return randomIPAllocator{}
+	case ipStrategyWebhook:
+		return webhookIPAllocator{url: d.NetworkIPWebhook}
+	}
+	return nil
+}
+
+// allocateNetworkIPs chooses the IPs of the networks given without a static IP according to the IP strategy; the
+// networks are then attached with these IPs like with static ones, see attachStaticIPNetworks. Allocations are kept
+// apart from the static IPs, so they are neither stored nor exported, and replace those of a previous attempt
+func (d *Driver) allocateNetworkIPs() error {
+	d.allocatedIPs = nil
+	allocator := d.ipAllocator()
+	if allocator == nil {
+		return nil
+	}
+
+	for _, idOrName := range d.Networks {
+		if d.NetworkIPs[idOrName] != "" {
+			continue
+		}
+
+		network, _, err := d.getClient().Network.Get(context.Background(), idOrName)
+		if err != nil {
+			return fmt.Errorf("could not get network by ID or name: %w", err)
+		}
+		if network == nil {
+			return notFoundError("network", idOrName)
+		}
+		used, err := d.usedNetworkIPs(network)
+		if err != nil {
+			return err
+		}
+
+		ip, err := allocator.allocateIP(d.GetMachineName(), network, used)
+		if err != nil {
+			return fmt.Errorf("could not allocate IP in network %v: %w", network.Name, err)
+		}
+		if !network.IPRange.Contains(ip) || used[ip.String()] {
+			return fmt.Errorf("allocated IP %v is not available in network %v", ip, network.Name)
+		}
+
+		log.Infof(" -> Allocated IP %v in network %v[%d] (%v)", ip, network.Name, network.ID, d.NetworkIPStrategy)
+		if d.allocatedIPs == nil {
+			d.allocatedIPs = make(map[string]string)
+		}
+		d.allocatedIPs[idOrName] = ip.String()
+	}
+	return nil
+}
+
+// networkIP returns the static or allocated IP of the network given by ID or name, if any
+func (d *Driver) networkIP(idOrName string) string {
+	if ip := d.NetworkIPs[idOrName]; ip != "" {
+		return ip
+	}
+	return d.allocatedIPs[idOrName]
+}
+
+// hasNetworkIPs checks whether any network is attached with a static or allocated IP
+func (d *Driver) hasNetworkIPs() bool {
+	return len(d.NetworkIPs) > 0 || len(d.allocatedIPs) > 0
+}
+
+// usedNetworkIPs returns the IPs of all servers and load balancers attached to the network, as well as the gateways of
+// its subnets
+func (d *Driver) usedNetworkIPs(network *hcloud.Network) (map[string]bool, error) {
+	used := make(map[string]bool)
+	for _, subnet := range network.Subnets {
+		if subnet.Gateway != nil {
+			used[subnet.Gateway.String()] = true
+		}
+	}
+
+	servers, err := d.getClient().Server.All(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("could not list servers: %w", err)
+	}
+	for _, srv := range servers {
+		for _, privateNet := range srv.PrivateNet {
+			if privateNet.Network != nil && privateNet.Network.ID == network.ID {
+				used[privateNet.IP.String()] = true
+				for _, alias := range privateNet.Aliases {
+					used[alias.String()] = true
+				}
+			}
+		}
+	}
+
+	lbs, err := d.getClient().LoadBalancer.All(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("could not list load balancers: %w", err)
+	}
+	for _, lb := range lbs {
+		for _, privateNet := range lb.PrivateNet {
+			if privateNet.Network != nil && privateNet.Network.ID == network.ID {
+				used[privateNet.IP.String()] = true
+			}
+		}
+	}
+	return used, nil
+}
+
+// hostRange returns the first and last assignable IPv4 address of a subnet, excluding its network and broadcast
+// addresses, as integers
+func hostRange(subnet hcloud.NetworkSubnet) (uint32, uint32, bool) {
+	if subnet.Type == hcloud.NetworkSubnetTypeVSwitch || subnet.IPRange == nil {
+		return 0, 0, false
+	}
+	ip4 := subnet.IPRange.IP.To4()
+	ones, bits := subnet.IPRange.Mask.Size()
+	if ip4 == nil || bits != 32 || ones > 30 {
+		return 0, 0, false
+	}
+
+	first := binary.BigEndian.Uint32(ip4)
+	return first + 1, first + (1 << (32 - ones)) - 2, true
+}
+
+func uint32IP(n uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+// sequentialIPAllocator chooses the lowest free IP of the network's subnets
+type sequentialIPAllocator struct{}
+
+func (sequentialIPAllocator) allocateIP(_ string, network *hcloud.Network, used map[string]bool) (net.IP, error) {
+	for _, subnet := range network.Subnets {
+		first, last, ok := hostRange(subnet)
+		if !ok {
+			continue
+		}
+		for n := first; n <= last; n++ {
+			if ip := uint32IP(n); !used[ip.String()] {
+				return ip, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no free IP left in the subnets of network %v", network.Name)
+}
+
+// randomIPAllocator chooses a random free IP of the network's subnets, reducing conflicts between concurrent creations
+type randomIPAllocator struct{}
+
+func (randomIPAllocator) allocateIP(_ string, network *hcloud.Network, used map[string]bool) (net.IP, error) {
+	var subnets []hcloud.NetworkSubnet
+	for _, subnet := range network.Subnets {
+		if _, _, ok := hostRange(subnet); ok {
+			subnets = append(subnets, subnet)
+		}
+	}
+	if len(subnets) == 0 {
+		return nil, fmt.Errorf("network %v has no subnet to allocate IPs from", network.Name)
+	}
+
+	for i := 0; i < randomIPAttempts; i++ {
+		first, last, _ := hostRange(subnets[rand.Intn(len(subnets))])
+		if ip := uint32IP(first + uint32(rand.Int63n(int64(last-first)+1))); !used[ip.String()] {
+			return ip, nil
+		}
+	}
+	// the subnets are mostly used up, fall back to searching them
+	return sequentialIPAllocator{}.allocateIP("", network, used)
+}
+
+// webhookIPAllocator asks an external IPAM system for the IP by posting an ipWebhookRequest to its URL, which responds
+// with an ipWebhookResponse
+type webhookIPAllocator struct {
+	url string
+}
+
+type ipWebhookRequest struct {
+	Machine   string   `json:"machine"`
+	NetworkID int64    `json:"network_id"`
+	Network   string   `json:"network"`
+	IPRange   string   `json:"ip_range"`
+	Used      []string `json:"used"`
+}
+
+type ipWebhookResponse struct {
+	IP string `json:"ip"`
+}
+
+func (a webhookIPAllocator) allocateIP(machine string, network *hcloud.Network, used map[string]bool) (net.IP, error) {
+	payload := ipWebhookRequest{
+		Machine:   machine,
+		NetworkID: network.ID,
+		Network:   network.Name,
+		IPRange:   network.IPRange.String(),
+		Used:      make([]string, 0, len(used)),
+	}
+	for ip := range used {
+		payload.Used = append(payload.Used, ip)
+	}
+	sort.Strings(payload.Used)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize webhook request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ipWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not query %v: %w", a.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status querying %v: %v", a.url, resp.Status)
+	}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response: %w", err)
+	}
+
+	var result ipWebhookResponse
+	if err = json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("could not parse response of %v: %w", a.url, err)
+	}
+	ip := net.ParseIP(result.IP)
+	if ip == nil || ip.To4() == nil {
+		return nil, fmt.Errorf("%v responded with invalid IPv4 address %q", a.url, result.IP)
+	}
+	return ip.To4(), nil
+}
@@ -0,0 +1,59 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// getISO resolves the ISO of --hetzner-iso by ID or name
+func (d *Driver) getISO() (*hcloud.ISO, error) {
+	if d.ISO == "" || d.cachedISO != nil {
+		return d.cachedISO, nil
+	}
+
+	iso, _, err := d.getClient().ISO.Get(context.Background(), d.ISO)
+	if err != nil {
+		return nil, fmt.Errorf("could not get ISO by ID or name: %w", err)
+	}
+	if iso == nil {
+		return nil, notFoundError("ISO", d.ISO)
+	}
+
+	d.cachedISO = instrumented(iso)
+	return iso, nil
+}
+
+// verifyISO makes sure the ISO exists and, unless it is architecture-independent, matches the server type's
+// architecture, as the server could not boot it otherwise
+func (d *Driver) verifyISO(arch hcloud.Architecture) error {
+	iso, err := d.getISO()
+	if err != nil || iso == nil {
+		return err
+	}
+	if iso.Architecture != nil && *iso.Architecture != arch {
+		return fmt.Errorf("ISO %v is for architecture %v, but the server type is of architecture %v", iso.Name, *iso.Architecture, arch)
+	}
+	return nil
+}
+
+// attachISO attaches the ISO of --hetzner-iso to the newly created server, which boots from it on its next start,
+// e.g. when started after creating it powered off
+func (d *Driver) attachISO(srv *hcloud.Server) error {
+	iso, err := d.getISO()
+	if err != nil || iso == nil {
+		return err
+	}
+
+	log.Infof(" -> Attaching ISO %v[%d] ...", iso.Name, iso.ID)
+	act, _, err := d.getClient().Server.AttachISO(context.Background(), srv, iso)
+	if err != nil {
+		return fmt.Errorf("could not attach ISO: %w", err)
+	}
+	if err = d.waitForAction(act); err != nil {
+		return fmt.Errorf("could not wait for ISO attachment: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// keyCleanupAttempts bounds the rounds of deleting the machine's SSH keys, each followed by checking which still exist
+const keyCleanupAttempts = 3
+
+// IncompleteCleanupError is returned by [Driver.Remove] if SSH keys created for the machine still exist after the server
+// was removed; automation can detect it with errors.As to alert on stale keys, while the server itself is gone
+type IncompleteCleanupError struct {
+	Keys []KeyCleanupFailure
+}
+
+// KeyCleanupFailure describes an SSH key which could not be deleted
+type KeyCleanupFailure struct {
+	ID   int64
+	Name string
+	Err  error
+}
+
+func (e *IncompleteCleanupError) Error() string {
+	keys := make([]string, 0, len(e.Keys))
+	for _, key := range e.Keys {
+		keys = append(keys, fmt.Sprintf("%v[%d]: %v", key.Name, key.ID, key.Err))
+	}
+	return fmt.Sprintf("server removed, but %d ssh key(s) were left behind: %v", len(e.Keys), strings.Join(keys, "; "))
+}
+
+func (e *IncompleteCleanupError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Keys))
+	for _, key := range e.Keys {
+		errs = append(errs, key.Err)
+	}
+	return errs
+}
+
+// removeMachineKeys deletes the additional keys and, if it was created for the machine, the key of the machine, then
+// verifies they are gone, retrying deletion of those still existing
+func (d *Driver) removeMachineKeys() error {
+	remaining := append([]int64{}, d.AdditionalKeyIDs...)
+	if d.ownsKey() {
+		remaining = append(remaining, d.KeyID)
+	}
+
+	names := make(map[int64]string)
+	failures := make(map[int64]error)
+	for attempt := 1; attempt <= keyCleanupAttempts && len(remaining) > 0; attempt++ {
+		if attempt > 1 {
+			log.Infof(" -> Retrying deletion of %d ssh key(s) ...", len(remaining))
+			time.Sleep(time.Duration(max(d.WaitOnPolling, 1)) * time.Second)
+		}
+		remaining = d.deleteKeys(remaining, names, failures)
+	}
+
+	if len(remaining) == 0 {
+		return nil
+	}
+	cleanupErr := &IncompleteCleanupError{}
+	for _, id := range remaining {
+		cleanupErr.Keys = append(cleanupErr.Keys, KeyCleanupFailure{ID: id, Name: names[id], Err: failures[id]})
+	}
+	return cleanupErr
+}
+
+// deleteKeys deletes the keys of the given IDs which still exist, recording their names and why they could not be
+// deleted, and returns the IDs of those whose deletion failed or which still exist afterwards
+func (d *Driver) deleteKeys(ids []int64, names map[int64]string, failures map[int64]error) []int64 {
+	var remaining []int64
+	for _, id := range ids {
+		key, _, err := d.getClient().SSHKey.GetByID(context.Background(), id)
+		if err != nil {
+			log.Warnf(" -> could not get ssh key %d: %v", id, err)
+			remaining = append(remaining, id)
+			failures[id] = err
+			continue
+		}
+		if key == nil {
+			continue
+		}
+		names[id] = key.Name
+
+		log.Infof(" -> Destroying SSH key %s[%d]...", key.Name, key.ID)
+		err = d.retry("ssh key deletion", func() error {
+			_, err := d.getClient().SSHKey.Delete(context.Background(), key)
+			return err
+		})
+		if err != nil {
+			log.Warnf(" -> could not delete ssh key %s[%d]: %v", key.Name, key.ID, err)
+			remaining = append(remaining, id)
+			failures[id] = err
+			continue
+		}
+
+		if key, _, err = d.getClient().SSHKey.GetByID(context.Background(), id); err != nil {
+			remaining = append(remaining, id)
+			failures[id] = fmt.Errorf("could not verify deletion: %w", err)
+		} else if key != nil {
+			remaining = append(remaining, id)
+			failures[id] = errors.New("key still exists after deletion")
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,44 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// keyLimitHint suggests ways to stay below the SSH key limit of a project
+const keyLimitHint = "remove stale keys, e.g. with the remove-by-selector command, or share one key among machines " +
+	"with --hetzner-existing-key-path and --hetzner-existing-key-id"
+
+// checkKeyLimit verifies that uploading the machine's key and up to the given number of additional keys stays within
+// --hetzner-key-limit, warning when more than 90% of it would be used
+func (d *Driver) checkKeyLimit(additional int) error {
+	if d.KeyLimit == 0 {
+		return nil
+	}
+
+	keys, err := d.getClient().SSHKey.All(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not list ssh keys: %w", err)
+	}
+
+	needed := additional
+	if !d.IsExistingKey {
+		needed++
+	}
+	return checkKeyCount(len(keys), needed, d.KeyLimit)
+}
+
+// checkKeyCount fails if adding the needed keys to the existing ones exceeds the limit, and warns when getting close
+func checkKeyCount(existing, needed, limit int) error {
+	if existing+needed > limit {
+		return fmt.Errorf("the project has %d of %d ssh keys, but the machine needs up to %d more; %v",
+			existing, limit, needed, keyLimitHint)
+	}
+	if (existing+needed)*10 > limit*9 {
+		log.Warnf("the project will have up to %d of %d ssh keys; to avoid failing key uploads, %v",
+			existing+needed, limit, keyLimitHint)
+	}
+	return nil
+}
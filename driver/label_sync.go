@@ -0,0 +1,103 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// SyncLabels updates the labels of the existing server to match the given key=value server labels, or the configured
+// ones if there are none, printing the changes to w; labels in the driver's docker-machine/ namespace are retained, as
+// they are managed by the driver itself
+func (d *Driver) SyncLabels(w io.Writer, labels []string) (err error) {
+	defer d.operation("SyncLabels")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.Robot {
+		return errors.New("labels cannot be managed for robot servers")
+	}
+
+	desired := maps.Clone(d.ServerLabels)
+	if len(labels) != 0 {
+		desired = make(map[string]string, len(labels))
+		for _, label := range labels {
+			key, value, ok := strings.Cut(label, "=")
+			if !ok {
+				return fmt.Errorf("server label %v is not in key=value format", label)
+			}
+			desired[key] = value
+		}
+	}
+	if desired == nil {
+		desired = make(map[string]string)
+	}
+
+	srv, err := d.getServerHandle()
+	if err != nil {
+		return err
+	}
+	for key, value := range srv.Labels {
+		if strings.HasPrefix(key, labelNamespace+"/") {
+			desired[key] = value
+		}
+	}
+
+	if maps.Equal(desired, srv.Labels) {
+		_, _ = fmt.Fprintln(w, "labels are up to date")
+		d.ServerLabels = desired
+		return nil
+	}
+	if ok, err := hcloud.ValidateResourceLabels(toInterfaceMap(desired)); !ok {
+		return fmt.Errorf("invalid server labels: %w", err)
+	}
+
+	_, _, err = d.getClient().Server.Update(context.Background(), srv, hcloud.ServerUpdateOpts{Labels: desired})
+	if err != nil {
+		return fmt.Errorf("could not update server labels: %w", err)
+	}
+
+	for _, change := range labelChanges(srv.Labels, desired) {
+		_, _ = fmt.Fprintln(w, change)
+	}
+	d.ServerLabels = desired
+	return nil
+}
+
+// labelChanges describes the changes from the old to the new labels, ordered by key
+func labelChanges(old, new map[string]string) []string {
+	var changes []string
+	for key, value := range new {
+		if oldValue, exists := old[key]; !exists {
+			changes = append(changes, fmt.Sprintf("+ %v=%v", key, value))
+		} else if oldValue != value {
+			changes = append(changes, fmt.Sprintf("~ %v=%v (was %v)", key, value, oldValue))
+		}
+	}
+	for key, value := range old {
+		if _, exists := new[key]; !exists {
+			changes = append(changes, fmt.Sprintf("- %v=%v", key, value))
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i][2:] < changes[j][2:] })
+	return changes
+}
+
+func toInterfaceMap(labels map[string]string) map[string]interface{} {
+	ret := make(map[string]interface{}, len(labels))
+	for key, value := range labels {
+		ret[key] = value
+	}
+	return ret
+}
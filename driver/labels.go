@@ -1,7 +1,57 @@
 package driver
 
-const labelNamespace = "docker-machine"
+import (
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	labelNamespace   = "docker-machine"
+	labelDescription = "description"
+)
 
 func (d *Driver) labelName(name string) string {
 	return labelNamespace + "/" + name
 }
+
+// parseServerLabelFlag parses the key=value label of a flag assigning it to the server, which must be a valid label not
+// conflicting with the server labels
+func (d *Driver) parseServerLabelFlag(flag, label string) (string, string, error) {
+	key, value, ok := strings.Cut(label, "=")
+	if !ok || key == "" {
+		return "", "", d.flagFailure("--%v must be a key=value pair: %v", flag, label)
+	}
+	if ok, err := hcloud.ValidateResourceLabels(map[string]interface{}{key: value}); !ok {
+		return "", "", d.flagFailure("--%v: invalid label: %v", flag, err)
+	}
+	if existing, set := d.ServerLabels[key]; set && existing != value {
+		return "", "", d.flagFailure("--%v %v conflicts with server label %v=%v", flag, label, key, existing)
+	}
+	return key, value, nil
+}
+
+// setMachineLabel labels the server with the machine name, by which the inventory command recognizes the servers
+// created by the driver
+func (d *Driver) setMachineLabel() {
+	if d.ServerLabels == nil {
+		d.ServerLabels = make(map[string]string)
+	}
+	d.ServerLabels[d.labelName(labelMachine)] = d.GetMachineName()
+}
+
+// setDescriptionFlag labels the server with --hetzner-description, made a valid label value, so the purpose of the
+// machine is visible in the Cloud Console
+func (d *Driver) setDescriptionFlag(description string) error {
+	d.Description = description
+	if description == "" {
+		return nil
+	}
+
+	value := labelValue(description)
+	if value == "" {
+		return d.flagFailure("--%v must contain letters or digits: %q", flagDescription, description)
+	}
+	d.ServerLabels[d.labelName(labelDescription)] = value
+	return nil
+}
@@ -0,0 +1,240 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	labelAutoLoadBalancer = "auto-load-balancer"
+
+	// autoLoadBalancerType is the type of load balancers created by --hetzner-auto-load-balancer
+	autoLoadBalancerType = "lb11"
+)
+
+// setLoadBalancerFlags validates --hetzner-load-balancer-label, which is assigned to the server and replaces registering
+// it with the load balancers of --hetzner-load-balancer directly by registering a label selector target
+func (d *Driver) setLoadBalancerFlags(loadBalancers []string, label string, auto string) error {
+	d.LoadBalancers, d.LoadBalancerLabel, d.AutoLoadBalancer = loadBalancers, "", auto
+	if auto != "" {
+		if labelValue(auto) == "" {
+			return d.flagFailure("--%v: %q contains no characters usable in a label", flagAutoLoadBalancer, auto)
+		}
+		d.ServerLabels[d.labelName(labelAutoLoadBalancer)] = labelValue(auto)
+	}
+	if label == "" {
+		return nil
+	}
+	if len(loadBalancers) == 0 {
+		return d.flagFailure("--%v requires --%v", flagLoadBalancerLabel, flagLoadBalancers)
+	}
+
+	key, value, err := d.parseServerLabelFlag(flagLoadBalancerLabel, label)
+	if err != nil {
+		return err
+	}
+
+	d.LoadBalancerLabel = label
+	d.ServerLabels[key] = value
+	return nil
+}
+
+// registerLoadBalancers adds the server, or the label selector of --hetzner-load-balancer-label, as a target of the
+// load balancers of --hetzner-load-balancer
+func (d *Driver) registerLoadBalancers(srv *hcloud.Server) error {
+	for _, idOrName := range d.LoadBalancers {
+		lb, _, err := d.getClient().LoadBalancer.Get(context.Background(), idOrName)
+		if err != nil {
+			return fmt.Errorf("could not get load balancer by ID or name: %w", err)
+		}
+		if lb == nil {
+			return notFoundError("load balancer", idOrName)
+		}
+
+		var act *hcloud.Action
+		if d.LoadBalancerLabel != "" {
+			log.Infof(" -> Registering servers labeled %v with load balancer %v[%d] ...", d.LoadBalancerLabel, lb.Name, lb.ID)
+			act, _, err = d.getClient().LoadBalancer.AddLabelSelectorTarget(context.Background(), lb, hcloud.LoadBalancerAddLabelSelectorTargetOpts{
+				Selector:     d.LoadBalancerLabel,
+				UsePrivateIP: hcloud.Ptr(d.UsePrivateNetwork),
+			})
+			if hcloud.IsError(err, hcloud.ErrorCodeTargetAlreadyDefined) {
+				continue
+			}
+		} else {
+			log.Infof(" -> Registering server with load balancer %v[%d] ...", lb.Name, lb.ID)
+			act, _, err = d.getClient().LoadBalancer.AddServerTarget(context.Background(), lb, hcloud.LoadBalancerAddServerTargetOpts{
+				Server:       srv,
+				UsePrivateIP: hcloud.Ptr(d.UsePrivateNetwork),
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("could not register with load balancer %v: %w", lb.Name, err)
+		}
+		if err = d.waitForAction(act); err != nil {
+			return fmt.Errorf("could not wait for load balancer %v: %w", lb.Name, err)
+		}
+		if d.LoadBalancerLabel == "" {
+			d.LoadBalancerIDs = append(d.LoadBalancerIDs, lb.ID)
+		}
+	}
+	return nil
+}
+
+// deregisterLoadBalancers removes the server from the load balancers it was registered with before it is deleted, so
+// they stop sending traffic to it; as deleting the server removes it as well, failures are logged only
+func (d *Driver) deregisterLoadBalancers() {
+	if len(d.LoadBalancerIDs) == 0 || d.ServerID == 0 {
+		return
+	}
+	srv := &hcloud.Server{ID: d.ServerID}
+
+	for _, id := range d.LoadBalancerIDs {
+		lb, _, err := d.getClient().LoadBalancer.GetByID(context.Background(), id)
+		if err != nil || lb == nil {
+			log.Warnf(" -> could not get load balancer %d: %v", id, err)
+			continue
+		}
+
+		log.Infof(" -> Deregistering server from load balancer %v[%d] ...", lb.Name, lb.ID)
+		act, _, err := d.getClient().LoadBalancer.RemoveServerTarget(context.Background(), lb, srv)
+		if err == nil {
+			err = d.waitForAction(act)
+		}
+		if err != nil {
+			log.Warnf(" -> could not deregister server from load balancer %v: %v", lb.Name, err)
+		}
+	}
+	d.LoadBalancerIDs = nil
+}
+
+// autoLoadBalancerSelector returns the label selector of the servers targeted by the load balancer of
+// --hetzner-auto-load-balancer, which also finds the load balancer itself
+func (d *Driver) autoLoadBalancerSelector() string {
+	return d.labelName(labelAutoLoadBalancer) + "=" + labelValue(d.AutoLoadBalancer)
+}
+
+// ensureAutoLoadBalancer creates the load balancer of --hetzner-auto-load-balancer in the network zone of the server
+// unless a machine created it before; it targets all servers labeled like this one, so the server needs no registration
+func (d *Driver) ensureAutoLoadBalancer(srv *hcloud.Server) error {
+	if d.AutoLoadBalancer == "" {
+		return nil
+	}
+
+	selector := d.autoLoadBalancerSelector()
+	existing, err := d.findAutoLoadBalancer(selector)
+	if err != nil || existing {
+		return err
+	}
+	if srv.Datacenter == nil || srv.Datacenter.Location == nil {
+		return fmt.Errorf("could not determine network zone of server %d", srv.ID)
+	}
+
+	opts := hcloud.LoadBalancerCreateOpts{
+		Name:             d.AutoLoadBalancer,
+		LoadBalancerType: &hcloud.LoadBalancerType{Name: autoLoadBalancerType},
+		NetworkZone:      srv.Datacenter.Location.NetworkZone,
+		Labels: map[string]string{
+			d.labelName(labelAutoLoadBalancer): labelValue(d.AutoLoadBalancer),
+			d.labelName(labelAutoCreated):      "true",
+		},
+		Targets: []hcloud.LoadBalancerCreateOptsTarget{{
+			Type:          hcloud.LoadBalancerTargetTypeLabelSelector,
+			LabelSelector: hcloud.LoadBalancerCreateOptsTargetLabelSelector{Selector: selector},
+			UsePrivateIP:  hcloud.Ptr(d.UsePrivateNetwork && len(srv.PrivateNet) > 0),
+		}},
+	}
+	if d.UsePrivateNetwork && len(srv.PrivateNet) > 0 {
+		opts.Network = srv.PrivateNet[0].Network
+	}
+
+	log.Infof(" -> Creating load balancer %v in %v targeting servers labeled %v ...", opts.Name, opts.NetworkZone, selector)
+	res, _, err := d.getClient().LoadBalancer.Create(context.Background(), opts)
+	if hcloud.IsError(err, hcloud.ErrorCodeUniquenessError) {
+		// another machine created the load balancer concurrently
+		if existing, findErr := d.findAutoLoadBalancer(selector); findErr != nil || existing {
+			return findErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("could not create load balancer %v: %w", opts.Name, err)
+	}
+	d.trackDangling("load balancer", func() error {
+		_, err := d.getClient().LoadBalancer.Delete(context.Background(), res.LoadBalancer)
+		return err
+	})
+	if err = d.waitForAction(res.Action); err != nil {
+		return fmt.Errorf("could not wait for load balancer %v: %w", opts.Name, err)
+	}
+	return nil
+}
+
+// findAutoLoadBalancer checks whether the load balancer of --hetzner-auto-load-balancer exists
+func (d *Driver) findAutoLoadBalancer(selector string) (bool, error) {
+	existing, err := d.getClient().LoadBalancer.AllWithOpts(context.Background(), hcloud.LoadBalancerListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: selector},
+	})
+	if err != nil {
+		return false, fmt.Errorf("could not list load balancers: %w", err)
+	}
+	if len(existing) == 0 {
+		return false, nil
+	}
+	log.Infof(" -> Server is targeted by load balancer %v[%d]", existing[0].Name, existing[0].ID)
+	return true, nil
+}
+
+// removableAutoLoadBalancer returns the load balancer of --hetzner-auto-load-balancer if it was created by the driver
+// and no other server carries its label, or nil otherwise
+func (d *Driver) removableAutoLoadBalancer() (*hcloud.LoadBalancer, error) {
+	if d.AutoLoadBalancer == "" {
+		return nil, nil
+	}
+
+	selector := d.autoLoadBalancerSelector()
+	servers, err := d.getClient().Server.AllWithOpts(context.Background(), hcloud.ServerListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: selector},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list servers: %w", err)
+	}
+	for _, srv := range servers {
+		if srv.ID != d.ServerID {
+			log.Debugf("load balancer %v still targets server %d, ignoring", d.AutoLoadBalancer, srv.ID)
+			return nil, nil
+		}
+	}
+
+	lbs, err := d.getClient().LoadBalancer.AllWithOpts(context.Background(), hcloud.LoadBalancerListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: selector + "," + d.labelName(labelAutoCreated) + "=true"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list load balancers: %w", err)
+	}
+	if len(lbs) == 0 {
+		return nil, nil
+	}
+	return lbs[0], nil
+}
+
+// removeAutoLoadBalancer deletes the load balancer of --hetzner-auto-load-balancer once the last server it targets is
+// removed, like the auto-spread placement group
+func (d *Driver) removeAutoLoadBalancer() error {
+	lb, err := d.removableAutoLoadBalancer()
+	if err != nil || lb == nil {
+		return err
+	}
+
+	log.Infof(" -> Destroying load balancer %v[%d] ...", lb.Name, lb.ID)
+	err = d.retry("load balancer deletion", func() error {
+		_, err := d.getClient().LoadBalancer.Delete(context.Background(), lb)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not remove load balancer: %w", err)
+	}
+	return nil
+}
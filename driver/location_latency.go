@@ -0,0 +1,111 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	locationAuto = "auto"
+
+	latencyProbes       = 3
+	latencyProbeTimeout = 3 * time.Second
+)
+
+// probeLocationLatency measures the time to establish a TCP connection to the location's speed test host; it is
+// replaceable for testing
+var probeLocationLatency = func(location string) (time.Duration, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(location+"-speed.hetzner.com", "443"), latencyProbeTimeout)
+	if err != nil {
+		return 0, err
+	}
+	_ = conn.Close()
+	return time.Since(start), nil
+}
+
+// resolveAutoLocations makes all locations with a datacenter currently offering the server type candidate locations
+func (d *Driver) resolveAutoLocations() error {
+	if !d.locationAuto {
+		return nil
+	}
+
+	serverType, err := d.getType()
+	if err != nil {
+		return fmt.Errorf("could not get type: %w", err)
+	}
+	datacenters, err := d.getClient().Datacenter.All(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not list datacenters: %w", err)
+	}
+
+	d.locationCandidates = availableLocations(datacenters, serverType)
+	if len(d.locationCandidates) == 0 {
+		return fmt.Errorf("server type %v is currently not available in any location", serverType.Name)
+	}
+	log.Infof(" -> Locations offering server type %v: %v", serverType.Name, d.locationCandidates)
+	return nil
+}
+
+// availableLocations returns the names of the locations with a datacenter offering the server type, in API order
+func availableLocations(datacenters []*hcloud.Datacenter, serverType *hcloud.ServerType) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	for _, dc := range datacenters {
+		if dc.Location == nil || seen[dc.Location.Name] {
+			continue
+		}
+		for _, available := range dc.ServerTypes.Available {
+			if available.ID == serverType.ID {
+				seen[dc.Location.Name] = true
+				ret = append(ret, dc.Location.Name)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// getClosestLocation returns the candidate location with the lowest connection latency from this host, taking the best
+// of several probes each; if no location could be reached, the first candidate is used
+func (d *Driver) getClosestLocation() string {
+	latencies := make([]time.Duration, len(d.locationCandidates))
+	var wg sync.WaitGroup
+	for i, candidate := range d.locationCandidates {
+		wg.Add(1)
+		go func(i int, candidate string) {
+			defer wg.Done()
+			for n := 0; n < latencyProbes; n++ {
+				latency, err := probeLocationLatency(candidate)
+				if err != nil {
+					log.Debugf("could not probe location %v: %v", candidate, err)
+					continue
+				}
+				if latencies[i] == 0 || latency < latencies[i] {
+					latencies[i] = latency
+				}
+			}
+		}(i, candidate)
+	}
+	wg.Wait()
+
+	best := -1
+	for i, latency := range latencies {
+		if latency > 0 && (best < 0 || latency < latencies[best]) {
+			best = i
+		}
+	}
+
+	if best < 0 {
+		log.Warnf("could not reach any of the locations %v, using %v", d.locationCandidates, d.locationCandidates[0])
+		return d.locationCandidates[0]
+	}
+	log.Infof(" -> Closest location is %v (%v)", d.locationCandidates[best], latencies[best].Round(time.Millisecond))
+	return d.locationCandidates[best]
+}
@@ -0,0 +1,235 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	labelLocationSpread = "location-spread"
+
+	locationStrategySpread  = "spread"
+	locationStrategyRandom  = "random"
+	locationStrategyLatency = "latency"
+)
+
+// setLocationFlag accepts a single location, a comma-separated list of candidate locations to choose from, or auto to
+// choose the closest location offering the server type
+func (d *Driver) setLocationFlag(location, strategy string) error {
+	d.Location = location
+	d.locationCandidates = nil
+	d.locationAuto = location == locationAuto
+	if d.locationAuto {
+		d.Location = ""
+		d.locationStrategy = locationStrategyLatency
+		return nil
+	}
+	if !strings.Contains(location, ",") {
+		return nil
+	}
+
+	if strategy != locationStrategySpread && strategy != locationStrategyRandom && strategy != locationStrategyLatency {
+		return d.flagFailure("--%v must be one of %v, %v, %v", flagLocationStrategy,
+			locationStrategySpread, locationStrategyRandom, locationStrategyLatency)
+	}
+
+	seen := make(map[string]bool)
+	for _, candidate := range strings.Split(location, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" || seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+		d.locationCandidates = append(d.locationCandidates, candidate)
+	}
+
+	d.Location = ""
+	d.locationStrategy = strategy
+	return nil
+}
+
+// selectLocation picks one of the candidate locations according to the location strategy
+func (d *Driver) selectLocation() error {
+	if len(d.locationCandidates) == 0 {
+		return nil
+	}
+
+	var location string
+	switch d.locationStrategy {
+	case locationStrategyRandom:
+		location = d.locationCandidates[rand.Intn(len(d.locationCandidates))]
+		log.Infof(" -> Randomly chose location %v", location)
+	case locationStrategyLatency:
+		location = d.getClosestLocation()
+	default:
+		var err error
+		if location, err = d.getSpreadLocation(); err != nil {
+			return err
+		}
+	}
+
+	d.Location = location
+	d.cachedLocation = nil
+	d.locationCandidates = nil
+	return nil
+}
+
+// getSpreadLocation returns the candidate location hosting the fewest servers of the same spread group, preferring
+// earlier candidates on ties; servers are assigned to the group by a label
+func (d *Driver) getSpreadLocation() (string, error) {
+	sorted := append([]string(nil), d.locationCandidates...)
+	sort.Strings(sorted)
+	group := strings.Join(sorted, "-")
+	label := d.labelName(labelLocationSpread)
+
+	servers, err := d.getClient().Server.AllWithOpts(context.Background(), hcloud.ServerListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: fmt.Sprintf("%v=%v", label, group)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not list servers of location spread group: %w", err)
+	}
+
+	counts := make(map[string]int)
+	for _, srv := range servers {
+		if srv.Datacenter != nil && srv.Datacenter.Location != nil {
+			counts[srv.Datacenter.Location.Name]++
+		}
+	}
+
+	best := d.locationCandidates[0]
+	for _, candidate := range d.locationCandidates[1:] {
+		if counts[candidate] < counts[best] {
+			best = candidate
+		}
+	}
+
+	if d.ServerLabels == nil {
+		d.ServerLabels = make(map[string]string)
+	}
+	d.ServerLabels[label] = group
+
+	log.Infof(" -> Spreading to location %v (%d of %d servers in group %v)", best, counts[best], len(servers), group)
+	return best, nil
+}
+
+// setServerLocationsFlag sets the ordered locations to fall back through when the current one lacks capacity, starting
+// with the first one
+func (d *Driver) setServerLocationsFlag(locations []string) error {
+	d.ServerLocations = nil
+	for _, location := range locations {
+		if location = strings.TrimSpace(location); location != "" {
+			d.ServerLocations = append(d.ServerLocations, location)
+		}
+	}
+	if len(d.ServerLocations) == 0 {
+		return nil
+	}
+	if d.Location != "" || d.locationAuto || len(d.locationCandidates) > 0 {
+		return d.flagFailure("--%v and --%v are mutually exclusive", flagLocation, flagServerLocations)
+	}
+
+	d.Location = d.ServerLocations[0]
+	return nil
+}
+
+// nextServerLocation returns the location following the current one in --hetzner-server-locations, if any
+func (d *Driver) nextServerLocation() string {
+	for i, location := range d.ServerLocations {
+		if location == d.Location && i+1 < len(d.ServerLocations) {
+			return d.ServerLocations[i+1]
+		}
+	}
+	return ""
+}
+
+// shouldFallBack checks whether server creation failed for lack of capacity in the current location and another one
+// remains to fall back to
+func (d *Driver) shouldFallBack(err error) bool {
+	code := errorCode(err)
+	return (code == string(hcloud.ErrorCodeResourceUnavailable) || code == string(hcloud.ErrorCodePlacementError)) &&
+		d.nextServerLocation() != ""
+}
+
+// isRetryableCreationError classifies errors of server creation like isRetryableError, except that a lack of capacity
+// is not retried with --hetzner-server-locations, which rather falls back to the next location right away
+func (d *Driver) isRetryableCreationError(err error) bool {
+	if len(d.ServerLocations) > 0 && errorCode(err) == string(hcloud.ErrorCodeResourceUnavailable) {
+		return false
+	}
+	return isRetryableError(err)
+}
+
+// fallBack switches to the next location after server creation failed with the given error, dropping the cached
+// location; locations outside the network zones of the attached networks are skipped, and the final location is kept
+// in the machine state
+func (d *Driver) fallBack(cause error) error {
+	if d.PrimaryIPv4 != "" || d.PrimaryIPv6 != "" || d.PrimaryIPPool != "" || d.reusePrimaryIPOf != "" || len(d.Volumes) > 0 {
+		return fmt.Errorf("cannot fall back to another location with primary IPs or volumes configured, as they are bound to a location: %w", cause)
+	}
+
+	fits, err := d.networkZoneFilter()
+	if err != nil {
+		return err
+	}
+	from := d.Location
+	for next := d.nextServerLocation(); next != ""; next = d.nextServerLocation() {
+		d.Location, d.cachedLocation = next, nil
+		if zone, ok := fits(next); !ok {
+			log.Warnf("Skipping fallback location %v, its network zone %v lacks a subnet of the attached networks", next, zone)
+			continue
+		}
+		log.Warnf("No capacity in location %v, falling back to location %v ...", from, next)
+		return nil
+	}
+	d.Location, d.cachedLocation = from, nil
+	return fmt.Errorf("no fallback location left in the network zones of the attached networks: %w", cause)
+}
+
+// networkZoneFilter returns a function reporting whether a location is in a network zone in which all attached networks
+// have a subnet, along with the location's network zone
+func (d *Driver) networkZoneFilter() (func(location string) (hcloud.NetworkZone, bool), error) {
+	if len(d.Networks) == 0 {
+		return func(string) (hcloud.NetworkZone, bool) { return "", true }, nil
+	}
+
+	var networkZones []map[hcloud.NetworkZone]bool
+	for _, idOrName := range d.Networks {
+		network, _, err := d.getClient().Network.Get(context.Background(), idOrName)
+		if err != nil {
+			return nil, fmt.Errorf("could not get network by ID or name: %w", err)
+		}
+		if network == nil {
+			return nil, notFoundError("network", idOrName)
+		}
+		zones := make(map[hcloud.NetworkZone]bool)
+		for _, subnet := range network.Subnets {
+			zones[subnet.NetworkZone] = true
+		}
+		networkZones = append(networkZones, zones)
+	}
+
+	locations, err := d.getClient().Location.All(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("could not list locations: %w", err)
+	}
+	locationZones := make(map[string]hcloud.NetworkZone, len(locations))
+	for _, location := range locations {
+		locationZones[location.Name] = location.NetworkZone
+	}
+
+	return func(location string) (hcloud.NetworkZone, bool) {
+		zone := locationZones[location]
+		for _, zones := range networkZones {
+			if !zones[zone] {
+				return zone, false
+			}
+		}
+		return zone, true
+	}, nil
+}
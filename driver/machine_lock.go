@@ -0,0 +1,59 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	machineLockFile     = "hetzner.lock"
+	machineLockTimeout  = 15 * time.Minute
+	machineLockInterval = 250 * time.Millisecond
+)
+
+// lockMachine acquires an advisory lock in the machine's store directory, serializing mutating operations of concurrent
+// docker-machine invocations against the same machine; the returned function releases it
+func (d *Driver) lockMachine() (func(), error) {
+	if d.StorePath == "" || d.MachineName == "" {
+		return func() {}, nil // not managed by docker-machine, e.g. standalone commands
+	}
+
+	dir := d.ResolveStorePath(".")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create machine directory: %w", err)
+	}
+	f, err := os.OpenFile(d.ResolveStorePath(machineLockFile), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open machine lock: %w", err)
+	}
+
+	deadline := time.Now().Add(machineLockTimeout)
+	for waiting := false; ; waiting = true {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("could not lock machine: %w", err)
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			_ = f.Close()
+			return nil, fmt.Errorf("machine %v is still locked by another operation after %v", d.MachineName, machineLockTimeout)
+		}
+		if !waiting {
+			log.Infof("Waiting for another operation on machine %v to finish ...", d.MachineName)
+		}
+		time.Sleep(machineLockInterval)
+	}
+
+	return func() {
+		if err := unlockFile(f); err != nil {
+			log.Debugf("could not unlock machine: %v", err)
+		}
+		_ = f.Close()
+	}, nil
+}
@@ -0,0 +1,42 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// machineMetadata is written onto the server to identify how it was created
+type machineMetadata struct {
+	MachineName   string            `json:"machine_name"`
+	DriverVersion string            `json:"driver_version"`
+	ServerType    string            `json:"server_type"`
+	Location      string            `json:"location,omitempty"`
+	Image         string            `json:"image"`
+	Labels        map[string]string `json:"labels"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// metadataCloudInitFile returns the file describing the machine, to be written to --hetzner-metadata-file
+func (d *Driver) metadataCloudInitFile() (cloudInitFile, error) {
+	image := d.Image
+	if image == "" {
+		image = strconv.FormatInt(d.ImageID, 10)
+	}
+
+	content, err := json.MarshalIndent(machineMetadata{
+		MachineName:   d.GetMachineName(),
+		DriverVersion: d.version,
+		ServerType:    d.Type,
+		Location:      d.Location,
+		Image:         image,
+		Labels:        d.ServerLabels,
+		CreatedAt:     time.Now().UTC().Truncate(time.Second),
+	}, "", "  ")
+	if err != nil {
+		return cloudInitFile{}, fmt.Errorf("could not serialize machine metadata: %w", err)
+	}
+
+	return cloudInitFile{Path: d.metadataFile, Permissions: "0644", Content: string(content) + "\n"}, nil
+}
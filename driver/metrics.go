@@ -0,0 +1,134 @@
+package driver
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const metricsJobName = "docker_machine_driver_hetzner"
+
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	operationsCounter = mustRegister(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hetzner_driver_operations_total",
+		Help: "A counter for driver operations per operation and result.",
+	}, []string{"operation", "result"}))
+
+	danglingCleanupCounter = mustRegister(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hetzner_driver_dangling_cleanups_total",
+		Help: "A counter for resources destroyed or left behind after failed creations per resource kind and result.",
+	}, []string{"resource", "result"}))
+
+	// the API metrics are registered once metrics are enabled, see setupClientMetrics
+	apiMetricsOnce      sync.Once
+	apiInFlightGauge    prometheus.Gauge
+	apiRequestsCounter  *prometheus.CounterVec
+	apiLatencyHistogram *prometheus.HistogramVec
+	apiEndpointStrip    = regexp.MustCompile("[^a-z/_]+")
+)
+
+func mustRegister[C prometheus.Collector](collector C) C {
+	metricsRegistry.MustRegister(collector)
+	return collector
+}
+
+func (d *Driver) metricsEnabled() bool {
+	return d.MetricsFile != "" || d.MetricsPushgateway != ""
+}
+
+// setupClientMetrics instruments the API client's transport; unlike the client's own instrumentation, which always
+// sends requests via the default transport, this keeps custom TLS settings. The metrics are named like the client's.
+func (d *Driver) setupClientMetrics(httpClient *http.Client) {
+	if !d.metricsEnabled() {
+		return
+	}
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	apiMetricsOnce.Do(func() {
+		apiInFlightGauge = mustRegister(prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "hcloud_api_in_flight_requests",
+			Help: "A gauge of in-flight requests to the hcloud api.",
+		}))
+		apiRequestsCounter = mustRegister(prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "hcloud_api_requests_total",
+			Help: "A counter for requests to the hcloud api per endpoint.",
+		}, []string{"code", "method", "api_endpoint"}))
+		apiLatencyHistogram = mustRegister(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "hcloud_api_request_duration_seconds",
+			Help:    "A histogram of request latencies to the hcloud api.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}))
+	})
+
+	countEndpoint := promhttp.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if err == nil {
+			apiRequestsCounter.WithLabelValues(strconv.Itoa(resp.StatusCode), strings.ToLower(req.Method),
+				apiEndpointLabel(req.URL.Path)).Inc()
+		}
+		return resp, err
+	})
+	httpClient.Transport = promhttp.InstrumentRoundTripperInFlight(apiInFlightGauge,
+		promhttp.InstrumentRoundTripperDuration(apiLatencyHistogram, countEndpoint))
+}
+
+// apiEndpointLabel strips IDs and the API version from the path of a request, e.g. /v1/volumes/42/actions/attach
+// becomes /volumes/actions/attach
+func apiEndpointLabel(path string) string {
+	path = apiEndpointStrip.ReplaceAllString(strings.ToLower(path), "")
+	path = strings.ReplaceAll(path, "//", "/")
+	return strings.Replace(path, "/v/", "/", 1)
+}
+
+func (d *Driver) recordOperation(name string, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	operationsCounter.WithLabelValues(name, result).Inc()
+}
+
+// recordDanglingCleanup counts the resources destroyed and left behind after a failed creation by kind
+func (d *Driver) recordDanglingCleanup(destroyed, leaked map[string]int) {
+	if !d.metricsEnabled() {
+		return
+	}
+	for kind, count := range destroyed {
+		danglingCleanupCounter.WithLabelValues(kind, "destroyed").Add(float64(count))
+	}
+	for kind, count := range leaked {
+		danglingCleanupCounter.WithLabelValues(kind, "leaked").Add(float64(count))
+	}
+}
+
+// flushMetrics writes the metrics collected so far to the configured textfile and/or pushgateway; failures are not
+// considered fatal, as they do not affect the machine itself
+func (d *Driver) flushMetrics() {
+	if d.MetricsFile != "" {
+		if err := prometheus.WriteToTextfile(d.MetricsFile, metricsRegistry); err != nil {
+			log.Warnf("could not write metrics to %v: %v", d.MetricsFile, err)
+		}
+	}
+
+	if d.MetricsPushgateway != "" {
+		err := push.New(d.MetricsPushgateway, metricsJobName).
+			Gatherer(metricsRegistry).
+			Grouping("machine", d.GetMachineName()).
+			Push()
+		if err != nil {
+			log.Warnf("could not push metrics to %v: %v", d.MetricsPushgateway, err)
+		}
+	}
+}
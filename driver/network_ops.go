@@ -0,0 +1,240 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// networkAttachAttempts bounds the attempts to attach to a network while it is modified concurrently
+const networkAttachAttempts = 5
+
+// AttachNetwork attaches the private network given by ID or name to the existing server, optionally with a static IP,
+// and adds it to the configured networks
+func (d *Driver) AttachNetwork(idOrName, ip string) (err error) {
+	defer d.operation("AttachNetwork")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	srv, network, err := d.getServerAndNetwork(idOrName)
+	if err != nil {
+		return err
+	}
+
+	opts := hcloud.ServerAttachToNetworkOpts{Network: network}
+	if ip != "" {
+		if opts.IP = net.ParseIP(ip); opts.IP == nil {
+			return fmt.Errorf("invalid IP address: %v", ip)
+		}
+	}
+
+	log.Infof("Attaching server %v[%d] to network %v[%d] ...", srv.Name, srv.ID, network.Name, network.ID)
+	if err = d.attachToNetwork(srv, opts); err != nil {
+		return fmt.Errorf("could not attach to network: %w", err)
+	}
+
+	d.Networks = append(withoutResource(d.Networks, network.ID, network.Name), network.Name)
+	d.forgetNetworkIP(network)
+	if ip != "" {
+		d.NetworkIPs[network.Name] = ip
+	}
+	return nil
+}
+
+// DetachNetwork detaches the private network given by ID or name from the existing server and removes it from the
+// configured networks; the network used to reach the server with --hetzner-use-private-network cannot be detached
+func (d *Driver) DetachNetwork(idOrName string) (err error) {
+	defer d.operation("DetachNetwork")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	srv, network, err := d.getServerAndNetwork(idOrName)
+	if err != nil {
+		return err
+	}
+	if d.UsePrivateNetwork && len(srv.PrivateNet) > 0 && srv.PrivateNet[0].Network.ID == network.ID {
+		return fmt.Errorf("network %v is used to reach the machine and cannot be detached", network.Name)
+	}
+
+	log.Infof("Detaching server %v[%d] from network %v[%d] ...", srv.Name, srv.ID, network.Name, network.ID)
+	action, _, err := d.getClient().Server.DetachFromNetwork(context.Background(), srv, hcloud.ServerDetachFromNetworkOpts{
+		Network: network,
+	})
+	if err != nil {
+		return fmt.Errorf("could not detach from network: %w", err)
+	}
+	if err = d.waitForAction(action); err != nil {
+		return fmt.Errorf("could not wait for network detachment: %w", err)
+	}
+
+	d.Networks = withoutResource(d.Networks, network.ID, network.Name)
+	d.forgetNetworkIP(network)
+	return nil
+}
+
+// parseNetworksFlag splits the network references of --hetzner-networks from the static IPs they may be suffixed with,
+// as in mynet:10.0.1.50
+func (d *Driver) parseNetworksFlag(values []string) ([]string, map[string]string, error) {
+	networks := make([]string, 0, len(values))
+	ips := make(map[string]string)
+	for _, value := range values {
+		idx := strings.LastIndex(value, ":")
+		if idx < 0 {
+			networks = append(networks, value)
+			continue
+		}
+
+		network, ip := value[:idx], value[idx+1:]
+		if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil {
+			return nil, nil, d.flagFailure("--%v: invalid IPv4 address %q for network %v", flagNetworks, ip, network)
+		}
+		networks = append(networks, network)
+		ips[network] = ip
+	}
+	return networks, ips, nil
+}
+
+// attachStaticIPNetworks attaches the newly created server to the networks given with a static IP
+func (d *Driver) attachStaticIPNetworks(srv *hcloud.Server) error {
+	for _, idOrName := range d.Networks {
+		ip := d.networkIP(idOrName)
+		if ip == "" {
+			continue
+		}
+
+		network, _, err := d.getClient().Network.Get(context.Background(), idOrName)
+		if err != nil {
+			return fmt.Errorf("could not get network by ID or name: %w", err)
+		}
+		if network == nil {
+			return notFoundError("network", idOrName)
+		}
+
+		log.Infof(" -> Attaching server %v[%d] to network %v[%d] with IP %v ...", srv.Name, srv.ID, network.Name, network.ID, ip)
+		err = d.attachToNetwork(srv, hcloud.ServerAttachToNetworkOpts{Network: network, IP: net.ParseIP(ip)})
+		if err != nil {
+			return fmt.Errorf("could not attach to network %v: %w", network.Name, err)
+		}
+	}
+	return nil
+}
+
+// forgetNetworkIP removes the static IP configured for the network, referenced by ID or name
+func (d *Driver) forgetNetworkIP(network *hcloud.Network) {
+	if d.NetworkIPs == nil {
+		d.NetworkIPs = make(map[string]string)
+	}
+	delete(d.NetworkIPs, network.Name)
+	delete(d.NetworkIPs, strconv.FormatInt(network.ID, 10))
+}
+
+// attachToNetwork attaches the server to a network, retrying with exponential backoff while the network is locked or
+// the IP is taken concurrently; without a static IP, the API assigns the next free one on each attempt
+func (d *Driver) attachToNetwork(srv *hcloud.Server, opts hcloud.ServerAttachToNetworkOpts) error {
+	delay := time.Duration(max(d.WaitOnPolling, 1)) * time.Second
+	for attempt := 1; ; attempt++ {
+		action, _, err := d.getClient().Server.AttachToNetwork(context.Background(), srv, opts)
+		if err == nil {
+			err = d.waitForAction(action)
+		}
+		if err == nil || !isNetworkAttachConflict(err, opts.IP != nil) {
+			return err
+		}
+		if attempt == networkAttachAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		log.Warnf("network attachment failed due to a conflict, retrying in %v: %v", delay, err)
+		d.recordRetry()
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// isNetworkAttachConflict reports whether attaching to a network failed due to a concurrent modification of the network,
+// which retrying may resolve; a static IP being taken is not resolved by retrying
+func isNetworkAttachConflict(err error, staticIP bool) bool {
+	switch hcloud.ErrorCode(errorCode(err)) {
+	case hcloud.ErrorCodeLocked, hcloud.ErrorCodeConflict:
+		return true
+	case hcloud.ErrorCodeIPNotAvailable:
+		return !staticIP
+	}
+	return false
+}
+
+// retryNetworkAttachments retries the network attachments among the failed actions, if all of them failed due to a
+// conflict; otherwise, or if retrying fails, the original error is returned
+func (d *Driver) retryNetworkAttachments(srv *hcloud.Server, actions []*hcloud.Action, actionsErr error) error {
+	failed, err := d.failedActions(actions)
+	if err != nil || len(failed) == 0 {
+		return actionsErr
+	}
+
+	var networks []*hcloud.Network
+	for _, a := range failed {
+		if a.Command != "attach_to_network" || !isNetworkAttachConflict(a.Error(), false) {
+			return actionsErr
+		}
+		for _, res := range a.Resources {
+			if res.Type == hcloud.ActionResourceType("network") {
+				networks = append(networks, &hcloud.Network{ID: res.ID})
+			}
+		}
+	}
+
+	for _, network := range networks {
+		log.Infof(" -> Retrying attachment of server %v[%d] to network [%d] ...", srv.Name, srv.ID, network.ID)
+		if err = d.attachToNetwork(srv, hcloud.ServerAttachToNetworkOpts{Network: network}); err != nil {
+			return errors.Join(actionsErr, fmt.Errorf("could not retry network attachment: %w", err))
+		}
+	}
+	return nil
+}
+
+func (d *Driver) getServerAndNetwork(idOrName string) (*hcloud.Server, *hcloud.Network, error) {
+	if d.Robot {
+		return nil, nil, errors.New("networks cannot be managed for robot servers")
+	}
+
+	srv, err := d.getServerHandle()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	network, _, err := d.getClient().Network.Get(context.Background(), idOrName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get network by ID or name: %w", err)
+	}
+	if network == nil {
+		return nil, nil, notFoundError("network", idOrName)
+	}
+	return srv, network, nil
+}
+
+// withoutResource returns the given resource references, which may be IDs or names, without those referring to the
+// resource of the given ID and name
+func withoutResource(refs []string, id int64, name string) []string {
+	ret := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		if ref != name && ref != strconv.FormatInt(id, 10) {
+			ret = append(ret, ref)
+		}
+	}
+	return ret
+}
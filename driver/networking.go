@@ -56,10 +56,14 @@ func (d *Driver) resolvePrimaryIP(raw string) (*hcloud.PrimaryIP, error) {
 		return instrumented(ip), nil
 	}
 
-	return nil, fmt.Errorf("primary IP not found: %v", raw)
+	return nil, notFoundError("primary IP", raw)
 }
 
 func (d *Driver) setPublicNetIfRequired(srvopts *hcloud.ServerCreateOpts) error {
+	if err := d.allocatePrimaryIPs(); err != nil {
+		return err
+	}
+
 	pip4, err := d.getPrimaryIPv4()
 	if err != nil {
 		return err
@@ -98,13 +102,7 @@ func (d *Driver) configureNetworkAccess(srv hcloud.ServerCreateResult) error {
 	} else if d.DisablePublic4 {
 		log.Infof("Using public IPv6 network ...")
 
-		pv6 := srv.Server.PublicNet.IPv6
-		ip := pv6.IP
-		if ip.Mask(pv6.Network.Mask).Equal(pv6.Network.IP) { // no host given
-			ip[net.IPv6len-1] |= 0x01 // TODO make this configurable
-		}
-
-		ips := ip.String()
+		ips := publicIPv6Address(srv.Server.PublicNet.IPv6)
 		log.Infof(" -> resolved %v ...", ips)
 		d.IPAddress = ips
 	} else {
@@ -113,3 +111,31 @@ func (d *Driver) configureNetworkAccess(srv hcloud.ServerCreateResult) error {
 	}
 	return nil
 }
+
+// publicIPv6Address returns the address to use for the public IPv6 network of a server, picking the first host address
+// if only a network is assigned
+func publicIPv6Address(pv6 hcloud.ServerPublicNetIPv6) string {
+	ip := append(net.IP(nil), pv6.IP...)
+	if ip.Mask(pv6.Network.Mask).Equal(pv6.Network.IP) { // no host given
+		ip[net.IPv6len-1] |= 0x01 // TODO make this configurable
+	}
+	return ip.String()
+}
+
+// liveIPAddress returns the address docker-machine should use for the given server, according to the network
+// configuration, or an empty string if it has none (yet)
+func (d *Driver) liveIPAddress(srv *hcloud.Server) string {
+	switch {
+	case d.FloatingIPID != 0:
+		return d.IPAddress // the floating IP does not change with the server
+	case d.UsePrivateNetwork:
+		if len(srv.PrivateNet) == 0 {
+			return ""
+		}
+		return srv.PrivateNet[0].IP.String()
+	case d.DisablePublic4:
+		return publicIPv6Address(srv.PublicNet.IPv6)
+	default:
+		return srv.PublicNet.IPv4.IP.String()
+	}
+}
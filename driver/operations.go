@@ -0,0 +1,22 @@
+package driver
+
+// operation sets up the instrumentation of a driver operation; the returned function finishes it, given a pointer to
+// the operation's result
+func (d *Driver) operation(name string) func(*error) {
+	debugAPIPayloads = d.DebugAPIPayloads
+	endTrace := d.traceOperation(name)
+	endUsage := d.trackUsage(name)
+	endInFlight := d.beginOperation()
+
+	return func(err *error) {
+		*err = withTokenHint(withCorrelationID(*err))
+		endUsage()
+		endTrace(err)
+
+		if d.metricsEnabled() {
+			d.recordOperation(name, *err)
+			d.flushMetrics()
+		}
+		endInFlight()
+	}
+}
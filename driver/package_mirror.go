@@ -0,0 +1,63 @@
+package driver
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	packageMirrorHetzner = "hetzner"
+	hetznerMirrorURL     = "https://mirror.hetzner.com"
+	dnfConfigFile        = "/etc/dnf/dnf.conf"
+)
+
+// setPackageMirrorFlag validates --hetzner-package-mirror, being either "hetzner" or the URL of an apt mirror
+func (d *Driver) setPackageMirrorFlag(mirror string) error {
+	d.PackageMirror = mirror
+	if mirror == "" || mirror == packageMirrorHetzner {
+		return nil
+	}
+	if u, err := url.Parse(mirror); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return d.flagFailure("--%v must be %q or an http(s) URL, got: %v", flagPackageMirror, packageMirrorHetzner, mirror)
+	}
+	return nil
+}
+
+// packageMirrorCloudConfig adds the cloud-init configuration pointing the package manager of the image's OS flavor at
+// the mirror given by --hetzner-package-mirror; Hetzner's mirror is only reachable from within its network
+func (d *Driver) packageMirrorCloudConfig(config *cloudConfig) error {
+	image, err := d.getImage()
+	if err != nil {
+		return fmt.Errorf("could not get image for package mirror: %w", err)
+	}
+
+	switch flavor := image.OSFlavor; flavor {
+	case "ubuntu", "debian":
+		primary, security := d.PackageMirror, ""
+		if primary == packageMirrorHetzner {
+			primary = fmt.Sprintf("%v/%v/packages", hetznerMirrorURL, flavor)
+			security = fmt.Sprintf("%v/%v/security", hetznerMirrorURL, flavor)
+		}
+		config.Apt = &cloudInitApt{Primary: []cloudInitAptMirror{{Arches: []string{"default"}, URI: primary}}}
+		if security != "" {
+			config.Apt.Security = []cloudInitAptMirror{{Arches: []string{"default"}, URI: security}}
+		}
+	case "fedora", "centos", "rocky", "alma":
+		if d.PackageMirror != packageMirrorHetzner {
+			log.Warnf("--%v: custom mirrors are only supported for apt, ignoring it for %v", flagPackageMirror, flavor)
+			return nil
+		}
+		// there is no Hetzner mirror for these, but dnf picks the closest of the official mirrors, often hosted at Hetzner
+		config.WriteFiles = append(config.WriteFiles, cloudInitFile{
+			Path:        dnfConfigFile,
+			Permissions: "0644",
+			Content:     "fastestmirror=True\n",
+			Append:      true,
+		})
+	default:
+		log.Warnf("--%v: unsupported OS flavor %q of image %v, not configuring a package mirror", flagPackageMirror, flavor, image.Name)
+	}
+	return nil
+}
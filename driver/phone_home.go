@@ -0,0 +1,137 @@
+package driver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// phoneHomeTimeout limits waiting for the boot notification if --hetzner-wait-for-running-timeout is not set
+const phoneHomeTimeout = 5 * time.Minute
+
+// phoneHomeListener receives the notification cloud-init's phone_home module sends once the server finished booting
+type phoneHomeListener struct {
+	listener net.Listener
+	server   *http.Server
+	token    string
+	done     chan struct{}
+}
+
+// setPhoneHomeFlags validates the --hetzner-phone-home-url and --hetzner-phone-home-listen flags
+func (d *Driver) setPhoneHomeFlags(rawURL, listen string) error {
+	d.phoneHomeURL = rawURL
+	d.phoneHomeListen = listen
+	if rawURL == "" {
+		if listen != "" {
+			return d.flagFailure("--%v requires --%v to be set", flagPhoneHomeListen, flagPhoneHomeURL)
+		}
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return d.flagFailure("--%v must be an absolute http(s) URL, got: %v", flagPhoneHomeURL, rawURL)
+	}
+	if listen != "" {
+		if _, _, err := net.SplitHostPort(listen); err != nil {
+			return d.flagFailure("--%v must be a host:port address: %v", flagPhoneHomeListen, err)
+		}
+	}
+	return nil
+}
+
+// phoneHomeCloudConfig returns the phone_home module configuration notifying --hetzner-phone-home-url; when listening
+// locally, the URL is suffixed by a random token identifying the server
+func (d *Driver) phoneHomeCloudConfig() *cloudInitPhoneHome {
+	target := d.phoneHomeURL
+	if d.phoneHome != nil {
+		target = strings.TrimSuffix(target, "/") + "/" + d.phoneHome.token
+	}
+	return &cloudInitPhoneHome{
+		URL:   target,
+		Post:  []string{"instance_id", "hostname"},
+		Tries: 10,
+	}
+}
+
+// listenPhoneHome starts listening on --hetzner-phone-home-listen, if set, for the server's boot notification
+func (d *Driver) listenPhoneHome() error {
+	if d.phoneHomeListen == "" {
+		return nil
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return fmt.Errorf("could not generate phone home token: %w", err)
+	}
+	listener, err := net.Listen("tcp", d.phoneHomeListen)
+	if err != nil {
+		return fmt.Errorf("could not listen for phone home: %w", err)
+	}
+
+	ph := &phoneHomeListener{
+		listener: listener,
+		token:    hex.EncodeToString(token),
+		done:     make(chan struct{}),
+	}
+	var once sync.Once
+	ph.server = &http.Server{
+		ReadHeaderTimeout: 10 * time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || strings.Trim(r.URL.Path, "/") != ph.token {
+				http.NotFound(w, r)
+				return
+			}
+			_ = r.ParseForm()
+			log.Debugf("received phone home from %v (instance %v, hostname %v)", r.RemoteAddr, r.PostForm.Get("instance_id"), r.PostForm.Get("hostname"))
+			once.Do(func() { close(ph.done) })
+		}),
+	}
+	go func() {
+		if err := ph.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Warnf("phone home listener failed: %v", err)
+		}
+	}()
+
+	log.Infof(" -> Listening for phone home on %v", listener.Addr())
+	d.phoneHome = ph
+	return nil
+}
+
+// closePhoneHome stops listening for the boot notification
+func (d *Driver) closePhoneHome() {
+	if d.phoneHome == nil {
+		return
+	}
+	_ = d.phoneHome.server.Close()
+	d.phoneHome = nil
+}
+
+// waitForPhoneHome waits for the boot notification, for at most --hetzner-wait-for-running-timeout seconds if set;
+// it returns false if none arrived, so the caller may fall back to polling
+func (d *Driver) waitForPhoneHome() bool {
+	timeout := phoneHomeTimeout
+	if d.WaitForRunningTimeout > 0 {
+		timeout = time.Duration(d.WaitForRunningTimeout) * time.Second
+	}
+
+	select {
+	case <-d.phoneHome.done:
+		log.Infof(" -> Server phoned home")
+		return true
+	case <-d.shutdownSignal():
+		return false
+	case <-time.After(timeout):
+		log.Warnf("server did not phone home within %v, polling its state", timeout)
+		return false
+	}
+}
@@ -0,0 +1,148 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// placementMoveShutdownTimeout bounds the graceful shutdown of a server moved between placement groups, after which it
+// is powered off
+const placementMoveShutdownTimeout = 2 * time.Minute
+
+// MovePlacementGroup moves the existing server into the placement group given by ID or name, or out of its current one
+// if idOrName is empty, so placement policy changes do not require recreating the machine; as this requires the server
+// to be off, a running server is shut down, or powered off if it does not stop in time, and started again afterwards
+func (d *Driver) MovePlacementGroup(idOrName string) (err error) {
+	defer d.operation("MovePlacementGroup")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.Robot {
+		return errors.New("placement groups cannot be managed for robot servers")
+	}
+
+	srv, err := d.getServerHandle()
+	if err != nil {
+		return fmt.Errorf("could not get server handle: %w", err)
+	}
+
+	var target *hcloud.PlacementGroup
+	if idOrName != "" {
+		target, _, err = d.getClient().PlacementGroup.Get(context.Background(), idOrName)
+		if err != nil {
+			return fmt.Errorf("could not get placement group by ID or name: %w", err)
+		}
+		if target == nil {
+			return notFoundError("placement group", idOrName)
+		}
+	}
+
+	if !needsPlacementGroupMove(srv.PlacementGroup, target) {
+		log.Infof("Server %v[%d] is already in the requested placement group", srv.Name, srv.ID)
+		return nil
+	}
+
+	if srv.Status != hcloud.ServerStatusOff {
+		if err = d.shutdownForMove(srv); err != nil {
+			return err
+		}
+		defer func() {
+			log.Infof("Starting server %v[%d] ...", srv.Name, srv.ID)
+			act, _, startErr := d.getClient().Server.Poweron(context.Background(), srv)
+			if startErr == nil {
+				startErr = d.waitForAction(act)
+			}
+			if startErr != nil {
+				err = errors.Join(err, fmt.Errorf("could not start server: %w", startErr))
+			}
+		}()
+	}
+
+	current := srv.PlacementGroup
+	if current != nil {
+		log.Infof("Removing server %v[%d] from placement group %v[%d] ...", srv.Name, srv.ID, current.Name, current.ID)
+		act, _, err := d.getClient().Server.RemoveFromPlacementGroup(context.Background(), srv)
+		if err == nil {
+			err = d.waitForAction(act)
+		}
+		if err != nil {
+			return fmt.Errorf("could not remove server from placement group: %w", err)
+		}
+	}
+
+	if target != nil {
+		log.Infof("Adding server %v[%d] to placement group %v[%d] ...", srv.Name, srv.ID, target.Name, target.ID)
+		if err = d.addToPlacementGroup(srv, target); err != nil {
+			err = fmt.Errorf("could not add server to placement group: %w", err)
+			if current != nil {
+				log.Infof(" -> Restoring placement group %v[%d] ...", current.Name, current.ID)
+				if restoreErr := d.addToPlacementGroup(srv, current); restoreErr != nil {
+					err = errors.Join(err, fmt.Errorf("could not restore placement group: %w", restoreErr))
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// needsPlacementGroupMove reports whether a server in the current placement group, if any, has to be moved to reach the
+// target placement group, where nil means none
+func needsPlacementGroupMove(current, target *hcloud.PlacementGroup) bool {
+	if current == nil || target == nil {
+		return current != target
+	}
+	return current.ID != target.ID
+}
+
+func (d *Driver) addToPlacementGroup(srv *hcloud.Server, pg *hcloud.PlacementGroup) error {
+	act, _, err := d.getClient().Server.AddToPlacementGroup(context.Background(), srv, pg)
+	if err != nil {
+		return err
+	}
+	return d.waitForAction(act)
+}
+
+// shutdownForMove shuts the server down gracefully and waits until it is off, powering it off after
+// placementMoveShutdownTimeout
+func (d *Driver) shutdownForMove(srv *hcloud.Server) error {
+	log.Infof("Shutting down server %v[%d] ...", srv.Name, srv.ID)
+	act, _, err := d.getClient().Server.Shutdown(context.Background(), srv)
+	if err == nil {
+		err = d.waitForAction(act)
+	}
+	if err != nil {
+		return fmt.Errorf("could not shutdown server: %w", err)
+	}
+
+	deadline := time.Now().Add(placementMoveShutdownTimeout)
+	for time.Now().Before(deadline) {
+		current, _, err := d.getClient().Server.GetByID(context.Background(), srv.ID)
+		if err != nil {
+			return fmt.Errorf("could not get server state: %w", err)
+		}
+		if current != nil && current.Status == hcloud.ServerStatusOff {
+			return nil
+		}
+		time.Sleep(time.Duration(max(d.WaitOnPolling, 1)) * time.Second)
+	}
+
+	log.Warnf("server %v[%d] did not shut down within %v, powering it off", srv.Name, srv.ID, placementMoveShutdownTimeout)
+	act, _, err = d.getClient().Server.Poweroff(context.Background(), srv)
+	if err == nil {
+		err = d.waitForAction(act)
+	}
+	if err != nil {
+		return fmt.Errorf("could not power off server: %w", err)
+	}
+	return nil
+}
@@ -3,20 +3,26 @@ package driver
 import (
 	"context"
 	"fmt"
+	"strings"
 
-	"github.com/docker/machine/libmachine/log"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
 
 const (
-	labelAutoSpreadPg = "auto-spread"
-	labelAutoCreated  = "auto-created"
-	autoSpreadPgName  = "__auto_spread"
+	labelAutoSpreadPg       = "auto-spread"
+	labelAutoCreated        = "auto-created"
+	autoSpreadPgName        = "__auto_spread"
+	autoSpreadPgDefaultName = "Docker-Machine auto spread"
 )
 
 func (d *Driver) getAutoPlacementGroup() (*hcloud.PlacementGroup, error) {
+	name, value := autoSpreadPgDefaultName, "true"
+	if d.autoSpreadName != "" {
+		name, value = d.autoSpreadName, labelValue(d.autoSpreadName)
+	}
+
 	res, err := d.getClient().PlacementGroup.AllWithOpts(context.Background(), hcloud.PlacementGroupListOpts{
-		ListOpts: hcloud.ListOpts{LabelSelector: d.labelName(labelAutoSpreadPg)},
+		ListOpts: hcloud.ListOpts{LabelSelector: d.labelName(labelAutoSpreadPg) + "=" + value},
 	})
 
 	if err != nil {
@@ -27,14 +33,44 @@ func (d *Driver) getAutoPlacementGroup() (*hcloud.PlacementGroup, error) {
 		return res[0], nil
 	}
 
-	grp, err := d.makePlacementGroup("Docker-Machine auto spread", map[string]string{
-		d.labelName(labelAutoSpreadPg): "true",
-		d.labelName(labelAutoCreated):  "true",
-	})
+	labels := make(map[string]string, len(d.autoSpreadLabels)+2)
+	for k, v := range d.autoSpreadLabels {
+		labels[k] = v
+	}
+	// set last, as the group is found by them
+	labels[d.labelName(labelAutoSpreadPg)] = value
+	labels[d.labelName(labelAutoCreated)] = "true"
+	grp, err := d.makePlacementGroup(name, labels)
 
 	return instrumented(grp), err
 }
 
+// setAutoSpreadFlags validates the --hetzner-auto-spread-group-name and --hetzner-auto-spread-group-label flags, which
+// give independent controllers sharing a project separate auto-spread groups
+func (d *Driver) setAutoSpreadFlags(name string, labels []string) error {
+	if (name != "" || len(labels) != 0) && d.placementGroup != autoSpreadPgName {
+		return d.flagFailure("--%v and --%v require --%v", flagAutoSpreadName, flagAutoSpreadLabel, flagAutoSpread)
+	}
+	if name != "" && labelValue(name) == "" {
+		return d.flagFailure("--%v: %q contains no characters usable in a label", flagAutoSpreadName, name)
+	}
+
+	d.autoSpreadName = name
+	d.autoSpreadLabels = make(map[string]string)
+	for _, label := range labels {
+		split := strings.SplitN(label, "=", 2)
+		if len(split) != 2 {
+			return d.flagFailure("auto-spread group label %v is not in key=value format", label)
+		}
+		if strings.HasPrefix(split[0], labelNamespace+"/") {
+			return d.flagFailure("--%v: labels of the %v/ namespace are reserved for the driver: %v",
+				flagAutoSpreadLabel, labelNamespace, label)
+		}
+		d.autoSpreadLabels[split[0]] = split[1]
+	}
+	return nil
+}
+
 func (d *Driver) makePlacementGroup(name string, labels map[string]string) (*hcloud.PlacementGroup, error) {
 	grp, _, err := d.getClient().PlacementGroup.Create(context.Background(), instrumented(hcloud.PlacementGroupCreateOpts{
 		Name:   name,
@@ -43,11 +79,9 @@ func (d *Driver) makePlacementGroup(name string, labels map[string]string) (*hcl
 	}))
 
 	if grp.PlacementGroup != nil {
-		d.dangling = append(d.dangling, func() {
+		d.trackDangling("placement group", func() error {
 			_, err := d.getClient().PlacementGroup.Delete(context.Background(), grp.PlacementGroup)
-			if err != nil {
-				log.Errorf("could not delete placement group: %v", err)
-			}
+			return err
 		})
 	}
 
@@ -70,6 +104,10 @@ func (d *Driver) getPlacementGroup() (*hcloud.PlacementGroup, error) {
 		grp, err := d.getAutoPlacementGroup()
 		d.cachedPGrp = grp
 		return grp, err
+	} else if name == antiAffinityPgName {
+		grp, err := d.getAntiAffinityPlacementGroup()
+		d.cachedPGrp = grp
+		return grp, err
 	} else {
 		client := d.getClient().PlacementGroup
 		grp, _, err := client.Get(context.Background(), name)
@@ -80,6 +118,9 @@ func (d *Driver) getPlacementGroup() (*hcloud.PlacementGroup, error) {
 		if grp != nil {
 			return grp, nil
 		}
+		if _, ok := numericID(name); ok {
+			return nil, notFoundError("placement group", name)
+		}
 
 		return d.makePlacementGroup(name, map[string]string{d.labelName(labelAutoCreated): "true"})
 	}
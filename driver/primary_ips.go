@@ -0,0 +1,257 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	labelPrimaryIPPool = "primary-ip-pool"
+	labelMachine       = "machine"
+)
+
+// allocatePrimaryIPs takes over retained primary IPs of a previous machine, if requested, and allocates pool primary
+// IPs for the remaining address families
+func (d *Driver) allocatePrimaryIPs() error {
+	if err := d.reusePrimaryIPsOfMachine(); err != nil {
+		return err
+	}
+	return d.allocatePoolPrimaryIPs()
+}
+
+func (d *Driver) reusePrimaryIPsOfMachine() error {
+	if d.reusePrimaryIPOf == "" {
+		return nil
+	}
+
+	ips, err := d.getClient().PrimaryIP.AllWithOpts(context.Background(), hcloud.PrimaryIPListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: fmt.Sprintf("%v=%v", d.labelName(labelMachine), d.reusePrimaryIPOf)},
+	})
+	if err != nil {
+		return fmt.Errorf("could not list primary IPs: %w", err)
+	}
+
+	found := false
+	for _, ip := range ips {
+		if ip.AssigneeID != 0 {
+			log.Debugf("primary IP %v[%d] is still assigned, ignoring", ip.IP, ip.ID)
+			continue
+		}
+
+		var target *string
+		var cached **hcloud.PrimaryIP
+		switch {
+		case ip.Type == hcloud.PrimaryIPTypeIPv4 && !d.DisablePublic4 && d.PrimaryIPv4 == "":
+			target, cached = &d.PrimaryIPv4, &d.cachedPrimaryIPv4
+		case ip.Type == hcloud.PrimaryIPTypeIPv6 && !d.DisablePublic6 && d.PrimaryIPv6 == "":
+			target, cached = &d.PrimaryIPv6, &d.cachedPrimaryIPv6
+		default:
+			continue
+		}
+
+		if err := d.adoptPrimaryIPLocation(ip); err != nil {
+			return err
+		}
+
+		log.Infof(" -> Reusing %v primary IP %v[%d] of machine %v", ip.Type, ip.IP, ip.ID, d.reusePrimaryIPOf)
+		claimed, err := d.claimPrimaryIP(ip)
+		if err != nil {
+			return err
+		}
+		*target = strconv.FormatInt(claimed.ID, 10)
+		*cached = claimed
+		found = true
+	}
+
+	if !found {
+		return fmt.Errorf("no unassigned primary IP labeled %v=%v found", d.labelName(labelMachine), d.reusePrimaryIPOf)
+	}
+	return nil
+}
+
+// adoptPrimaryIPLocation makes sure the server is created in the location a reused primary IP resides in
+func (d *Driver) adoptPrimaryIPLocation(ip *hcloud.PrimaryIP) error {
+	if ip.Datacenter == nil || ip.Datacenter.Location == nil {
+		return nil
+	}
+
+	if d.Location == "" {
+		log.Infof(" -> Using location %v of primary IP %v", ip.Datacenter.Location.Name, ip.IP)
+		d.Location = ip.Datacenter.Location.Name
+		d.cachedLocation = nil
+	} else if d.Location != ip.Datacenter.Location.Name {
+		return fmt.Errorf("primary IP %v resides in %v, but server is to be created in %v", ip.IP, ip.Datacenter.Location.Name, d.Location)
+	}
+	return nil
+}
+
+// allocatePoolPrimaryIPs reuses free primary IPs from the configured pool or explicitly creates labeled ones, for
+// all address families not disabled and not explicitly specified by the user
+func (d *Driver) allocatePoolPrimaryIPs() error {
+	if d.PrimaryIPPool == "" {
+		return nil
+	}
+
+	if !d.DisablePublic4 && d.PrimaryIPv4 == "" {
+		ip, err := d.allocatePoolPrimaryIP(hcloud.PrimaryIPTypeIPv4)
+		if err != nil {
+			return fmt.Errorf("could not allocate primary IPv4: %w", err)
+		}
+		d.PrimaryIPv4 = strconv.FormatInt(ip.ID, 10)
+		d.cachedPrimaryIPv4 = ip
+	}
+
+	if !d.DisablePublic6 && d.PrimaryIPv6 == "" {
+		ip, err := d.allocatePoolPrimaryIP(hcloud.PrimaryIPTypeIPv6)
+		if err != nil {
+			return fmt.Errorf("could not allocate primary IPv6: %w", err)
+		}
+		d.PrimaryIPv6 = strconv.FormatInt(ip.ID, 10)
+		d.cachedPrimaryIPv6 = ip
+	}
+
+	return nil
+}
+
+func (d *Driver) allocatePoolPrimaryIP(ipType hcloud.PrimaryIPType) (*hcloud.PrimaryIP, error) {
+	location, err := d.getLocationNullable()
+	if err != nil {
+		return nil, err
+	}
+	if location == nil {
+		return nil, fmt.Errorf("--%v requires --%v to be set", flagPrimaryIPPool, flagLocation)
+	}
+
+	ip, err := d.getFreePoolPrimaryIPNullable(ipType, location)
+	if err != nil {
+		return nil, err
+	}
+
+	if ip != nil {
+		log.Infof(" -> Reusing %v primary IP %v[%d] from pool %v", ipType, ip.IP, ip.ID, d.PrimaryIPPool)
+		return d.claimPrimaryIP(ip)
+	}
+
+	return d.makePoolPrimaryIP(ipType, location)
+}
+
+func (d *Driver) getFreePoolPrimaryIPNullable(ipType hcloud.PrimaryIPType, location *hcloud.Location) (*hcloud.PrimaryIP, error) {
+	ips, err := d.getClient().PrimaryIP.AllWithOpts(context.Background(), hcloud.PrimaryIPListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: fmt.Sprintf("%v=%v", d.labelName(labelPrimaryIPPool), d.PrimaryIPPool)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list primary IPs: %w", err)
+	}
+
+	for _, ip := range ips {
+		if ip.AssigneeID == 0 && ip.Type == ipType && ip.Datacenter != nil && ip.Datacenter.Location != nil &&
+			ip.Datacenter.Location.Name == location.Name {
+			return instrumented(ip), nil
+		}
+	}
+	return nil, nil
+}
+
+// claimPrimaryIP labels a reused primary IP as belonging to this machine
+func (d *Driver) claimPrimaryIP(ip *hcloud.PrimaryIP) (*hcloud.PrimaryIP, error) {
+	labels := make(map[string]string, len(ip.Labels)+1)
+	for k, v := range ip.Labels {
+		labels[k] = v
+	}
+	labels[d.labelName(labelMachine)] = d.GetMachineName()
+
+	var updated *hcloud.PrimaryIP
+	err := d.retry("primary IP labeling", func() (err error) {
+		updated, _, err = d.getClient().PrimaryIP.Update(context.Background(), ip, hcloud.PrimaryIPUpdateOpts{Labels: &labels})
+		return
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not label primary IP: %w", err)
+	}
+	return instrumented(updated), nil
+}
+
+func (d *Driver) makePoolPrimaryIP(ipType hcloud.PrimaryIPType, location *hcloud.Location) (*hcloud.PrimaryIP, error) {
+	datacenter, err := d.getDatacenterForLocation(location)
+	if err != nil {
+		return nil, err
+	}
+
+	autoDelete := false
+	res, _, err := d.getClient().PrimaryIP.Create(context.Background(), instrumented(hcloud.PrimaryIPCreateOpts{
+		Name:         fmt.Sprintf("%v-%v", d.GetMachineName(), ipType),
+		Type:         ipType,
+		AssigneeType: "server",
+		AutoDelete:   &autoDelete,
+		Datacenter:   datacenter.Name,
+		Labels: map[string]string{
+			d.labelName(labelPrimaryIPPool): d.PrimaryIPPool,
+			d.labelName(labelMachine):       d.GetMachineName(),
+			d.labelName(labelAutoCreated):   "true",
+		},
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("could not create primary IP: %w", err)
+	}
+
+	ip := res.PrimaryIP
+	log.Infof(" -> Created %v primary IP %v[%d] in pool %v", ipType, ip.IP, ip.ID, d.PrimaryIPPool)
+
+	d.trackDangling("primary IP", func() error {
+		_, err := d.getClient().PrimaryIP.Delete(context.Background(), ip)
+		return err
+	})
+
+	return instrumented(ip), nil
+}
+
+// trackDefaultPrimaryIPs registers the primary IPs the API created along with the server, i.e. those not given by
+// flags, for cleanup should creation fail; those still assigned to the server are left to be deleted along with it
+func (d *Driver) trackDefaultPrimaryIPs(srv *hcloud.Server) {
+	var ids []int64
+	if d.cachedPrimaryIPv4 == nil && srv.PublicNet.IPv4.ID != 0 {
+		ids = append(ids, srv.PublicNet.IPv4.ID)
+	}
+	if d.cachedPrimaryIPv6 == nil && srv.PublicNet.IPv6.ID != 0 {
+		ids = append(ids, srv.PublicNet.IPv6.ID)
+	}
+
+	for _, id := range ids {
+		id := id
+		d.trackDangling("primary IP", func() error {
+			ip, _, err := d.getClient().PrimaryIP.GetByID(context.Background(), id)
+			if err != nil {
+				return fmt.Errorf("could not get primary IP %d: %w", id, err)
+			}
+			if ip == nil {
+				return nil
+			}
+			if ip.AssigneeID != 0 {
+				log.Infof(" -> Primary IP %v[%d] is still assigned to server %d and will be deleted along with it", ip.IP, ip.ID, ip.AssigneeID)
+				return nil
+			}
+
+			log.Infof(" -> Deleting primary IP %v[%d] created with the server", ip.IP, ip.ID)
+			_, err = d.getClient().PrimaryIP.Delete(context.Background(), ip)
+			return err
+		})
+	}
+}
+
+func (d *Driver) getDatacenterForLocation(location *hcloud.Location) (*hcloud.Datacenter, error) {
+	datacenters, err := d.getClient().Datacenter.All(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("could not list datacenters: %w", err)
+	}
+
+	for _, datacenter := range datacenters {
+		if datacenter.Location != nil && datacenter.Location.Name == location.Name {
+			return datacenter, nil
+		}
+	}
+	return nil, fmt.Errorf("no datacenter found in location %v", location.Name)
+}
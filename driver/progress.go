@@ -0,0 +1,11 @@
+package driver
+
+import "github.com/docker/machine/libmachine/log"
+
+// progressPrefix marks coarse progress lines during creation, to be picked up by provisioning UIs such as Rancher's
+const progressPrefix = "[progress]"
+
+// progress logs a coarse progress marker in the parseable form "[progress] <percent>% <stage>"
+func progress(percent int, stage string) {
+	log.Infof("%v %d%% %v", progressPrefix, percent, stage)
+}
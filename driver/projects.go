@@ -0,0 +1,87 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const projectsFile = "projects.yaml"
+
+// setProjectFlags selects the project given by --hetzner-project, whose token is read from the projects file instead of
+// being stored with the machine
+func (d *Driver) setProjectFlags(project, path string) error {
+	d.Project, d.ProjectsFile, d.projectToken = project, path, ""
+	if project == "" {
+		return nil
+	}
+	if d.AccessToken != "" && d.AccessToken != os.Getenv(envHcloudToken) {
+		return d.flagFailure("--%v and --%v are mutually exclusive", flagAPIToken, flagProject)
+	}
+
+	d.AccessToken = ""
+	if d.offline {
+		return nil // the projects file holding the token may not be available, see ValidateFlags
+	}
+	token, err := d.readProjectToken()
+	if err != nil {
+		return d.flagFailure("%v", err)
+	}
+	d.projectToken = token
+	return nil
+}
+
+// readProjectToken reads the token of the selected project from the projects file, defaulting to projects.yaml in the
+// user's config directory
+func (d *Driver) readProjectToken() (string, error) {
+	path := d.ProjectsFile
+	if path == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("could not determine projects file location: %w", err)
+		}
+		path = filepath.Join(dir, profilesDir, projectsFile)
+	}
+
+	raw, err := readConfigFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read projects file %v: %w", path, err)
+	}
+
+	entry, ok := raw[d.Project]
+	if !ok {
+		names := make([]string, 0, len(raw))
+		for name := range raw {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("project '%v' not found in %v, available projects: %v", d.Project, path, strings.Join(names, ", "))
+	}
+
+	settings, _ := entry.(map[string]interface{})
+	token, _ := settings["token"].(string)
+	if token == "" {
+		return "", fmt.Errorf("project '%v' in %v has no token", d.Project, path)
+	}
+	return token, nil
+}
+
+// apiToken returns the token to use for the cloud API, i.e. the selected project's or the configured one
+func (d *Driver) apiToken() string {
+	if d.Project == "" {
+		return d.AccessToken
+	}
+
+	if d.projectToken == "" {
+		token, err := d.readProjectToken()
+		if err != nil {
+			log.Errorf("could not get API token: %v", err)
+		}
+		d.projectToken = token
+	}
+	return d.projectToken
+}
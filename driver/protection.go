@@ -0,0 +1,74 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// enableProtection enables the delete and rebuild protection of the server if --hetzner-protection is given; as it is
+// the last step of creation, failed creations can still be rolled back
+func (d *Driver) enableProtection(srv *hcloud.Server) error {
+	if !d.Protection {
+		return nil
+	}
+
+	log.Infof(" -> Enabling delete and rebuild protection of server %s[%d] ...", srv.Name, srv.ID)
+	act, _, err := d.getClient().Server.ChangeProtection(context.Background(), srv, hcloud.ServerChangeProtectionOpts{
+		Delete:  hcloud.Ptr(true),
+		Rebuild: hcloud.Ptr(true),
+	})
+	if err != nil {
+		return fmt.Errorf("could not enable protection: %w", err)
+	}
+	if err = d.waitForAction(act); err != nil {
+		return fmt.Errorf("could not wait for protection to be enabled: %w", err)
+	}
+	return nil
+}
+
+// forceRemoveOnce reports whether HETZNER_FORCE_REMOVE forces the current removal
+func forceRemoveOnce() bool {
+	force, _ := strconv.ParseBool(os.Getenv(envForceRemove))
+	return force
+}
+
+// forceRemove reports whether a server may be removed despite its protection, given by --hetzner-force-remove when
+// creating the machine or HETZNER_FORCE_REMOVE when removing it
+func (d *Driver) forceRemove() bool {
+	return d.ForceRemove || forceRemoveOnce()
+}
+
+// checkDeleteProtection refuses to remove a server with delete protection enabled, unless removal is forced
+func (d *Driver) checkDeleteProtection(srv *hcloud.Server) error {
+	if srv == nil || !srv.Protection.Delete || d.forceRemove() {
+		return nil
+	}
+	return fmt.Errorf("server %v[%d] has delete protection enabled; disable it, pass --%v when creating the machine or "+
+		"set %v=true to disable it on removal", srv.Name, srv.ID, flagForceRemove, envForceRemove)
+}
+
+// disableProtection disables the delete and rebuild protection of the server before removing it, see
+// checkDeleteProtection
+func (d *Driver) disableProtection(srv *hcloud.Server) error {
+	if srv == nil || (!srv.Protection.Delete && !srv.Protection.Rebuild) {
+		return nil
+	}
+
+	log.Infof(" -> Disabling protection of server %s[%d] ...", srv.Name, srv.ID)
+	act, _, err := d.getClient().Server.ChangeProtection(context.Background(), srv, hcloud.ServerChangeProtectionOpts{
+		Delete:  hcloud.Ptr(false),
+		Rebuild: hcloud.Ptr(false),
+	})
+	if err != nil {
+		return fmt.Errorf("could not disable protection: %w", err)
+	}
+	if err = d.waitForAction(act); err != nil {
+		return fmt.Errorf("could not wait for protection to be disabled: %w", err)
+	}
+	return nil
+}
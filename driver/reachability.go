@@ -0,0 +1,73 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// reachabilityDialTimeout limits a single connection attempt of the reachability check
+const reachabilityDialTimeout = 5 * time.Second
+
+// waitForReachableSSH waits for up to --hetzner-reachability-timeout seconds until the SSH port of the new server
+// accepts TCP connections; if it does not, the error tells a server that never booted from one that is unreachable
+func (d *Driver) waitForReachableSSH(srv *hcloud.Server) error {
+	addr := net.JoinHostPort(d.IPAddress, strconv.Itoa(d.SSHPort))
+	log.Infof(" -> Checking reachability of %v ...", addr)
+
+	deadline := time.Now().Add(time.Duration(d.reachabilityTimeout) * time.Second)
+	for {
+		err := probeTCP(addr)
+		if err == nil {
+			return nil
+		}
+		if err := d.interrupted(); err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			current, _, getErr := d.getClient().Server.GetByID(context.Background(), srv.ID)
+			if getErr != nil || current == nil {
+				current = srv
+			}
+			return reachabilityError(current, d.SSHPort, err)
+		}
+
+		log.Debugf(" -> %v not reachable yet: %v", addr, err)
+		time.Sleep(time.Duration(max(d.WaitOnPolling, 1)) * time.Second)
+	}
+}
+
+// probeTCP reports whether a TCP connection to addr can be established
+func probeTCP(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, reachabilityDialTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// reachabilityError explains why the SSH port of the server could not be reached, based on its current state and the
+// error of the last connection attempt
+func reachabilityError(srv *hcloud.Server, port int, err error) error {
+	if srv.Status != hcloud.ServerStatusRunning {
+		return fmt.Errorf("server %v[%d] never booted, its status is %v: %w", srv.Name, srv.ID, srv.Status, err)
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("server %v[%d] is running, but nothing accepts connections on port %d: %w", srv.Name, srv.ID, port, err)
+	}
+	if len(srv.PublicNet.Firewalls) > 0 {
+		ids := make([]int64, 0, len(srv.PublicNet.Firewalls))
+		for _, fw := range srv.PublicNet.Firewalls {
+			ids = append(ids, fw.Firewall.ID)
+		}
+		return fmt.Errorf("server %v[%d] is running, but port %d is unreachable, check whether firewalls %v allow it: %w", srv.Name, srv.ID, port, ids, err)
+	}
+	return fmt.Errorf("server %v[%d] is running, but port %d is unreachable, check whether a firewall on your side blocks it: %w", srv.Name, srv.ID, port, err)
+}
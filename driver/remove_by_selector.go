@@ -0,0 +1,112 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// RemoveBySelector removes all servers matching the label selector along with the SSH keys and placement groups the
+// driver created for them, e.g. after the local machine store was lost; with dryRun, the affected resources are only
+// printed to w
+func (d *Driver) RemoveBySelector(w io.Writer, selector string, dryRun bool, concurrency int) error {
+	if selector == "" {
+		return errors.New("a label selector is required")
+	}
+
+	servers, err := d.getClient().Server.AllWithOpts(context.Background(), hcloud.ServerListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: selector},
+	})
+	if err != nil {
+		return fmt.Errorf("could not list servers labeled %v: %w", selector, err)
+	}
+	keys, err := d.getClient().SSHKey.All(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not list ssh keys: %w", err)
+	}
+
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, max(concurrency, 1))
+	)
+	report := func(format string, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = fmt.Fprintf(w, format+"\n", args...)
+	}
+
+	for _, srv := range servers {
+		if err := d.checkRemovalGuard(srv); err != nil {
+			report("skipping server %v[%d]: %v", srv.Name, srv.ID, err)
+			continue
+		}
+		if err := d.checkDeleteProtection(srv); err != nil {
+			report("skipping server %v[%d]: %v", srv.Name, srv.ID, err)
+			continue
+		}
+		srvKeys := d.machineKeys(srv.Name, keys)
+		if dryRun {
+			report("would remove server %v[%d] and %d ssh key(s)", srv.Name, srv.ID, len(srvKeys))
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(srv *hcloud.Server) {
+			defer func() { <-sem; wg.Done() }()
+
+			if err := d.removeServerAndKeys(srv, srvKeys); err != nil {
+				report("could not remove server %v[%d]: %v", srv.Name, srv.ID, err)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("server %v[%d]: %w", srv.Name, srv.ID, err))
+				mu.Unlock()
+				return
+			}
+			report("removed server %v[%d] and %d ssh key(s)", srv.Name, srv.ID, len(srvKeys))
+		}(srv)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// machineKeys returns the keys the driver created for the machine of the given name, see isMachineKey
+func (d *Driver) machineKeys(name string, keys []*hcloud.SSHKey) []*hcloud.SSHKey {
+	var ret []*hcloud.SSHKey
+	for _, key := range keys {
+		if d.isMachineKey(name, name, key) {
+			ret = append(ret, key)
+		}
+	}
+	return ret
+}
+
+func (d *Driver) removeServerAndKeys(srv *hcloud.Server, keys []*hcloud.SSHKey) error {
+	// operate on a copy, so concurrent removals do not share the server handle
+	sd := *d
+	sd.ServerID = srv.ID
+	sd.cachedServer = srv
+	if err := sd.disableProtection(srv); err != nil {
+		return err
+	}
+	if err := sd.destroyServer(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		err := d.retry("ssh key deletion", func() error {
+			_, err := d.getClient().SSHKey.Delete(context.Background(), key)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("could not delete ssh key %v[%d]: %w", key.Name, key.ID, err)
+		}
+	}
+	return nil
+}
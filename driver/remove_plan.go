@@ -0,0 +1,155 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// RemovePlan prints every resource removing the machine would delete, or keep, to w without changing anything, so the
+// impact of `docker-machine rm` on a shared project can be verified beforehand
+func (d *Driver) RemovePlan(w io.Writer) error {
+	report := func(format string, args ...interface{}) {
+		_, _ = fmt.Fprintf(w, format+"\n", args...)
+	}
+
+	if d.Robot {
+		report("would disable the rescue system of dedicated server %d", d.RobotServerNumber)
+		if d.RobotKeyCreated && d.RobotKeyFingerprint != "" {
+			report("would delete robot key %v", d.RobotKeyFingerprint)
+		}
+		report("would keep dedicated server %d running, as it is not cancelled", d.RobotServerNumber)
+		return nil
+	}
+
+	if proceeds, err := d.planServerRemoval(report); err != nil || !proceeds {
+		return err
+	}
+
+	for _, id := range d.AdditionalKeyIDs {
+		key, _, err := d.getClient().SSHKey.GetByID(context.Background(), id)
+		if err != nil {
+			return fmt.Errorf("could not get additional key %d: %w", id, err)
+		}
+		if key == nil {
+			report("additional ssh key [%d] does not exist anymore", id)
+			continue
+		}
+		report("would delete additional ssh key %v[%d]%v", key.Name, key.ID, formatLabels(key.Labels))
+	}
+
+	if d.KeyID != 0 {
+		key, err := d.getKeyNullable()
+		if err != nil {
+			return fmt.Errorf("could not get ssh key: %w", err)
+		}
+		if key == nil {
+			report("ssh key [%d] does not exist anymore", d.KeyID)
+		} else if d.ownsKey() {
+			report("would delete ssh key %v[%d]%v", key.Name, key.ID, formatLabels(key.Labels))
+		} else {
+			report("would keep ssh key %v[%d], as it was not created for the machine", key.Name, key.ID)
+		}
+	}
+	return nil
+}
+
+// planServerRemoval reports the removal of the server and its resources, and whether removal would proceed afterwards
+func (d *Driver) planServerRemoval(report func(format string, args ...interface{})) (bool, error) {
+	if d.ServerID == 0 {
+		report("no server was created for the machine")
+		return true, nil
+	}
+
+	srv, err := d.getServerHandleNullable()
+	if err != nil {
+		return false, fmt.Errorf("could not get server handle: %w", err)
+	}
+	if srv == nil {
+		report("server [%d] does not exist anymore", d.ServerID)
+		return true, nil
+	}
+	if err = d.checkRemovalGuard(srv); err != nil {
+		report("would refuse removal: %v", err)
+		return false, nil
+	}
+	if err = d.checkDeleteProtection(srv); err != nil {
+		report("would refuse removal: %v", err)
+		return false, nil
+	} else if srv.Protection.Delete || srv.Protection.Rebuild {
+		report("would disable the protection of server %v[%d]", srv.Name, srv.ID)
+	}
+	for _, id := range d.LoadBalancerIDs {
+		report("would deregister the server from load balancer [%d]", id)
+	}
+	report("would delete server %v[%d]%v", srv.Name, srv.ID, formatLabels(srv.Labels))
+
+	for _, id := range []int64{srv.PublicNet.IPv4.ID, srv.PublicNet.IPv6.ID} {
+		if id == 0 {
+			continue
+		}
+		ip, _, err := d.getClient().PrimaryIP.GetByID(context.Background(), id)
+		if err != nil {
+			return false, fmt.Errorf("could not get primary IP %d: %w", id, err)
+		}
+		if ip == nil {
+			continue
+		}
+		if ip.AutoDelete {
+			report("would delete primary IP %v[%d]%v along with the server", ip.IP, ip.ID, formatLabels(ip.Labels))
+		} else {
+			report("would keep primary IP %v[%d], as auto delete is disabled", ip.IP, ip.ID)
+		}
+	}
+
+	if d.FloatingIPID != 0 && d.FloatingIPCreated {
+		report("would delete floating IP %v[%d]", d.IPAddress, d.FloatingIPID)
+	} else if d.FloatingIPID != 0 {
+		report("would unassign, but keep, floating IP %v[%d]", d.IPAddress, d.FloatingIPID)
+	}
+
+	for _, v := range srv.Volumes {
+		name := ""
+		if volume, _, err := d.getClient().Volume.GetByID(context.Background(), v.ID); err == nil && volume != nil {
+			name = volume.Name
+		}
+		report("would detach, but keep, volume %v[%d]", name, v.ID)
+	}
+
+	if d.AutoLoadBalancer != "" {
+		lb, err := d.removableAutoLoadBalancer()
+		if err != nil {
+			return false, err
+		}
+		if lb != nil {
+			report("would delete load balancer %v[%d]%v", lb.Name, lb.ID, formatLabels(lb.Labels))
+		} else {
+			report("would keep load balancer %v, as it was not created by the driver or targets other servers", d.AutoLoadBalancer)
+		}
+	}
+
+	if pg := srv.PlacementGroup; pg != nil {
+		if d.isRemovablePlacementGroup(pg) {
+			report("would delete placement group %v[%d]%v", pg.Name, pg.ID, formatLabels(pg.Labels))
+		} else {
+			report("would keep placement group %v[%d], as it was not created by the driver or holds other servers", pg.Name, pg.ID)
+		}
+	}
+	return true, nil
+}
+
+// formatLabels returns the labels sorted by key for output after a resource, or an empty string if there are none
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return " (" + strings.Join(pairs, ", ") + ")"
+}
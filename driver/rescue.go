@@ -0,0 +1,122 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// setBootRescueFlag validates --hetzner-boot-rescue; the rescue system is only reachable as root on the default SSH
+// port, and needs the server to be started
+func (d *Driver) setBootRescueFlag(bootRescue bool) error {
+	d.BootRescue = bootRescue
+	if !bootRescue {
+		return nil
+	}
+	if d.startPoweredOff {
+		return d.flagFailure("--%v and --%v are mutually exclusive", flagBootRescue, flagNoStartAfterCreate)
+	}
+	if (d.SSHUser != "" && d.SSHUser != defaultSSHUser) || (d.SSHPort != 0 && d.SSHPort != defaultSSHPort) {
+		return d.flagFailure("--%v requires --%v %v and --%v %d", flagBootRescue, flagSshUser, defaultSSHUser,
+			flagSshPort, defaultSSHPort)
+	}
+	return nil
+}
+
+// EnableRescue enables the rescue system of the server, authorizing the machine's keys, and resets the server into
+// it, e.g. to troubleshoot a server which fails to boot; [Driver.DisableRescue] or [Driver.RecoverBoot] revert it
+func (d *Driver) EnableRescue() (err error) {
+	defer d.operation("EnableRescue")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.Robot {
+		return errors.New("rescue mode is managed by the driver for robot servers")
+	}
+
+	srv, err := d.getServerHandle()
+	if err != nil {
+		return fmt.Errorf("could not get server handle: %w", err)
+	}
+	if err = d.enableRescue(srv); err != nil {
+		return err
+	}
+	return d.restartServer(srv)
+}
+
+// DisableRescue disables the rescue system of the server and resets it, so it boots from its disk again
+func (d *Driver) DisableRescue() (err error) {
+	defer d.operation("DisableRescue")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if d.Robot {
+		return errors.New("rescue mode is managed by the driver for robot servers")
+	}
+
+	srv, err := d.getServerHandle()
+	if err != nil {
+		return fmt.Errorf("could not get server handle: %w", err)
+	}
+	if !srv.RescueEnabled {
+		log.Infof("Rescue system of server %v[%d] is not enabled", srv.Name, srv.ID)
+		return nil
+	}
+
+	log.Infof("Disabling rescue system of server %v[%d] ...", srv.Name, srv.ID)
+	act, _, err := d.getClient().Server.DisableRescue(context.Background(), srv)
+	if err != nil {
+		return fmt.Errorf("could not disable rescue system: %w", err)
+	}
+	if err = d.waitForAction(act); err != nil {
+		return fmt.Errorf("could not wait for rescue system to be disabled: %w", err)
+	}
+	return d.restartServer(srv)
+}
+
+// bootIntoRescue resets the newly created server into the rescue system if --hetzner-boot-rescue is given, so the
+// machine is provisioned there instead of on the installed image
+func (d *Driver) bootIntoRescue(srv *hcloud.Server) error {
+	if !d.BootRescue {
+		return nil
+	}
+	if err := d.enableRescue(srv); err != nil {
+		return err
+	}
+	return d.restartServer(srv)
+}
+
+// enableRescue enables the linux64 rescue system with the machine's key and additional keys authorized
+func (d *Driver) enableRescue(srv *hcloud.Server) error {
+	var keys []*hcloud.SSHKey
+	if d.KeyID != 0 {
+		keys = append(keys, &hcloud.SSHKey{ID: d.KeyID})
+	}
+	for _, id := range d.AdditionalKeyIDs {
+		keys = append(keys, &hcloud.SSHKey{ID: id})
+	}
+
+	log.Infof("Enabling rescue system of server %v[%d] ...", srv.Name, srv.ID)
+	res, _, err := d.getClient().Server.EnableRescue(context.Background(), srv, hcloud.ServerEnableRescueOpts{
+		Type:    hcloud.ServerRescueTypeLinux64,
+		SSHKeys: keys,
+	})
+	if err != nil {
+		return fmt.Errorf("could not enable rescue system: %w", err)
+	}
+	if err = d.waitForAction(res.Action); err != nil {
+		return fmt.Errorf("could not wait for rescue system to be enabled: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// numericID parses a reference to a resource consisting of digits only; like hcloud-go's Get functions, the driver
+// always treats such references as IDs, even if a resource with that name exists
+func numericID(idOrName string) (int64, bool) {
+	id, err := strconv.ParseInt(idOrName, 10, 64)
+	return id, err == nil
+}
+
+// notFoundError reports that the resource of the given kind referenced by ID or name does not exist, pointing out that
+// numeric references are looked up as IDs
+func notFoundError(kind, idOrName string) error {
+	if _, ok := numericID(idOrName); ok {
+		return fmt.Errorf("%v with ID %v not found; numeric references are always treated as IDs", kind, idOrName)
+	}
+	return fmt.Errorf("%v '%s' not found", kind, idOrName)
+}
@@ -0,0 +1,117 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// Resync re-reads the live server and updates the driver configuration to match it, so that externally modified
+// machines do not confuse later operations; differences are printed to w, and the return value tells whether there
+// were any
+func (d *Driver) Resync(w io.Writer) (changed bool, err error) {
+	defer d.operation("Resync")(&err)
+
+	if d.Robot {
+		return false, errors.New("resync is not supported for robot servers")
+	}
+
+	srv, err := d.getServerHandle()
+	if err != nil {
+		return false, err
+	}
+
+	report := func(field string, old, new interface{}) {
+		_, _ = fmt.Fprintf(w, "%v: %v -> %v\n", field, old, new)
+		changed = true
+	}
+
+	if srv.ServerType != nil && srv.ServerType.Name != d.Type {
+		report("type", d.Type, srv.ServerType.Name)
+		d.Type = srv.ServerType.Name
+	}
+
+	if !maps.Equal(d.ServerLabels, srv.Labels) {
+		report("labels", d.ServerLabels, srv.Labels)
+		d.ServerLabels = maps.Clone(srv.Labels)
+	}
+
+	networkIDs := make([]int64, 0, len(srv.PrivateNet))
+	for _, privateNet := range srv.PrivateNet {
+		networkIDs = append(networkIDs, privateNet.Network.ID)
+	}
+	networks, err := resyncNames(d.Networks, networkIDs, func(id string) (string, error) {
+		network, _, err := d.getClient().Network.Get(context.Background(), id)
+		if err != nil || network == nil {
+			return "", err
+		}
+		return network.Name, nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("could not resolve networks: %w", err)
+	}
+	if networks != nil {
+		report("networks", d.Networks, networks)
+		d.Networks = networks
+	}
+
+	firewallIDs := make([]int64, 0, len(srv.PublicNet.Firewalls))
+	for _, status := range srv.PublicNet.Firewalls {
+		firewallIDs = append(firewallIDs, status.Firewall.ID)
+	}
+	firewalls, err := resyncNames(d.Firewalls, firewallIDs, func(id string) (string, error) {
+		firewall, _, err := d.getClient().Firewall.Get(context.Background(), id)
+		if err != nil || firewall == nil {
+			return "", err
+		}
+		return firewall.Name, nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("could not resolve firewalls: %w", err)
+	}
+	if firewalls != nil {
+		report("firewalls", d.Firewalls, firewalls)
+		d.Firewalls = firewalls
+	}
+
+	if ip := d.liveIPAddress(srv); ip != "" && ip != d.IPAddress {
+		report("ip", d.IPAddress, ip)
+		d.IPAddress = ip
+	}
+
+	return changed, nil
+}
+
+// resyncNames compares the configured networks or firewalls, given by ID or name, to the IDs of the ones attached to
+// the server, returning the names of the attached ones if they differ, or nil if they match; resolve looks up the name
+// of a resource by ID or name, returning an empty string if it does not exist
+func resyncNames(configured []string, attached []int64, resolve func(string) (string, error)) ([]string, error) {
+	live := make([]string, 0, len(attached))
+	for _, id := range attached {
+		name, err := resolve(strconv.FormatInt(id, 10))
+		if err != nil {
+			return nil, err
+		}
+		live = append(live, name)
+	}
+
+	current := make([]string, 0, len(configured))
+	for _, idOrName := range configured {
+		name, err := resolve(idOrName)
+		if err != nil {
+			return nil, err
+		}
+		current = append(current, name)
+	}
+
+	slices.Sort(live)
+	slices.Sort(current)
+	if slices.Equal(live, current) {
+		return nil, nil
+	}
+	return live, nil
+}
@@ -0,0 +1,76 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const retryAttempts = 3
+
+// retryableErrorCodes are API and action error codes denoting transient failures; all others are terminal
+var retryableErrorCodes = map[string]bool{
+	string(hcloud.ErrorCodeServiceError):        true,
+	string(hcloud.ErrorCodeRateLimitExceeded):   true,
+	string(hcloud.ErrorCodeUnknownError):        true,
+	string(hcloud.ErrorCodeLocked):              true,
+	string(hcloud.ErrorCodeResourceUnavailable): true,
+	string(hcloud.ErrorCodeMaintenance):         true,
+	string(hcloud.ErrorCodeConflict):            true,
+	string(hcloud.ErrorCodeRobotUnavailable):    true,
+	"server_error":                              true,
+	"timeout":                                   true,
+}
+
+// errorCode returns the code of an API or action error, or an empty string for other errors
+func errorCode(err error) string {
+	var apiErr hcloud.Error
+	if errors.As(err, &apiErr) {
+		return string(apiErr.Code)
+	}
+
+	var actionErr hcloud.ActionError
+	if errors.As(err, &actionErr) {
+		return actionErr.Code
+	}
+	return ""
+}
+
+// isRetryableError classifies API, action and transport errors as transient (retryable) or terminal
+func isRetryableError(err error) bool {
+	if code := errorCode(err); code != "" {
+		return retryableErrorCodes[code]
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retry runs fn until it succeeds, fails with a terminal error or the attempts are exhausted, with exponential backoff
+// starting at the polling interval
+func (d *Driver) retry(what string, fn func() error) error {
+	return d.retryClassified(what, isRetryableError, fn)
+}
+
+// retryClassified is retry with the classification of transient errors given by retryable
+func (d *Driver) retryClassified(what string, retryable func(error) bool, fn func() error) error {
+	delay := time.Duration(max(d.WaitOnPolling, 1)) * time.Second
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !retryable(err) {
+			return err
+		}
+		if attempt == retryAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		log.Warnf("%v failed with transient error, retrying in %v: %v", what, delay, err)
+		d.recordRetry()
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
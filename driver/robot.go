@@ -0,0 +1,230 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	robotStatusReady     = "ready"
+	robotStatusInProcess = "in process"
+
+	robotInstallImage = "/root/.oldroot/nfs/install/installimage"
+	robotImageDir     = "/root/.oldroot/nfs/images/"
+
+	defaultSSHWaitTimeout = 15 * time.Minute
+)
+
+func (d *Driver) preCreateCheckRobot() error {
+	srv, err := d.getRobotClient().getServer(d.RobotServerNumber)
+	if err != nil {
+		return fmt.Errorf("could not get robot server %d: %w", d.RobotServerNumber, err)
+	}
+	if srv.Cancelled {
+		return fmt.Errorf("robot server %d is cancelled", d.RobotServerNumber)
+	}
+
+	log.Infof("Using dedicated server %v[%d] (%v in %v)", srv.ServerName, srv.ServerNumber, srv.Product, srv.Datacenter)
+	return nil
+}
+
+// createRobot provisions a dedicated server by booting it into the rescue system with the machine key authorized and
+// optionally installing an operating system image using installimage
+func (d *Driver) createRobot() error {
+	if err := d.prepareLocalKey(); err != nil {
+		return err
+	}
+
+	client := d.getRobotClient()
+	srv, err := client.getServer(d.RobotServerNumber)
+	if err != nil {
+		return fmt.Errorf("could not get robot server %d: %w", d.RobotServerNumber, err)
+	}
+	d.IPAddress = srv.ServerIP
+
+	if err = d.createRobotKey(); err != nil {
+		return err
+	}
+
+	log.Infof(" -> Enabling rescue system on %v[%d]...", srv.ServerName, srv.ServerNumber)
+	if _, err = client.enableRescue(srv.ServerNumber, d.RobotKeyFingerprint); err != nil {
+		return fmt.Errorf("could not enable rescue system: %w", err)
+	}
+
+	log.Infof(" -> Resetting %v[%d] into rescue system...", srv.ServerName, srv.ServerNumber)
+	if err = client.reset(srv.ServerNumber, "hw"); err != nil {
+		return fmt.Errorf("could not reset server: %w", err)
+	}
+
+	if err = d.waitForSSH(); err != nil {
+		return err
+	}
+
+	if d.RobotImage != "" {
+		if err = d.installRobotImage(); err != nil {
+			return err
+		}
+	}
+
+	log.Infof(" -> Dedicated server %v[%d] ready. Ip %s", srv.ServerName, srv.ServerNumber, d.IPAddress)
+	return nil
+}
+
+func (d *Driver) createRobotKey() error {
+	buf, err := d.getLocalPublicKey()
+	if err != nil {
+		return err
+	}
+
+	pubk, _, _, _, err := ssh.ParseAuthorizedKey(buf)
+	if err != nil {
+		return fmt.Errorf("could not parse ssh public key: %w", err)
+	}
+	fp := ssh.FingerprintLegacyMD5(pubk)
+
+	client := d.getRobotClient()
+	key, err := client.getKeyNullable(fp)
+	if err != nil {
+		return fmt.Errorf("error retrieving potentially existing robot key: %w", err)
+	}
+
+	if key == nil {
+		log.Infof("SSH key not found in Robot. Uploading...")
+		if key, err = client.createKey(d.GetMachineName(), strings.TrimSpace(string(buf))); err != nil {
+			return fmt.Errorf("could not create robot key: %w", err)
+		}
+		d.RobotKeyCreated = true
+	} else {
+		log.Debugf("SSH key found in Robot: %v", key.Name)
+	}
+
+	d.RobotKeyFingerprint = key.Fingerprint
+	return nil
+}
+
+func (d *Driver) installRobotImage() error {
+	cmd := fmt.Sprintf("%v -a -n %v -r no -i %v%v.tar.gz -t yes %v",
+		robotInstallImage, d.GetMachineName(), robotImageDir, d.RobotImage, d.RobotInstallArgs)
+
+	log.Infof(" -> Installing %v...", d.RobotImage)
+	if _, err := drivers.RunSSHCommandFromDriver(d, cmd); err != nil {
+		return fmt.Errorf("could not install image: %w", err)
+	}
+
+	log.Infof(" -> Rebooting into installed system...")
+	// the connection is expected to be dropped by the reboot
+	if _, err := drivers.RunSSHCommandFromDriver(d, "reboot"); err != nil {
+		log.Debugf("reboot command returned: %v", err)
+	}
+
+	// do not mistake the still running rescue system for the installed one
+	time.Sleep(time.Duration(d.WaitOnPolling)*time.Second + 30*time.Second)
+
+	return d.waitForSSH()
+}
+
+// waitForSSH polls until the server accepts SSH connections, e.g. after booting into the rescue system
+func (d *Driver) waitForSSH() error {
+	timeout := defaultSSHWaitTimeout
+	if d.WaitForRunningTimeout > 0 {
+		timeout = time.Duration(d.WaitForRunningTimeout) * time.Second
+	}
+
+	log.Infof(" -> Waiting for SSH on %v...", d.IPAddress)
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := drivers.RunSSHCommandFromDriver(d, "exit 0")
+		if err == nil {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("server did not become reachable via SSH within %v: %w", timeout, err)
+		}
+		time.Sleep(time.Duration(d.WaitOnPolling) * time.Second)
+	}
+}
+
+func (d *Driver) getStateRobot() (state.State, error) {
+	srv, err := d.getRobotClient().getServer(d.RobotServerNumber)
+	if err != nil {
+		return state.None, fmt.Errorf("could not get robot server: %w", err)
+	}
+
+	switch srv.Status {
+	case robotStatusReady:
+		return state.Running, nil
+	case robotStatusInProcess:
+		return state.Starting, nil
+	}
+	return state.None, nil
+}
+
+// removeRobot releases the resources created for a dedicated server; the server itself is not cancelled
+func (d *Driver) removeRobot() error {
+	client := d.getRobotClient()
+
+	var errs error
+	if err := client.disableRescue(d.RobotServerNumber); err != nil && !isRobotNotFound(err) {
+		errs = errors.Join(errs, fmt.Errorf("could not disable rescue system: %w", err))
+	}
+
+	if d.RobotKeyCreated && d.RobotKeyFingerprint != "" {
+		log.Infof(" -> Destroying robot key %v...", d.RobotKeyFingerprint)
+		if err := client.deleteKey(d.RobotKeyFingerprint); err != nil && !isRobotNotFound(err) {
+			errs = errors.Join(errs, fmt.Errorf("could not delete robot key: %w", err))
+		}
+	}
+
+	log.Warnf(" -> Dedicated server %d is not cancelled and keeps running its current system", d.RobotServerNumber)
+	return errs
+}
+
+// robotPowerReset returns the reset type switching the dedicated server on or off, or an empty string if it already is;
+// the power button toggles, so it is only pressed to switch the server on, while a long press switches it off
+func robotPowerReset(on, poweredOn bool) string {
+	switch {
+	case on && !poweredOn:
+		return "power"
+	case !on && poweredOn:
+		return "power_long"
+	}
+	return ""
+}
+
+// robotPoweredOn tells whether the dedicated server is powered on by whether its SSH port accepts connections, as the
+// Robot webservice does not report the power state
+func (d *Driver) robotPoweredOn() bool {
+	return d.IPAddress != "" && probeTCP(net.JoinHostPort(d.IPAddress, strconv.Itoa(d.SSHPort))) == nil
+}
+
+// powerRobot switches the dedicated server on or off, unless it already is
+func (d *Driver) powerRobot(on bool) error {
+	resetType := robotPowerReset(on, d.robotPoweredOn())
+	switch {
+	case resetType == "":
+		log.Infof(" -> Dedicated server %d already is in the requested power state", d.RobotServerNumber)
+		return nil
+	case on:
+		return d.resetRobot(resetType, "Powering on")
+	default:
+		return d.resetRobot(resetType, "Powering off")
+	}
+}
+
+func (d *Driver) resetRobot(resetType, description string) error {
+	log.Infof(" -> %v dedicated server %d...", description, d.RobotServerNumber)
+	if err := d.getRobotClient().reset(d.RobotServerNumber, resetType); err != nil {
+		return fmt.Errorf("could not reset server: %w", err)
+	}
+	return nil
+}
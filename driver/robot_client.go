@@ -0,0 +1,181 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	robotEndpoint       = "https://robot-ws.your-server.de"
+	robotRequestTimeout = 60 * time.Second
+)
+
+// robotClient is a minimal client for the Hetzner Robot webservice, covering what is needed to provision dedicated servers
+type robotClient struct {
+	endpoint string
+	user     string
+	password string
+	agent    string
+}
+
+type robotServer struct {
+	ServerIP     string `json:"server_ip"`
+	ServerNumber int64  `json:"server_number"`
+	ServerName   string `json:"server_name"`
+	Product      string `json:"product"`
+	Datacenter   string `json:"dc"`
+	Status       string `json:"status"`
+	Cancelled    bool   `json:"cancelled"`
+}
+
+type robotKey struct {
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+	Type        string `json:"type"`
+	Data        string `json:"data"`
+}
+
+type robotRescue struct {
+	ServerIP     string `json:"server_ip"`
+	ServerNumber int64  `json:"server_number"`
+	OS           string `json:"os"`
+	Active       bool   `json:"active"`
+}
+
+type robotError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e robotError) Error() string {
+	return fmt.Sprintf("robot API error %v (%d): %v", e.Code, e.Status, e.Message)
+}
+
+func (d *Driver) getRobotClient() *robotClient {
+	return &robotClient{
+		endpoint: robotEndpoint,
+		user:     d.RobotUser,
+		password: d.RobotPassword,
+		agent:    "docker-machine-driver/" + d.version,
+	}
+}
+
+func (c *robotClient) do(method, path string, form url.Values, target interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), robotRequestTimeout)
+	defer cancel()
+
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, body)
+	if err != nil {
+		return fmt.Errorf("could not create robot request: %w", err)
+	}
+	req.SetBasicAuth(c.user, c.password)
+	req.Header.Set("User-Agent", c.agent)
+	req.Header.Set("Accept", "application/json")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("robot request %v %v failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read robot response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		var wrapped struct {
+			Error robotError `json:"error"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err == nil && wrapped.Error.Code != "" {
+			return wrapped.Error
+		}
+		return fmt.Errorf("robot request %v %v failed: %v", method, path, resp.Status)
+	}
+
+	if target == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("could not decode robot response: %w", err)
+	}
+	return nil
+}
+
+func isRobotNotFound(err error) bool {
+	rerr, ok := err.(robotError)
+	return ok && rerr.Status == http.StatusNotFound
+}
+
+func (c *robotClient) getServer(number int64) (*robotServer, error) {
+	var res struct {
+		Server robotServer `json:"server"`
+	}
+	if err := c.do(http.MethodGet, fmt.Sprintf("/server/%d", number), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res.Server, nil
+}
+
+func (c *robotClient) getKeyNullable(fingerprint string) (*robotKey, error) {
+	var res struct {
+		Key robotKey `json:"key"`
+	}
+	err := c.do(http.MethodGet, "/key/"+fingerprint, nil, &res)
+	if isRobotNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &res.Key, nil
+}
+
+func (c *robotClient) createKey(name, data string) (*robotKey, error) {
+	var res struct {
+		Key robotKey `json:"key"`
+	}
+	if err := c.do(http.MethodPost, "/key", url.Values{"name": {name}, "data": {data}}, &res); err != nil {
+		return nil, err
+	}
+	return &res.Key, nil
+}
+
+func (c *robotClient) deleteKey(fingerprint string) error {
+	return c.do(http.MethodDelete, "/key/"+fingerprint, nil, nil)
+}
+
+func (c *robotClient) enableRescue(number int64, keyFingerprint string) (*robotRescue, error) {
+	var res struct {
+		Rescue robotRescue `json:"rescue"`
+	}
+	form := url.Values{"os": {"linux"}, "authorized_key[]": {keyFingerprint}}
+	if err := c.do(http.MethodPost, fmt.Sprintf("/boot/%d/rescue", number), form, &res); err != nil {
+		return nil, err
+	}
+	return &res.Rescue, nil
+}
+
+func (c *robotClient) disableRescue(number int64) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/boot/%d/rescue", number), nil, nil)
+}
+
+// reset triggers a reset of the given type: sw (ctrl-alt-del), hw (hardware reset), power (power button),
+// power_long (long power button press)
+func (c *robotClient) reset(number int64, resetType string) error {
+	return c.do(http.MethodPost, fmt.Sprintf("/reset/%d", number), url.Values{"type": {resetType}}, nil)
+}
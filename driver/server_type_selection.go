@@ -0,0 +1,137 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// serverTypeRequirements are the minimum resources of --hetzner-min-cores, --hetzner-min-memory and --hetzner-min-disk
+// the server type is chosen by, instead of --hetzner-server-type
+type serverTypeRequirements struct {
+	cores  int
+	memory int
+	disk   int
+}
+
+func (r serverTypeRequirements) empty() bool {
+	return r.cores == 0 && r.memory == 0 && r.disk == 0
+}
+
+func (r serverTypeRequirements) satisfiedBy(stype *hcloud.ServerType) bool {
+	return stype.Cores >= r.cores && stype.Memory >= float32(r.memory) && stype.Disk >= r.disk
+}
+
+func (r serverTypeRequirements) String() string {
+	return fmt.Sprintf("at least %d cores, %d GB memory and %d GB disk", r.cores, r.memory, r.disk)
+}
+
+// setServerTypeRequirementsFlags validates the minimum resources, which replace --hetzner-server-type; as its default
+// cannot be told apart from a given type, only other types are rejected
+func (d *Driver) setServerTypeRequirementsFlags(cores, memory, disk int) error {
+	d.typeRequirements = serverTypeRequirements{cores: cores, memory: memory, disk: disk}
+	if d.typeRequirements.empty() {
+		return nil
+	}
+	if cores < 0 || memory < 0 || disk < 0 {
+		return d.flagFailure("--%v, --%v and --%v must not be negative", flagMinCores, flagMinMemory, flagMinDisk)
+	}
+	if d.Type != "" && d.Type != defaultType {
+		return d.flagFailure("--%v and --%v are mutually exclusive", flagType, flagMinCores)
+	}
+	if d.locationAuto {
+		return d.flagFailure("--%v %v requires --%v, which is chosen by --%v", flagLocation, locationAuto, flagType, flagMinCores)
+	}
+	return nil
+}
+
+// selectServerType chooses the cheapest server type satisfying the minimum resources that is available in the chosen
+// location, or in any location if none is given; types are filtered by --hetzner-image-arch, defaulting to x86
+func (d *Driver) selectServerType() error {
+	if d.typeRequirements.empty() {
+		return nil
+	}
+
+	types, err := d.getClient().ServerType.All(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not list server types: %w", err)
+	}
+	pricing, _, err := d.getClient().Pricing.Get(context.Background())
+	if err != nil {
+		return fmt.Errorf("could not get pricing: %w", err)
+	}
+
+	var available map[int64]bool
+	if d.Location != "" {
+		datacenters, err := d.getClient().Datacenter.All(context.Background())
+		if err != nil {
+			return fmt.Errorf("could not list datacenters: %w", err)
+		}
+		available = make(map[int64]bool)
+		for _, dc := range datacenters {
+			if dc.Location == nil || dc.Location.Name != d.Location {
+				continue
+			}
+			for _, stype := range dc.ServerTypes.Available {
+				available[stype.ID] = true
+			}
+		}
+	}
+
+	arch := d.ImageArch
+	if arch == emptyImageArchitecture {
+		arch = hcloud.ArchitectureX86
+	}
+	stype, price := cheapestServerType(types, pricing, available, d.Location, arch, d.typeRequirements)
+	if stype == nil {
+		where := ""
+		if d.Location != "" {
+			where = " in " + d.Location
+		}
+		return fmt.Errorf("no %v server type with %v is available%v", arch, d.typeRequirements, where)
+	}
+
+	log.Infof(" -> Chose server type %v (%d cores, %v GB memory, %d GB disk) for %v %v per month",
+		stype.Name, stype.Cores, stype.Memory, stype.Disk, strconv.FormatFloat(price, 'f', 2, 64),
+		pricing.Image.PerGBMonth.Currency)
+	d.Type = stype.Name
+	d.cachedType = stype
+	return nil
+}
+
+// cheapestServerType returns the server type satisfying the requirements with the lowest monthly gross price in the
+// location, or in any location if it is empty, along with the price; deprecated types, types of other architectures
+// and, unless available is nil, types not available are skipped
+func cheapestServerType(types []*hcloud.ServerType, pricing hcloud.Pricing, available map[int64]bool, location string,
+	arch hcloud.Architecture, requirements serverTypeRequirements) (*hcloud.ServerType, float64) {
+	prices := make(map[string][]hcloud.ServerTypeLocationPricing, len(pricing.ServerTypes))
+	for _, typePricing := range pricing.ServerTypes {
+		if typePricing.ServerType != nil {
+			prices[typePricing.ServerType.Name] = typePricing.Pricings
+		}
+	}
+
+	var best *hcloud.ServerType
+	bestPrice := -1.0
+	for _, stype := range types {
+		if stype.IsDeprecated() || stype.Architecture != arch || !requirements.satisfiedBy(stype) {
+			continue
+		}
+		if available != nil && !available[stype.ID] {
+			continue
+		}
+
+		price := monthlyPrice(&hcloud.ServerType{Pricings: prices[stype.Name]}, location)
+		gross, err := strconv.ParseFloat(price, 64)
+		if err != nil {
+			continue // no price in the location
+		}
+		if best == nil || gross < bestPrice || (gross == bestPrice && stype.Name < best.Name) {
+			best, bestPrice = stype, gross
+		}
+	}
+	return best, bestPrice
+}
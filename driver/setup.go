@@ -2,10 +2,11 @@ package driver
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
 	"time"
 
+	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
@@ -22,6 +23,10 @@ func (d *Driver) waitForRunningServer() error {
 			break
 		}
 
+		if err = d.interrupted(); err != nil {
+			return err
+		}
+
 		elapsed_time := time.Since(start_time).Seconds()
 		if d.WaitForRunningTimeout > 0 && int(elapsed_time) > d.WaitForRunningTimeout {
 			return fmt.Errorf("server exceeded wait-for-running-timeout")
@@ -34,21 +39,64 @@ func (d *Driver) waitForRunningServer() error {
 
 func (d *Driver) waitForInitialStartup(srv hcloud.ServerCreateResult) error {
 	if srv.NextActions != nil && len(srv.NextActions) != 0 {
-		if err := d.waitForMultipleActions("server.NextActions", srv.NextActions); err != nil {
-			return fmt.Errorf("could not wait for NextActions: %w", err)
+		err := d.waitForMultipleActions("server.NextActions", srv.NextActions)
+		if err != nil {
+			err = d.retryNetworkAttachments(srv.Server, srv.NextActions, err)
+		}
+		if err != nil {
+			return d.rollbackServer(srv.Server, err)
 		}
 	}
 
+	if d.hasNetworkIPs() {
+		if err := d.attachStaticIPNetworks(srv.Server); err != nil {
+			return d.rollbackServer(srv.Server, err)
+		}
+	}
+
+	if d.startPoweredOff {
+		log.Infof(" -> Server %s[%d] was created powered off", srv.Server.Name, srv.Server.ID)
+		return nil
+	}
+	if d.hasNetworkIPs() {
+		act, _, err := d.getClient().Server.Poweron(context.Background(), srv.Server)
+		if err != nil {
+			return fmt.Errorf("could not power on server: %w", err)
+		}
+		if err = d.waitForAction(act); err != nil {
+			return fmt.Errorf("could not wait for power on: %w", err)
+		}
+	}
+	if d.phoneHome != nil && d.waitForPhoneHome() {
+		return nil
+	}
 	return d.waitForRunningServer()
 }
 
+// rollbackServer destroys the server whose setup failed with err, as it is unusable
+func (d *Driver) rollbackServer(srv *hcloud.Server, err error) error {
+	defer d.cleanup()()
+
+	err = fmt.Errorf("could not set up server: %w", err)
+	log.Infof(" -> Rolling back server %s[%d] ...", srv.Name, srv.ID)
+	if rbErr := d.destroyServer(); rbErr != nil {
+		return errors.Join(err, fmt.Errorf("could not roll back server: %w", rbErr))
+	}
+	d.ServerID = 0
+	return err
+}
+
 func (d *Driver) makeCreateServerOptions() (*hcloud.ServerCreateOpts, error) {
 	pgrp, err := d.getPlacementGroup()
 	if err != nil {
 		return nil, err
 	}
 
-	userData, err := d.getUserData()
+	parts, err := d.userDataParts()
+	if err != nil {
+		return nil, err
+	}
+	userData, err := d.assembleUserData(parts)
 	if err != nil {
 		return nil, err
 	}
@@ -59,6 +107,9 @@ func (d *Driver) makeCreateServerOptions() (*hcloud.ServerCreateOpts, error) {
 		Labels:         d.ServerLabels,
 		PlacementGroup: pgrp,
 	}
+	if d.startPoweredOff {
+		srvopts.StartAfterCreate = hcloud.Ptr(false)
+	}
 
 	err = d.setPublicNetIfRequired(&srvopts)
 	if err != nil {
@@ -83,6 +134,11 @@ func (d *Driver) makeCreateServerOptions() (*hcloud.ServerCreateOpts, error) {
 	}
 	srvopts.Volumes = volumes
 
+	if d.hasNetworkIPs() {
+		// attach the networks with static IPs before the first boot, so the server starts with all its addresses
+		srvopts.StartAfterCreate = hcloud.Ptr(false)
+	}
+
 	if srvopts.Location, err = d.getLocationNullable(); err != nil {
 		return nil, fmt.Errorf("could not get location: %w", err)
 	}
@@ -100,28 +156,18 @@ func (d *Driver) makeCreateServerOptions() (*hcloud.ServerCreateOpts, error) {
 	return &srvopts, nil
 }
 
-func (d *Driver) getUserData() (string, error) {
-	file := d.userDataFile
-	if file == "" {
-		return d.userData, nil
-	}
-
-	readUserData, err := os.ReadFile(file)
-	if err != nil {
-		return "", err
-	}
-	return string(readUserData), nil
-}
-
 func (d *Driver) createNetworks() ([]*hcloud.Network, error) {
 	networks := []*hcloud.Network{}
 	for _, networkIDorName := range d.Networks {
+		if d.networkIP(networkIDorName) != "" {
+			continue // attached after creation, see attachStaticIPNetworks
+		}
 		network, _, err := d.getClient().Network.Get(context.Background(), networkIDorName)
 		if err != nil {
 			return nil, fmt.Errorf("could not get network by ID or name: %w", err)
 		}
 		if network == nil {
-			return nil, fmt.Errorf("network '%s' not found", networkIDorName)
+			return nil, notFoundError("network", networkIDorName)
 		}
 		networks = append(networks, network)
 	}
@@ -136,7 +182,20 @@ func (d *Driver) createFirewalls() ([]*hcloud.ServerCreateFirewall, error) {
 			return nil, fmt.Errorf("could not get firewall by ID or name: %w", err)
 		}
 		if firewall == nil {
-			return nil, fmt.Errorf("firewall '%s' not found", firewallIDorName)
+			return nil, notFoundError("firewall", firewallIDorName)
+		}
+		if d.FirewallLabel != "" {
+			if err = d.applyFirewallsByLabel(firewall); err != nil {
+				return nil, err
+			}
+			continue // applied to the server via its label, see --hetzner-firewall-label
+		}
+		firewalls = append(firewalls, &hcloud.ServerCreateFirewall{Firewall: *firewall})
+	}
+	if d.FirewallPreset != "" {
+		firewall, err := d.presetFirewall()
+		if err != nil {
+			return nil, err
 		}
 		firewalls = append(firewalls, &hcloud.ServerCreateFirewall{Firewall: *firewall})
 	}
@@ -151,7 +210,7 @@ func (d *Driver) createVolumes() ([]*hcloud.Volume, error) {
 			return nil, fmt.Errorf("could not get volume by ID or name: %w", err)
 		}
 		if volume == nil {
-			return nil, fmt.Errorf("volume '%s' not found", volumeIDorName)
+			return nil, notFoundError("volume", volumeIDorName)
 		}
 		volumes = append(volumes, volume)
 	}
@@ -0,0 +1,88 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errShutdown is returned by operations interrupted by [Driver.Shutdown]
+var errShutdown = errors.New("driver is shutting down")
+
+// shutdownState tracks the in-flight operations of a driver, which are interrupted once the plugin process shuts down
+type shutdownState struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	inFlight sync.WaitGroup
+}
+
+func newShutdownState() *shutdownState {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &shutdownState{ctx: ctx, cancel: cancel}
+}
+
+// Shutdown interrupts the in-flight operations of the driver, e.g. when the plugin process receives SIGTERM, and waits
+// for at most timeout until they rolled back the resources they created so far; it reports whether all of them
+// finished in time
+func (d *Driver) Shutdown(timeout time.Duration) bool {
+	if d.shutdown == nil {
+		return true
+	}
+	d.shutdown.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		d.shutdown.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// beginOperation registers an in-flight operation with the shutdown state; the returned function unregisters it
+func (d *Driver) beginOperation() func() {
+	if d.shutdown == nil {
+		return func() {}
+	}
+	d.shutdown.inFlight.Add(1)
+	return d.shutdown.inFlight.Done
+}
+
+// interrupted returns errShutdown if the driver is shutting down; while cleaning up, waits are not interrupted, so the
+// cleanup can complete
+func (d *Driver) interrupted() error {
+	if d.cleaning || d.shutdown == nil || d.shutdown.ctx.Err() == nil {
+		return nil
+	}
+	return errShutdown
+}
+
+// shutdownContext returns the context waits of the driver derive from, which is cancelled on shutdown unless the driver
+// is cleaning up
+func (d *Driver) shutdownContext() context.Context {
+	if d.cleaning || d.shutdown == nil {
+		return context.Background()
+	}
+	return d.shutdown.ctx
+}
+
+// shutdownSignal returns a channel closed on shutdown, or nil while cleaning up, which blocks forever in a select
+func (d *Driver) shutdownSignal() <-chan struct{} {
+	if d.cleaning || d.shutdown == nil {
+		return nil
+	}
+	return d.shutdown.ctx.Done()
+}
+
+// cleanup marks the driver as cleaning up until the returned function is called, see interrupted
+func (d *Driver) cleanup() func() {
+	previous := d.cleaning
+	d.cleaning = true
+	return func() { d.cleaning = previous }
+}
@@ -0,0 +1,70 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// GetSSHKeyPath returns the path of the machine's private key; when authenticating via ssh-agent, no key is stored
+// and an empty path is returned, which makes docker-machine fall back to the identities offered by the agent
+func (d *Driver) GetSSHKeyPath() string {
+	if d.SSHAgentKey != "" {
+		return ""
+	}
+	return d.BaseDriver.GetSSHKeyPath()
+}
+
+// getAgentPublicKey retrieves the public key matching the configured fingerprint from the local ssh-agent
+func (d *Driver) getAgentPublicKey() ([]byte, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("SSH_AUTH_SOCK is not set, is ssh-agent running?")
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to ssh-agent: %w", err)
+	}
+	defer conn.Close()
+
+	keys, err := agent.NewClient(conn).List()
+	if err != nil {
+		return nil, fmt.Errorf("could not list ssh-agent keys: %w", err)
+	}
+
+	for _, key := range keys {
+		if matchesFingerprint(key, d.SSHAgentKey) {
+			log.Debugf("Using ssh-agent key %v (%v)", d.SSHAgentKey, key.Comment)
+			return ssh.MarshalAuthorizedKey(key), nil
+		}
+	}
+
+	return nil, fmt.Errorf("ssh-agent does not hold a key with fingerprint %v", d.SSHAgentKey)
+}
+
+func matchesFingerprint(key ssh.PublicKey, fp string) bool {
+	if isSHA256Fingerprint(fp) {
+		return ssh.FingerprintSHA256(key) == fp
+	}
+	return ssh.FingerprintLegacyMD5(key) == strings.ToLower(strings.TrimPrefix(fp, md5FingerprintPrefix))
+}
+
+// getLocalPublicKey retrieves the public part of the key used for provisioning the machine
+func (d *Driver) getLocalPublicKey() ([]byte, error) {
+	if d.SSHAgentKey != "" {
+		return d.getAgentPublicKey()
+	}
+
+	buf, err := os.ReadFile(d.GetSSHKeyPath() + ".pub")
+	if err != nil {
+		return nil, fmt.Errorf("could not read ssh public key: %w", err)
+	}
+	return buf, nil
+}
@@ -0,0 +1,136 @@
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"golang.org/x/crypto/ssh"
+)
+
+const keySourceTimeout = 30 * time.Second
+
+// keySourceURLs maps the supported `<source>:<user>` prefixes to the URL template serving the user's public keys
+var keySourceURLs = map[string]string{
+	"github": "https://github.com/%s.keys",
+	"gitlab": "https://gitlab.com/%s.keys",
+}
+
+// splitKeySource returns the source and user name of an additional key value in `<source>:<user>` notation;
+// ok is false if the value is not such a reference (i.e. a literal public key)
+func splitKeySource(value string) (source, user string, ok bool) {
+	source, user, found := strings.Cut(value, ":")
+	if !found {
+		return "", "", false
+	}
+
+	if _, known := keySourceURLs[source]; !known || user == "" || strings.ContainsAny(user, " \t/") {
+		return "", "", false
+	}
+
+	return source, user, true
+}
+
+// resolveAdditionalKeys expands all additional key values into single public keys; values may be key source references,
+// paths to files containing public keys, or (possibly multiple, newline separated) public keys
+func (d *Driver) resolveAdditionalKeys() ([]string, error) {
+	var keys []string
+	for i, value := range d.AdditionalKeys {
+		var origin string
+		var data []byte
+
+		if source, user, ok := splitKeySource(value); ok {
+			fetched, err := fetchSourceKeys(source, user)
+			if err != nil {
+				return nil, fmt.Errorf("could not fetch keys for %v: %w", value, err)
+			}
+			origin = d.additionalKeyOrigin(i, value)
+			data = fetched
+		} else {
+			origin, data = d.readLocalAdditionalKey(i, value)
+		}
+
+		parsed, err := parseAuthorizedKeys(origin, data)
+		if err != nil {
+			return nil, err
+		}
+		if len(parsed) == 0 {
+			return nil, fmt.Errorf("%v: no public keys found", origin)
+		}
+
+		log.Debugf("%v: %d key(s)", origin, len(parsed))
+		keys = append(keys, parsed...)
+	}
+	return keys, nil
+}
+
+// readLocalAdditionalKey reads an additional key value that is no key source reference, i.e. either a file or literal
+// public keys
+func (d *Driver) readLocalAdditionalKey(i int, value string) (origin string, data []byte) {
+	if buf, err := os.ReadFile(value); err == nil {
+		return d.additionalKeyOrigin(i, "file "+value), buf
+	}
+	return d.additionalKeyOrigin(i, ""), []byte(value)
+}
+
+// additionalKeyOrigin describes the i-th additional key value for error messages
+func (d *Driver) additionalKeyOrigin(i int, detail string) string {
+	origin := fmt.Sprintf("additional key #%d of %d", i+1, len(d.AdditionalKeys))
+	if detail != "" {
+		origin += " (" + detail + ")"
+	}
+	return origin
+}
+
+// parseAuthorizedKeys validates each non-empty, non-comment line of data as an authorized_keys entry
+func parseAuthorizedKeys(origin string, data []byte) ([]string, error) {
+	var keys []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(entry)); err != nil {
+			return nil, fmt.Errorf("%v, line %d: invalid public key: %w", origin, line, err)
+		}
+		keys = append(keys, entry)
+	}
+	return keys, scanner.Err()
+}
+
+func fetchSourceKeys(source, user string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keySourceTimeout)
+	defer cancel()
+
+	target := fmt.Sprintf(keySourceURLs[source], url.PathEscape(user))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not query %v: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status querying %v: %v", target, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response: %w", err)
+	}
+	return body, nil
+}
@@ -1,9 +1,15 @@
 package driver
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
+	"text/template"
 
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
@@ -12,6 +18,46 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
+const (
+	md5FingerprintPrefix    = "MD5:"
+	sha256FingerprintPrefix = "SHA256:"
+
+	keyConflictReuse  = "reuse"
+	keyConflictFail   = "fail"
+	keyConflictSuffix = "suffix"
+
+	// additionalKeyHashLength is the number of hex digits of the fingerprint in names of uploaded additional keys
+	additionalKeyHashLength = 12
+)
+
+var md5FingerprintPattern = regexp.MustCompile(`^[0-9a-fA-F]{2}(:[0-9a-fA-F]{2}){15}$`)
+
+func isSHA256Fingerprint(fp string) bool {
+	return strings.HasPrefix(fp, sha256FingerprintPrefix)
+}
+
+// isSecurityKey checks whether the key is backed by a FIDO2 authenticator (sk-ssh-ed25519, sk-ecdsa)
+func isSecurityKey(key ssh.PublicKey) bool {
+	return key.Type() == ssh.KeyAlgoSKED25519 || key.Type() == ssh.KeyAlgoSKECDSA256
+}
+
+// remoteKeyMatches checks whether the remote key holds the same key material as the local one
+func remoteKeyMatches(remote *hcloud.SSHKey, local ssh.PublicKey) bool {
+	if remote.Fingerprint == ssh.FingerprintLegacyMD5(local) || remote.Fingerprint == ssh.FingerprintSHA256(local) {
+		return true
+	}
+
+	remoteKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(remote.PublicKey))
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(remoteKey.Marshal(), local.Marshal())
+}
+
+func isValidFingerprint(fp string) bool {
+	return isSHA256Fingerprint(fp) || md5FingerprintPattern.MatchString(strings.TrimPrefix(fp, md5FingerprintPrefix))
+}
+
 func (d *Driver) setupExistingKey() error {
 	if !d.IsExistingKey {
 		return nil
@@ -37,8 +83,7 @@ func (d *Driver) setupExistingKey() error {
 		return fmt.Errorf("could not parse authorized key: %w", err)
 	}
 
-	if key.Fingerprint != ssh.FingerprintLegacyMD5(pubk) &&
-		key.Fingerprint != ssh.FingerprintSHA256(pubk) {
+	if !remoteKeyMatches(key, pubk) {
 		return fmt.Errorf("remote key %d does not match local key %s", d.KeyID, d.originalKey)
 	}
 
@@ -65,9 +110,9 @@ func (d *Driver) createRemoteKeys() error {
 	if d.KeyID == 0 {
 		log.Infof("Creating SSH key...")
 
-		buf, err := os.ReadFile(d.GetSSHKeyPath() + ".pub")
+		buf, err := d.getLocalPublicKey()
 		if err != nil {
-			return fmt.Errorf("could not read ssh public key: %w", err)
+			return err
 		}
 
 		key, err := d.getRemoteKeyWithSameFingerprintNullable(buf)
@@ -77,25 +122,57 @@ func (d *Driver) createRemoteKeys() error {
 		if key == nil {
 			log.Infof("SSH key not found in Hetzner. Uploading...")
 
-			key, err = d.makeKey(d.GetMachineName(), string(buf), d.keyLabels)
+			name, err := d.machineKeyName()
+			if err != nil {
+				return err
+			}
+			if d.keyConflict == keyConflictSuffix {
+				if name, err = d.freeKeyName(name); err != nil {
+					return err
+				}
+			}
+			key, err = d.makeKey(name, string(buf), d.keyLabels)
 			if err != nil {
 				return err
 			}
+		} else if d.keyConflict == keyConflictFail {
+			return fmt.Errorf("ssh key %v[%d] with the same fingerprint already exists; pass --%v %v to use it",
+				key.Name, key.ID, flagKeyConflict, keyConflictReuse)
 		} else {
-			d.IsExistingKey = true
-			log.Debugf("SSH key found in Hetzner. ID: %d", key.ID)
+			// a key labeled for the machine, or named like its key, was uploaded by the driver for it, e.g. by an aborted
+			// creation
+			name, err := d.machineKeyName()
+			if err != nil {
+				return err
+			}
+			d.KeyReused = !d.isMachineKey(d.GetMachineName(), name, key)
+			log.Debugf("SSH key found in Hetzner. ID: %d (reused: %v)", key.ID, d.KeyReused)
 		}
 
 		d.KeyID = key.ID
 	}
-	for i, pubkey := range d.AdditionalKeys {
+
+	additionalKeys, err := d.resolveAdditionalKeys()
+	if err != nil {
+		return err
+	}
+
+	for _, pubkey := range additionalKeys {
 		key, err := d.getRemoteKeyWithSameFingerprintNullable([]byte(pubkey))
 		if err != nil {
 			return fmt.Errorf("error checking for existing key for %v: %w", pubkey, err)
 		}
 		if key == nil {
 			log.Infof("Creating new key for %v...", pubkey)
-			key, err = d.makeKey(fmt.Sprintf("%v-additional-%d", d.GetMachineName(), i), pubkey, d.keyLabels)
+			prefix, err := d.machineKeyName()
+			if err != nil {
+				return err
+			}
+			name, err := additionalKeyName(prefix, pubkey)
+			if err != nil {
+				return err
+			}
+			key, err = d.makeKey(name, pubkey, d.keyLabels)
 
 			if err != nil {
 				return fmt.Errorf("error creating new key for %v: %w", pubkey, err)
@@ -109,11 +186,154 @@ func (d *Driver) createRemoteKeys() error {
 
 		d.cachedAdditionalKeys = append(d.cachedAdditionalKeys, key)
 	}
+
+	for _, fp := range d.AdditionalKeyFingerprints {
+		key, err := d.getRemoteKeyByFingerprint(fp)
+		if err != nil {
+			return fmt.Errorf("could not resolve additional key %v: %w", fp, err)
+		}
+
+		log.Infof("Using existing key (%v) %v for fingerprint %v", key.ID, key.Name, fp)
+		d.cachedAdditionalKeys = append(d.cachedAdditionalKeys, key)
+	}
+	return nil
+}
+
+// setKeyConflictFlag validates the handling of existing remote keys matching the machine key
+func (d *Driver) setKeyConflictFlag(behavior string) error {
+	switch behavior {
+	case "":
+		d.keyConflict = keyConflictReuse
+	case keyConflictReuse, keyConflictFail, keyConflictSuffix:
+		d.keyConflict = behavior
+	default:
+		return d.flagFailure("--%v must be one of %v, %v, %v", flagKeyConflict, keyConflictReuse, keyConflictFail, keyConflictSuffix)
+	}
+	return nil
+}
+
+// keyNameData holds the values available to the template of --hetzner-ssh-key-name
+type keyNameData struct {
+	MachineName string
+	Project     string
+	Location    string
+	ServerType  string
+}
+
+// setKeyNameFlag parses the template of --hetzner-ssh-key-name, which the uploaded machine key is named after instead
+// of the machine, and renders it once to reject references to unknown values early
+func (d *Driver) setKeyNameFlag(name string) error {
+	d.SSHKeyName, d.keyNameTemplate = name, nil
+	if name == "" {
+		return nil
+	}
+
+	if _, err := d.getKeyNameTemplate(); err != nil {
+		return d.flagFailure("--%v: invalid template: %v", flagSSHKeyName, err)
+	}
+	if _, err := d.machineKeyName(); err != nil {
+		return d.flagFailure("--%v: %v", flagSSHKeyName, err)
+	}
 	return nil
 }
 
+// getKeyNameTemplate returns the parsed template of --hetzner-ssh-key-name, which is stored unparsed
+func (d *Driver) getKeyNameTemplate() (*template.Template, error) {
+	if d.keyNameTemplate != nil {
+		return d.keyNameTemplate, nil
+	}
+
+	tmpl, err := template.New(flagSSHKeyName).Parse(d.SSHKeyName)
+	if err != nil {
+		return nil, err
+	}
+	d.keyNameTemplate = tmpl
+	return tmpl, nil
+}
+
+// machineKeyName returns the name of the machine key, rendered from --hetzner-ssh-key-name if given; uploaded
+// additional keys are named after it as well
+func (d *Driver) machineKeyName() (string, error) {
+	if d.SSHKeyName == "" {
+		return d.GetMachineName(), nil
+	}
+
+	tmpl, err := d.getKeyNameTemplate()
+	if err != nil {
+		return "", fmt.Errorf("could not parse ssh key name: %w", err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, keyNameData{
+		MachineName: d.GetMachineName(),
+		Project:     d.Project,
+		Location:    d.Location,
+		ServerType:  d.Type,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not render ssh key name: %w", err)
+	}
+	name := strings.TrimSpace(buf.String())
+	if name == "" {
+		return "", fmt.Errorf("ssh key name rendered from %q is empty", d.SSHKeyName)
+	}
+	return name, nil
+}
+
+// freeKeyName returns name, or name with the lowest numeric suffix not used by any key yet
+func (d *Driver) freeKeyName(name string) (string, error) {
+	candidate := name
+	for i := 2; ; i++ {
+		key, _, err := d.getClient().SSHKey.GetByName(context.Background(), candidate)
+		if err != nil {
+			return "", fmt.Errorf("could not get ssh key by name: %w", err)
+		}
+		if key == nil {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%v-%d", name, i)
+	}
+}
+
+// additionalKeyName names an uploaded additional key after the machine and a short fingerprint of the key, so names stay
+// unique and recognizable when the same keys are used for many machines
+func additionalKeyName(machine, pubkey string) (string, error) {
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pubkey))
+	if err != nil {
+		return "", fmt.Errorf("could not parse ssh public key: %w", err)
+	}
+	sum := sha256.Sum256(publicKey.Marshal())
+	return fmt.Sprintf("%v-%v", machine, hex.EncodeToString(sum[:])[:additionalKeyHashLength]), nil
+}
+
+// isAdditionalKeyName reports whether the key name is one of an additional key uploaded for the machine, including the
+// index-based names of earlier versions
+func isAdditionalKeyName(machine, keyName string) bool {
+	if strings.HasPrefix(keyName, machine+"-additional-") {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(keyName, machine+"-")
+	if !ok || len(suffix) != additionalKeyHashLength {
+		return false
+	}
+	_, err := hex.DecodeString(suffix)
+	return err == nil
+}
+
+// isMachineKey reports whether the driver uploaded the key for the machine: labeled keys belong to the machine named by
+// their machine label, unlabeled keys uploaded by earlier versions are recognized by the machine key name and the
+// additional key names derived from it
+func (d *Driver) isMachineKey(machine, keyName string, key *hcloud.SSHKey) bool {
+	if owner, ok := key.Labels[d.labelName(labelMachine)]; ok {
+		return owner == machine
+	}
+	return key.Name == keyName || isAdditionalKeyName(keyName, key.Name)
+}
+
 func (d *Driver) prepareLocalKey() error {
-	if d.originalKey != "" {
+	if d.SSHAgentKey != "" {
+		log.Debugf("Using ssh-agent, no local key required")
+		return nil
+	} else if d.originalKey != "" {
 		log.Debugf("Copying SSH key...")
 		if err := d.copySSHKeyPair(d.originalKey); err != nil {
 			return fmt.Errorf("could not copy ssh key pair: %w", err)
@@ -127,26 +347,33 @@ func (d *Driver) prepareLocalKey() error {
 	return nil
 }
 
-// Creates a new key for the machine and appends it to the dangling key list
+// Creates a new key for the machine and appends it to the dangling key list; the key is labeled with the machine name,
+// so it can be told apart from keys uploaded by other means whatever its name
 func (d *Driver) makeKey(name string, pubkey string, labels map[string]string) (*hcloud.SSHKey, error) {
+	keyLabels := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		keyLabels[k] = v
+	}
+	keyLabels[d.labelName(labelMachine)] = d.GetMachineName()
+
 	keyopts := hcloud.SSHKeyCreateOpts{
 		Name:      name,
 		PublicKey: pubkey,
-		Labels:    labels,
+		Labels:    keyLabels,
 	}
 
 	key, _, err := d.getClient().SSHKey.Create(context.Background(), instrumented(keyopts))
-	if err != nil {
+	if hcloud.IsError(err, hcloud.ErrorCodeResourceLimitExceeded) {
+		return nil, fmt.Errorf("could not create ssh key, the project's ssh key limit is reached; %v: %w", keyLimitHint, err)
+	} else if err != nil {
 		return nil, fmt.Errorf("could not create ssh key: %w", err)
 	} else if key == nil {
 		return nil, fmt.Errorf("key upload did not return an error, but key was nil")
 	}
 
-	d.dangling = append(d.dangling, func() {
+	d.trackDangling("ssh key", func() error {
 		_, err := d.getClient().SSHKey.Delete(context.Background(), key)
-		if err != nil {
-			log.Error(fmt.Errorf("could not delete ssh key: %w", err))
-		}
+		return err
 	})
 
 	return key, nil
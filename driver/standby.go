@@ -0,0 +1,351 @@
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	labelStandby       = "standby"
+	labelStandbyConfig = "standby-config"
+
+	// standbyStoreDir is the directory within the docker-machine store holding the local state of standby machines
+	standbyStoreDir = "hetzner-standby"
+)
+
+// setStandbyPoolFlag validates the pool name of --hetzner-standby-pool, which becomes a label value
+func (d *Driver) setStandbyPoolFlag(pool string) error {
+	d.standbyPool = pool
+	if pool == "" {
+		return nil
+	}
+	if ok, err := hcloud.ValidateResourceLabels(map[string]interface{}{d.labelName(labelStandby): pool}); !ok {
+		return d.flagFailure("--%v: invalid pool name: %v", flagStandbyPool, err)
+	}
+	return nil
+}
+
+// standbyStorePath returns the store holding the local state, i.e. the SSH keys, of the standby machines of a pool
+func standbyStorePath(storePath, pool string) string {
+	return filepath.Join(storePath, standbyStoreDir, pool)
+}
+
+// CreateStandby creates the configured machine powered off as a standby machine of the pool given by
+// --hetzner-standby-pool, to be claimed by a later creation using the same pool; its local state is kept in a
+// subdirectory of the docker-machine store rather than as a regular machine
+func (d *Driver) CreateStandby() error {
+	if d.standbyPool == "" {
+		return fmt.Errorf("--%v is required for standby machines", flagStandbyPool)
+	}
+
+	// standby servers are not booted until claimed; settings not digested by standbyConfig are applied when claiming
+	// them instead, as after creating a server
+	d.startPoweredOff = true
+	d.EngineLabels = false
+	d.phoneHomeListen = ""
+	d.reachabilityTimeout = 0
+	d.FloatingIP, d.CreateFloatingIP = "", false
+	d.ISO, d.BootRescue, d.EnableBackup, d.Protection = "", false, false, false
+	d.LoadBalancers, d.AutoLoadBalancer = nil, ""
+
+	d.StorePath = standbyStorePath(d.StorePath, d.standbyPool)
+	if err := os.MkdirAll(d.ResolveStorePath("."), 0700); err != nil {
+		return fmt.Errorf("could not create standby machine directory: %w", err)
+	}
+
+	config, err := d.standbyConfig()
+	if err != nil {
+		return err
+	}
+	labels := make(map[string]string, len(d.ServerLabels)+2)
+	for key, value := range d.ServerLabels {
+		labels[key] = value
+	}
+	labels[d.labelName(labelStandby)] = d.standbyPool
+	labels[d.labelName(labelStandbyConfig)] = config
+	d.ServerLabels = labels
+
+	err = d.PreCreateCheck()
+	if err == nil {
+		err = d.Create()
+	}
+	if err != nil {
+		_ = os.RemoveAll(d.ResolveStorePath("."))
+	}
+	return err
+}
+
+// standbyConfig digests the settings which are fixed once a server is created, e.g. its user data, networks and
+// firewalls, so standby servers are only claimed by machines created with the same ones; the remaining settings are
+// applied when claiming the server, like after creating one
+func (d *Driver) standbyConfig() (string, error) {
+	parts, err := d.userDataParts()
+	if err != nil {
+		return "", fmt.Errorf("could not read user data: %w", err)
+	}
+	var userData []string
+	for _, part := range parts {
+		userData = append(userData, part.mergeHow+"\n"+part.content)
+	}
+	cloudConfig, err := d.generateCloudConfig()
+	if err != nil {
+		return "", err
+	}
+	generated, err := cloudConfig.marshal()
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := json.Marshal(struct {
+		UserData       []string
+		CloudConfig    string
+		PhoneHome      string
+		Networks       []string
+		NetworkIPs     map[string]string
+		Volumes        []string
+		Firewalls      []string
+		FirewallLabel  string
+		FirewallPreset string
+		FirewallSource []string
+		PlacementGroup string
+		AutoSpread     string
+		PublicNet      []interface{}
+		VSwitch        []interface{}
+		AdditionalKeys []string
+	}{
+		UserData:       userData,
+		CloudConfig:    generated,
+		PhoneHome:      d.phoneHomeListen,
+		Networks:       d.Networks,
+		NetworkIPs:     d.NetworkIPs,
+		Volumes:        d.Volumes,
+		Firewalls:      d.Firewalls,
+		FirewallLabel:  d.FirewallLabel,
+		FirewallPreset: d.FirewallPreset,
+		FirewallSource: d.FirewallSources,
+		PlacementGroup: d.placementGroup,
+		AutoSpread:     d.autoSpreadName,
+		PublicNet:      []interface{}{d.DisablePublic4, d.DisablePublic6, d.PrimaryIPv4, d.PrimaryIPv6, d.PrimaryIPPool, d.reusePrimaryIPOf},
+		VSwitch:        []interface{}{d.VSwitchID, d.VSwitchSubnet},
+		AdditionalKeys: append(append([]string{}, d.AdditionalKeys...), d.AdditionalKeyFingerprints...),
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not serialize standby settings: %w", err)
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// claimStandby takes over a powered off standby server of the pool, renaming, relabeling and starting it as this
+// machine; it returns nil if no standby server could be claimed
+func (d *Driver) claimStandby() (*hcloud.Server, error) {
+	servers, err := d.getClient().Server.AllWithOpts(context.Background(), hcloud.ServerListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: fmt.Sprintf("%v=%v", d.labelName(labelStandby), d.standbyPool)},
+		Status:   []hcloud.ServerStatus{hcloud.ServerStatusOff},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list standby servers: %w", err)
+	}
+
+	stype, err := d.getType()
+	if err != nil {
+		return nil, fmt.Errorf("could not get type: %w", err)
+	}
+	image, err := d.getImage()
+	if err != nil {
+		return nil, fmt.Errorf("could not get image: %w", err)
+	}
+	config, err := d.standbyConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, srv := range servers {
+		if mismatch := d.standbyMismatch(srv, stype, image, config); mismatch != "" {
+			log.Infof(" -> Skipping standby server %v[%d], its %v", srv.Name, srv.ID, mismatch)
+			continue
+		}
+
+		standby := d.standbyMachine(srv.Name)
+		unlock, locked, err := standby.tryLockStandby()
+		if err != nil {
+			return nil, err
+		}
+		if !locked {
+			log.Debugf("standby server %v[%d] is being claimed by another operation", srv.Name, srv.ID)
+			continue
+		}
+
+		claimed, err := d.claimStandbyServer(srv, standby.GetSSHKeyPath())
+		unlock()
+		if err != nil {
+			return nil, err
+		}
+		_ = os.RemoveAll(standby.ResolveStorePath("."))
+		return claimed, nil
+	}
+
+	log.Infof(" -> No standby server available in pool %v", d.standbyPool)
+	return nil, nil
+}
+
+// standbyMismatch tells how a standby server differs from the machine to be created in its type, image, location, if
+// given, or the settings digested by standbyConfig; it returns an empty string if the standby server matches
+func (d *Driver) standbyMismatch(srv *hcloud.Server, stype *hcloud.ServerType, image *hcloud.Image, config string) string {
+	switch {
+	case srv.ServerType == nil || srv.ServerType.ID != stype.ID:
+		return fmt.Sprintf("server type differs from %v", stype.Name)
+	case srv.Image == nil || srv.Image.ID != image.ID:
+		return fmt.Sprintf("image differs from %v[%d]", image.Name, image.ID)
+	case d.Location != "" && (srv.Datacenter == nil || srv.Datacenter.Location == nil || srv.Datacenter.Location.Name != d.Location):
+		return fmt.Sprintf("location differs from %v", d.Location)
+	case srv.Labels[d.labelName(labelStandbyConfig)] != config:
+		return "create settings such as user data, networks or firewalls differ"
+	}
+	return ""
+}
+
+// standbyMachine returns a copy of the driver for the standby machine of the given name, whose local state is kept in
+// the store of the pool; the base driver is copied as well, as it is shared by reference
+func (d *Driver) standbyMachine(name string) *Driver {
+	base := *d.BaseDriver
+	base.StorePath, base.MachineName, base.SSHKeyPath = standbyStorePath(d.StorePath, d.standbyPool), name, ""
+	standby := *d
+	standby.BaseDriver = &base
+	return &standby
+}
+
+// tryLockStandby acquires the lock of a standby machine without waiting, as any other one may be claimed instead; it
+// also reports whether the standby machine's local state exists on this host
+func (d *Driver) tryLockStandby() (func(), bool, error) {
+	if _, err := os.Stat(d.GetSSHKeyPath()); err != nil {
+		return nil, false, nil // pre-provisioned by another host
+	}
+
+	f, err := os.OpenFile(d.ResolveStorePath(machineLockFile), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not open standby machine lock: %w", err)
+	}
+	locked, err := tryLockFile(f)
+	if err != nil || !locked {
+		_ = f.Close()
+		return nil, false, err
+	}
+
+	// a successful claim removes the standby machine's directory, so recheck after acquiring the lock
+	if _, err := os.Stat(d.GetSSHKeyPath()); err != nil {
+		_ = f.Close()
+		return nil, false, nil
+	}
+	return func() {
+		_ = unlockFile(f)
+		_ = f.Close()
+	}, true, nil
+}
+
+// claimStandbyServer renames and relabels the standby server and its SSH key as this machine and starts it; should that
+// fail, the renaming is reverted, so the server stays a standby server of the pool
+func (d *Driver) claimStandbyServer(standby *hcloud.Server, keyPath string) (srv *hcloud.Server, err error) {
+	log.Infof("Claiming standby server %v[%d] ...", standby.Name, standby.ID)
+
+	for _, suffix := range []string{"", ".pub"} {
+		key, err := os.ReadFile(keyPath + suffix)
+		if err != nil {
+			return nil, fmt.Errorf("could not read standby ssh key: %w", err)
+		}
+		if err = os.WriteFile(d.GetSSHKeyPath()+suffix, key, 0600); err != nil {
+			return nil, fmt.Errorf("could not write ssh key: %w", err)
+		}
+	}
+
+	key, _, err := d.getClient().SSHKey.GetByName(context.Background(), standby.Name)
+	if err != nil {
+		return nil, fmt.Errorf("could not get standby ssh key: %w", err)
+	}
+	if key == nil {
+		return nil, errors.New("standby ssh key not found")
+	}
+	keyLabels := make(map[string]string, len(key.Labels)+1)
+	for k, v := range key.Labels {
+		keyLabels[k] = v
+	}
+	keyLabels[d.labelName(labelMachine)] = d.GetMachineName()
+	if _, _, err = d.getClient().SSHKey.Update(context.Background(), key, hcloud.SSHKeyUpdateOpts{
+		Name:   d.GetMachineName(),
+		Labels: keyLabels,
+	}); err != nil {
+		return nil, fmt.Errorf("could not rename standby ssh key: %w", err)
+	}
+	d.KeyID = key.ID
+	defer func() {
+		if err == nil {
+			return
+		}
+		d.KeyID = 0
+		if _, _, rerr := d.getClient().SSHKey.Update(context.Background(), key, hcloud.SSHKeyUpdateOpts{
+			Name:   key.Name,
+			Labels: key.Labels,
+		}); rerr != nil {
+			err = errors.Join(err, fmt.Errorf("could not restore standby ssh key %v[%d]: %w", key.Name, key.ID, rerr))
+		}
+	}()
+
+	labels := d.ServerLabels
+	if labels == nil {
+		labels = make(map[string]string) // an empty map clears the standby label, unlike nil
+	}
+	srv, _, err = d.getClient().Server.Update(context.Background(), standby, instrumented(hcloud.ServerUpdateOpts{
+		Name:   d.GetMachineName(),
+		Labels: labels,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("could not rename standby server: %w", err)
+	}
+	d.ServerID = srv.ID
+	d.cachedServer = nil
+	defer func() {
+		if err == nil {
+			return
+		}
+		d.ServerID = 0
+		if _, _, rerr := d.getClient().Server.Update(context.Background(), standby, hcloud.ServerUpdateOpts{
+			Name:   standby.Name,
+			Labels: standby.Labels,
+		}); rerr != nil {
+			err = errors.Join(err, fmt.Errorf("could not restore standby server %v[%d]: %w", standby.Name, standby.ID, rerr))
+		}
+	}()
+
+	if err = d.attachISO(srv); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err == nil || d.ISO == "" {
+			return
+		}
+		if _, _, rerr := d.getClient().Server.DetachISO(context.Background(), standby); rerr != nil {
+			err = errors.Join(err, fmt.Errorf("could not detach ISO from standby server %v[%d]: %w", standby.Name, standby.ID, rerr))
+		}
+	}()
+	act, _, err := d.getClient().Server.Poweron(context.Background(), srv)
+	if err != nil {
+		return nil, fmt.Errorf("could not power on server: %w", err)
+	}
+	log.Infof(" -> Starting server %s[%d] in %s[%d]...", srv.Name, srv.ID, act.Command, act.ID)
+	if err = d.waitForAction(act); err != nil {
+		return nil, fmt.Errorf("could not wait for action: %w", err)
+	}
+	if err = d.waitForRunningServer(); err != nil {
+		return nil, err
+	}
+	return srv, nil
+}
@@ -0,0 +1,122 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const maxSuggestions = 3
+
+// levenshtein computes the edit distance between a and b
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// suggest returns the candidates closest to name, considering only those within a reasonable edit distance or
+// containing name
+func suggest(name string, candidates []string) []string {
+	name = strings.ToLower(name)
+	threshold := max(1, len(name)/3)
+
+	type match struct {
+		candidate string
+		distance  int
+	}
+	var matches []match
+	for _, candidate := range candidates {
+		distance := levenshtein(name, strings.ToLower(candidate))
+		if distance <= threshold || (name != "" && strings.Contains(strings.ToLower(candidate), name)) {
+			matches = append(matches, match{candidate, distance})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].distance < matches[j].distance
+	})
+
+	var ret []string
+	for _, m := range matches {
+		if len(ret) == maxSuggestions {
+			break
+		}
+		ret = append(ret, m.candidate)
+	}
+	return ret
+}
+
+// didYouMean formats suggestions for name as an error message suffix, or returns an empty string if there are none
+func didYouMean(name string, candidates []string) string {
+	suggestions := suggest(name, candidates)
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %v?)", strings.Join(suggestions, ", "))
+}
+
+func (d *Driver) suggestServerTypes(name string) string {
+	types, err := d.getClient().ServerType.All(context.Background())
+	if err != nil {
+		log.Debugf("could not list server types for suggestions: %v", err)
+		return ""
+	}
+
+	names := make([]string, 0, len(types))
+	for _, stype := range types {
+		names = append(names, stype.Name)
+	}
+	return didYouMean(name, names)
+}
+
+func (d *Driver) suggestLocations(name string) string {
+	locations, err := d.getClient().Location.All(context.Background())
+	if err != nil {
+		log.Debugf("could not list locations for suggestions: %v", err)
+		return ""
+	}
+
+	names := make([]string, 0, len(locations))
+	for _, location := range locations {
+		names = append(names, location.Name)
+	}
+	return didYouMean(name, names)
+}
+
+func (d *Driver) suggestImages(name string, arch hcloud.Architecture) string {
+	images, err := d.getClient().Image.AllWithOpts(context.Background(), hcloud.ImageListOpts{
+		Architecture: []hcloud.Architecture{arch},
+	})
+	if err != nil {
+		log.Debugf("could not list images for suggestions: %v", err)
+		return ""
+	}
+
+	names := make([]string, 0, len(images))
+	for _, image := range images {
+		if image.Name != "" {
+			names = append(names, image.Name)
+		}
+	}
+	return didYouMean(name, names)
+}
@@ -0,0 +1,24 @@
+package driver
+
+import (
+	"regexp"
+	"strings"
+)
+
+const swapFile = "/swapfile"
+
+var swapSize = regexp.MustCompile(`^[1-9][0-9]*[KMGT]?$`)
+
+// setSwapSizeFlag validates --hetzner-swap-size, being a number of bytes with an optional K, M, G or T suffix
+func (d *Driver) setSwapSizeFlag(size string) error {
+	d.swapSize = strings.ToUpper(strings.TrimSpace(size))
+	if d.swapSize != "" && !swapSize.MatchString(d.swapSize) {
+		return d.flagFailure("--%v must be a size like 2G, got: %v", flagSwapSize, size)
+	}
+	return nil
+}
+
+// swapCloudConfig returns the cloud-init swap configuration creating and enabling a swap file of --hetzner-swap-size
+func (d *Driver) swapCloudConfig() *cloudInitSwap {
+	return &cloudInitSwap{Filename: swapFile, Size: d.swapSize, MaxSize: d.swapSize}
+}
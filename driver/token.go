@@ -0,0 +1,43 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+var errReadOnlyToken = errors.New("the API token is read-only, but creating machines requires a read & write token")
+
+// verifyTokenWritable detects read-only tokens before any resources are created, by attempting to create an invalid
+// SSH key: a writable token yields a validation error, while a read-only one is rejected as forbidden
+func (d *Driver) verifyTokenWritable() error {
+	key, _, err := d.getClient().SSHKey.Create(context.Background(), hcloud.SSHKeyCreateOpts{
+		Name:      d.GetMachineName() + "-permission-probe",
+		PublicKey: "invalid",
+	})
+
+	switch {
+	case err == nil:
+		// should never happen, but the token evidently is writable
+		if _, err = d.getClient().SSHKey.Delete(context.Background(), key); err != nil {
+			return fmt.Errorf("could not delete permission probe SSH key: %w", err)
+		}
+	case hcloud.IsError(err, hcloud.ErrorCodeForbidden):
+		return errReadOnlyToken
+	case !hcloud.IsError(err, hcloud.ErrorCodeInvalidInput):
+		log.Debugf("could not determine token permissions: %v", err)
+	}
+	return nil
+}
+
+// withTokenHint points out read-only tokens as the likely cause of forbidden API errors
+func withTokenHint(err error) error {
+	var apiErr hcloud.Error
+	if err == nil || errors.Is(err, errReadOnlyToken) || !errors.As(err, &apiErr) || apiErr.Code != hcloud.ErrorCodeForbidden {
+		return err
+	}
+	return fmt.Errorf("%w (is the API token read-only?)", err)
+}
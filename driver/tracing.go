@@ -0,0 +1,141 @@
+package driver
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	tracerName         = "github.com/JonasProgrammer/docker-machine-driver-hetzner"
+	traceFlushTimeout  = 10 * time.Second
+	tracingServiceName = "docker-machine-driver-hetzner"
+)
+
+var (
+	tracerOnce     sync.Once
+	tracerProvider *sdktrace.TracerProvider
+	tracer         trace.Tracer = noop.NewTracerProvider().Tracer(tracerName)
+)
+
+// tracingEnabled checks whether an OTLP endpoint was configured via the standard OpenTelemetry environment variables
+func tracingEnabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+func (d *Driver) setupTracing() {
+	tracerOnce.Do(func() {
+		if !tracingEnabled() {
+			return
+		}
+
+		exporter, err := otlptracehttp.New(context.Background())
+		if err != nil {
+			log.Warnf("could not set up OTLP trace exporter: %v", err)
+			return
+		}
+
+		res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(tracingServiceName),
+			semconv.ServiceVersion(d.version),
+		))
+		if err != nil {
+			log.Debugf("could not merge tracing resource: %v", err)
+			res = resource.Default()
+		}
+
+		tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+		tracer = tracerProvider.Tracer(tracerName, trace.WithInstrumentationVersion(d.version))
+	})
+}
+
+// traceOperation starts a span for a driver operation, which becomes the parent of all API calls made until the
+// returned function is called; the latter ends the span, recording the error pointed to (if any)
+func (d *Driver) traceOperation(name string) func(*error) {
+	d.setupTracing()
+
+	ctx, span := tracer.Start(context.Background(), "driver."+name, trace.WithAttributes(
+		attribute.String("machine.name", d.GetMachineName()),
+		attribute.Int64("hcloud.server.id", d.ServerID),
+	))
+	previous := d.traceCtx
+	d.traceCtx = ctx
+
+	return func(err *error) {
+		if err != nil && *err != nil {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.SetAttributes(attribute.Int64("hcloud.server.id", d.ServerID))
+		span.End()
+		d.traceCtx = previous
+
+		if tracerProvider != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+			defer cancel()
+			if err := tracerProvider.ForceFlush(ctx); err != nil {
+				log.Debugf("could not flush traces: %v", err)
+			}
+		}
+	}
+}
+
+func (d *Driver) traceContext() context.Context {
+	if d.traceCtx == nil {
+		return context.Background()
+	}
+	return d.traceCtx
+}
+
+// tracingTransport creates a span for every API request, as a child of the currently traced driver operation
+type tracingTransport struct {
+	driver *Driver
+	next   http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	_, span := tracer.Start(t.driver.traceContext(), "hcloud "+req.Method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethod(req.Method),
+			semconv.URLPath(req.URL.Path),
+		))
+	defer span.End()
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+
+	span.SetAttributes(semconv.HTTPStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
+
+func (d *Driver) setupClientTracing(httpClient *http.Client) {
+	d.setupTracing()
+	if tracerProvider == nil {
+		return
+	}
+
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	httpClient.Transport = tracingTransport{driver: d, next: next}
+}
@@ -0,0 +1,62 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// Traffic prints the traffic the server used in the current billing period and the traffic included in its price
+func (d *Driver) Traffic(w io.Writer) error {
+	if d.Robot {
+		return errors.New("traffic is not reported for robot servers")
+	}
+
+	srv, err := d.getServerHandle()
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "outgoing: %v of %v included (%.1f%%)\ningoing:  %v\n", formatBytes(srv.OutgoingTraffic),
+		formatBytes(srv.IncludedTraffic), trafficPercentage(srv), formatBytes(srv.IngoingTraffic))
+	return err
+}
+
+// warnTrafficUsage warns once per driver instance if the server's outgoing traffic exceeds --hetzner-traffic-warning
+// percent of the included traffic, as traffic beyond it is billed
+func (d *Driver) warnTrafficUsage(srv *hcloud.Server) {
+	if d.TrafficWarning <= 0 || d.trafficWarned || srv.IncludedTraffic == 0 {
+		return
+	}
+
+	if percentage := trafficPercentage(srv); percentage >= float64(d.TrafficWarning) {
+		log.Warnf("server %v[%d] used %.1f%% of its included traffic (%v of %v)", srv.Name, srv.ID, percentage,
+			formatBytes(srv.OutgoingTraffic), formatBytes(srv.IncludedTraffic))
+		d.trafficWarned = true
+	}
+}
+
+// trafficPercentage returns the share of the included traffic used by outgoing traffic, which alone is billed
+func trafficPercentage(srv *hcloud.Server) float64 {
+	if srv.IncludedTraffic == 0 {
+		return 0
+	}
+	return float64(srv.OutgoingTraffic) / float64(srv.IncludedTraffic) * 100
+}
+
+// formatBytes formats a number of bytes using binary units
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit && exp < 4; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTP"[exp])
+}
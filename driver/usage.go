@@ -0,0 +1,108 @@
+package driver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// summarizedOperations are the operations whose API usage is logged when they finish
+var summarizedOperations = map[string]bool{
+	"Create": true,
+	"Remove": true,
+}
+
+// usageMu guards the usage of drivers, which is read by concurrent requests
+var usageMu sync.Mutex
+
+// apiUsage tracks the API calls made during a driver operation
+type apiUsage struct {
+	start              time.Time
+	calls              atomic.Int64
+	retries            atomic.Int64
+	rateLimitRemaining atomic.Int64 // as of the most recent response, -1 if unknown
+}
+
+func newAPIUsage() *apiUsage {
+	usage := &apiUsage{start: time.Now()}
+	usage.rateLimitRemaining.Store(-1)
+	return usage
+}
+
+func (u *apiUsage) String() string {
+	remaining := "unknown"
+	if r := u.rateLimitRemaining.Load(); r >= 0 {
+		remaining = strconv.FormatInt(r, 10)
+	}
+	return fmt.Sprintf("API usage: %d calls, %d retries, rate limit remaining %v, took %v",
+		u.calls.Load(), u.retries.Load(), remaining, time.Since(u.start).Round(time.Millisecond))
+}
+
+// trackUsage starts tracking the API usage of a driver operation; the returned function stops it and logs a summary
+// for summarizedOperations. Operations nested in another one, such as Start during Create, add to its usage instead.
+func (d *Driver) trackUsage(name string) func() {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	if d.usage != nil {
+		return func() {}
+	}
+	usage := newAPIUsage()
+	d.usage = usage
+
+	return func() {
+		if summarizedOperations[name] {
+			log.Infof("%v %v", name, usage)
+		}
+		usageMu.Lock()
+		d.usage = nil
+		usageMu.Unlock()
+	}
+}
+
+// currentUsage returns the usage of the operation in progress, if any
+func (d *Driver) currentUsage() *apiUsage {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	return d.usage
+}
+
+// usageTransport counts the requests made and records the remaining rate limit budget of each response
+type usageTransport struct {
+	d    *Driver
+	next http.RoundTripper
+}
+
+func (t usageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	usage := t.d.currentUsage()
+	if usage == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	usage.calls.Add(1)
+	resp, err := t.next.RoundTrip(req)
+	if err == nil {
+		if remaining, err := strconv.ParseInt(resp.Header.Get("RateLimit-Remaining"), 10, 64); err == nil {
+			usage.rateLimitRemaining.Store(remaining)
+		}
+	}
+	return resp, err
+}
+
+func (d *Driver) setupClientUsageTracking(httpClient *http.Client) {
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	httpClient.Transport = usageTransport{d: d, next: next}
+}
+
+func (d *Driver) recordRetry() {
+	if usage := d.currentUsage(); usage != nil {
+		usage.retries.Add(1)
+	}
+}
@@ -0,0 +1,98 @@
+package driver
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+const (
+	userDataMergeAppend  = "append"
+	userDataMergeReplace = "replace"
+)
+
+// userDataMergeTypes maps the merge types of --hetzner-user-data-merge-file to the cloud-init merge classes they stand
+// for; append extends lists such as runcmd and write_files of the preceding parts, replace overrides their keys
+var userDataMergeTypes = map[string]string{
+	userDataMergeAppend:  cloudConfigMergeHow,
+	userDataMergeReplace: "dict(replace)+list()+str()",
+}
+
+// userDataFile is a file of --hetzner-user-data-merge-file, merged into the preceding user data with the merge type
+type userDataFile struct {
+	path  string
+	merge string
+}
+
+// parseUserDataMergeFiles parses the values of --hetzner-user-data-merge-file, each a path optionally suffixed with a
+// merge type, e.g. overlay.yaml:replace; files are appended to the preceding user data by default
+func parseUserDataMergeFiles(values []string) []userDataFile {
+	var files []userDataFile
+	for _, value := range values {
+		file := userDataFile{path: value, merge: userDataMergeAppend}
+		if sep := strings.LastIndex(value, ":"); sep > 0 {
+			if _, ok := userDataMergeTypes[value[sep+1:]]; ok {
+				file = userDataFile{path: value[:sep], merge: value[sep+1:]}
+			}
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// userDataParts returns the user data passed inline or as file, followed by a part for each file to merge into it
+func (d *Driver) userDataParts() ([]userDataPart, error) {
+	userData := d.userData
+	if d.userDataFile != "" {
+		content, err := os.ReadFile(d.userDataFile)
+		if err != nil {
+			return nil, err
+		}
+		userData = string(content)
+	}
+	if userData == "" {
+		return nil, nil
+	}
+
+	parts := []userDataPart{{contentType: "text/plain", content: userData}}
+	for _, file := range d.userDataMerges {
+		content, err := os.ReadFile(file.path)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, userDataPart{
+			contentType: "text/plain",
+			content:     string(content),
+			mergeHow:    userDataMergeTypes[file.merge],
+		})
+	}
+	return parts, nil
+}
+
+// getUserData returns the user data passed by the user, combining multiple files into a MIME multipart archive
+func (d *Driver) getUserData() (string, error) {
+	parts, err := d.userDataParts()
+	if err != nil {
+		return "", err
+	}
+	switch len(parts) {
+	case 0:
+		return "", nil
+	case 1:
+		return parts[0].content, nil
+	}
+	if err = checkMultipartCombination(parts); err != nil {
+		return "", err
+	}
+	return multipartUserData(parts)
+}
+
+// checkMultipartCombination rejects parts which are MIME multipart archives themselves, as they cannot be nested
+func checkMultipartCombination(parts []userDataPart) error {
+	for _, part := range parts {
+		if isMultipartUserData(part.content) {
+			return errors.New("cannot combine MIME multipart user data with further parts, add them to it yourself")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// AttachVolume attaches the volume given by ID or name to the existing server, optionally mounting it automatically,
+// and adds it to the configured volumes
+func (d *Driver) AttachVolume(idOrName string, automount bool) (err error) {
+	defer d.operation("AttachVolume")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	srv, volume, err := d.getServerAndVolume(idOrName)
+	if err != nil {
+		return err
+	}
+	if volume.Server != nil {
+		if volume.Server.ID != srv.ID {
+			return fmt.Errorf("volume %v is attached to another server: %d", volume.Name, volume.Server.ID)
+		}
+		log.Infof("Volume %v[%d] is already attached to server %v[%d]", volume.Name, volume.ID, srv.Name, srv.ID)
+	} else if srv.Datacenter != nil && srv.Datacenter.Location != nil && volume.Location != nil &&
+		volume.Location.Name != srv.Datacenter.Location.Name {
+		// volumes can only be attached to servers in their location
+		return fmt.Errorf("volume %v is in location %v, but server %v is in location %v", volume.Name,
+			volume.Location.Name, srv.Name, srv.Datacenter.Location.Name)
+	} else {
+		log.Infof("Attaching volume %v[%d] to server %v[%d] ...", volume.Name, volume.ID, srv.Name, srv.ID)
+		action, _, err := d.getClient().Volume.AttachWithOpts(context.Background(), volume, hcloud.VolumeAttachOpts{
+			Server:    srv,
+			Automount: &automount,
+		})
+		if err != nil {
+			return fmt.Errorf("could not attach volume: %w", err)
+		}
+		if err = d.waitForAction(action); err != nil {
+			return fmt.Errorf("could not wait for volume attachment: %w", err)
+		}
+	}
+
+	d.Volumes = append(withoutResource(d.Volumes, volume.ID, volume.Name), volume.Name)
+	return nil
+}
+
+// DetachVolume detaches the volume given by ID or name from the existing server and removes it from the configured
+// volumes; it should be unmounted beforehand
+func (d *Driver) DetachVolume(idOrName string) (err error) {
+	defer d.operation("DetachVolume")(&err)
+
+	unlock, err := d.lockMachine()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	srv, volume, err := d.getServerAndVolume(idOrName)
+	if err != nil {
+		return err
+	}
+	if volume.Server == nil {
+		log.Infof("Volume %v[%d] is not attached", volume.Name, volume.ID)
+	} else if volume.Server.ID != srv.ID {
+		return fmt.Errorf("volume %v is attached to another server: %d", volume.Name, volume.Server.ID)
+	} else {
+		log.Infof("Detaching volume %v[%d] from server %v[%d] ...", volume.Name, volume.ID, srv.Name, srv.ID)
+		action, _, err := d.getClient().Volume.Detach(context.Background(), volume)
+		if err != nil {
+			return fmt.Errorf("could not detach volume: %w", err)
+		}
+		if err = d.waitForAction(action); err != nil {
+			return fmt.Errorf("could not wait for volume detachment: %w", err)
+		}
+	}
+
+	d.Volumes = withoutResource(d.Volumes, volume.ID, volume.Name)
+	return nil
+}
+
+func (d *Driver) getServerAndVolume(idOrName string) (*hcloud.Server, *hcloud.Volume, error) {
+	if d.Robot {
+		return nil, nil, errors.New("volumes cannot be managed for robot servers")
+	}
+
+	srv, err := d.getServerHandle()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	volume, _, err := d.getClient().Volume.Get(context.Background(), idOrName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get volume by ID or name: %w", err)
+	}
+	if volume == nil {
+		return nil, nil, notFoundError("volume", idOrName)
+	}
+	return srv, volume, nil
+}
@@ -0,0 +1,141 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// vSwitchCoupling describes what it takes to couple the first network the server is attached to with the configured
+// Robot vSwitch
+type vSwitchCoupling struct {
+	network      *hcloud.Network
+	zone         hcloud.NetworkZone
+	subnet       *net.IPNet // vSwitch subnet to add, if not coupled yet
+	exposeRoutes bool
+}
+
+// checkVSwitch determines the coupling of the network with the vSwitch without changing anything, so it can run
+// before creation; it fails if the network cannot be coupled as configured
+func (d *Driver) checkVSwitch() (*vSwitchCoupling, error) {
+	if d.VSwitchID == 0 {
+		return nil, nil
+	}
+
+	network, _, err := d.getClient().Network.Get(context.Background(), d.Networks[0])
+	if err != nil {
+		return nil, fmt.Errorf("could not get network by ID or name: %w", err)
+	}
+	if network == nil {
+		return nil, notFoundError("network", d.Networks[0])
+	}
+
+	var cloudSubnet, vswitchSubnet *hcloud.NetworkSubnet
+	for i, subnet := range network.Subnets {
+		switch {
+		case subnet.Type == hcloud.NetworkSubnetTypeVSwitch && subnet.VSwitchID == d.VSwitchID:
+			vswitchSubnet = &network.Subnets[i]
+		case subnet.Type == hcloud.NetworkSubnetTypeCloud || subnet.Type == hcloud.NetworkSubnetTypeServer:
+			if cloudSubnet == nil {
+				cloudSubnet = &network.Subnets[i]
+			}
+		}
+	}
+
+	if cloudSubnet == nil {
+		return nil, fmt.Errorf("network %v has no cloud subnet for the server to be attached to", network.Name)
+	}
+
+	coupling := &vSwitchCoupling{
+		network:      network,
+		zone:         cloudSubnet.NetworkZone,
+		exposeRoutes: d.VSwitchExposeRoutes && !network.ExposeRoutesToVSwitch,
+	}
+	if vswitchSubnet != nil {
+		if d.VSwitchSubnet != "" && vswitchSubnet.IPRange.String() != d.VSwitchSubnet {
+			return nil, fmt.Errorf("vSwitch %d is coupled to network %v with subnet %v instead of %v",
+				d.VSwitchID, network.Name, vswitchSubnet.IPRange, d.VSwitchSubnet)
+		}
+		if vswitchSubnet.NetworkZone != cloudSubnet.NetworkZone {
+			return nil, fmt.Errorf("vSwitch subnet %v is in network zone %v, but cloud subnet %v is in %v",
+				vswitchSubnet.IPRange, vswitchSubnet.NetworkZone, cloudSubnet.IPRange, cloudSubnet.NetworkZone)
+		}
+		return coupling, nil
+	}
+
+	if d.VSwitchSubnet == "" {
+		return nil, fmt.Errorf("network %v is not coupled with vSwitch %d and --%v was not given", network.Name, d.VSwitchID, flagVSwitchSubnet)
+	}
+	if _, coupling.subnet, err = net.ParseCIDR(d.VSwitchSubnet); err != nil {
+		return nil, fmt.Errorf("could not parse vSwitch subnet: %w", err)
+	}
+	return coupling, nil
+}
+
+// setupVSwitch couples the network with the vSwitch, adding the vSwitch subnet and exposing routes if necessary; the
+// changes are reverted should creation fail
+func (d *Driver) setupVSwitch() error {
+	coupling, err := d.checkVSwitch()
+	if err != nil || coupling == nil {
+		return err
+	}
+	network := coupling.network
+
+	if coupling.subnet == nil {
+		log.Infof(" -> Network %v is coupled with vSwitch %d", network.Name, d.VSwitchID)
+	} else if err = d.addVSwitchSubnet(network, coupling.subnet, coupling.zone); err != nil {
+		return err
+	}
+
+	if coupling.exposeRoutes {
+		log.Infof(" -> Exposing routes of network %v to vSwitch...", network.Name)
+		if err = d.setExposeRoutesToVSwitch(network, true); err != nil {
+			return fmt.Errorf("could not expose routes to vSwitch: %w", err)
+		}
+		d.trackDangling("vSwitch route exposure", func() error {
+			return d.setExposeRoutesToVSwitch(network, false)
+		})
+	}
+	return nil
+}
+
+func (d *Driver) setExposeRoutesToVSwitch(network *hcloud.Network, expose bool) error {
+	_, _, err := d.getClient().Network.Update(context.Background(), network, hcloud.NetworkUpdateOpts{ExposeRoutesToVSwitch: &expose})
+	return err
+}
+
+func (d *Driver) addVSwitchSubnet(network *hcloud.Network, ipRange *net.IPNet, zone hcloud.NetworkZone) error {
+	log.Infof(" -> Coupling network %v with vSwitch %d via %v...", network.Name, d.VSwitchID, ipRange)
+	err := d.retry("vSwitch coupling", func() error {
+		act, _, err := d.getClient().Network.AddSubnet(context.Background(), network, hcloud.NetworkAddSubnetOpts{
+			Subnet: hcloud.NetworkSubnet{
+				Type:        hcloud.NetworkSubnetTypeVSwitch,
+				IPRange:     ipRange,
+				NetworkZone: zone,
+				VSwitchID:   d.VSwitchID,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("could not add vSwitch subnet: %w", err)
+		}
+
+		return d.waitForAction(act)
+	})
+	if err != nil {
+		return err
+	}
+
+	d.trackDangling("vSwitch subnet", func() error {
+		act, _, err := d.getClient().Network.DeleteSubnet(context.Background(), network, hcloud.NetworkDeleteSubnetOpts{
+			Subnet: hcloud.NetworkSubnet{IPRange: ipRange},
+		})
+		if err != nil {
+			return err
+		}
+		return d.waitForAction(act)
+	})
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/JonasProgrammer/docker-machine-driver-hetzner/driver"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// fleetingState is the state of an instance as reported to the fleeting autoscaler
+type fleetingState string
+
+const (
+	fleetingStateCreating fleetingState = "creating"
+	fleetingStateRunning  fleetingState = "running"
+	fleetingStateDeleting fleetingState = "deleting"
+)
+
+// fleetingGroup is an instance group of GitLab's fleeting autoscaler, configured by the plugin_config of the runner; its
+// instances are created from the config file
+type fleetingGroup struct {
+	Name       string `json:"name"`
+	Token      string `json:"token"`
+	Project    string `json:"project"`
+	ConfigFile string `json:"config_file"`
+	StorePath  string `json:"storage_path"`
+
+	group *driver.InstanceGroup
+}
+
+// fleetingConnectInfo describes how the autoscaler connects to an instance
+type fleetingConnectInfo struct {
+	ExternalAddr string
+	InternalAddr string
+	Username     string
+	Port         int
+	Key          []byte
+}
+
+// init validates the group configuration, falling back to the environment like the other commands
+func (g *fleetingGroup) init() error {
+	for _, env := range []string{"HETZNER_API_TOKEN", "HCLOUD_TOKEN"} {
+		if g.Token == "" && g.Project == "" {
+			g.Token = os.Getenv(env)
+		}
+	}
+	if g.Token == "" && g.Project == "" {
+		return errors.New("an API token is required, set token, project or HETZNER_API_TOKEN")
+	}
+	if g.StorePath == "" {
+		storePath, err := machineStorePath()
+		if err != nil {
+			return err
+		}
+		g.StorePath = storePath
+	}
+
+	values := map[string]interface{}{"hetzner-api-token": g.Token, "hetzner-project": g.Project}
+	if g.ConfigFile != "" {
+		values["hetzner-config-file"] = g.ConfigFile
+	}
+
+	var err error
+	g.group, err = driver.NewInstanceGroup(g.Name, g.StorePath, version, func(d *driver.Driver) error {
+		return d.SetConfigFromFlags(newCreateOptions(d, values))
+	})
+	return err
+}
+
+// update reports the state of every instance of the group to fn; instances not reported are considered deleted
+func (g *fleetingGroup) update(fn func(id string, state fleetingState)) error {
+	instances, err := g.group.List()
+	if err != nil {
+		return err
+	}
+	for _, instance := range instances {
+		fn(instance.ID, fleetingServerState(instance.Status))
+	}
+	return nil
+}
+
+// fleetingServerState maps the status of a server to the state of its instance; servers which are off are not
+// started again and therefore reported as deleting, so the autoscaler replaces them
+func fleetingServerState(status hcloud.ServerStatus) fleetingState {
+	switch status {
+	case hcloud.ServerStatusInitializing, hcloud.ServerStatusStarting:
+		return fleetingStateCreating
+	case hcloud.ServerStatusRunning:
+		return fleetingStateRunning
+	}
+	return fleetingStateDeleting
+}
+
+// increase creates n instances and returns how many were created successfully
+func (g *fleetingGroup) increase(n int) (int, error) {
+	created, err := g.group.ScaleUp(n)
+	return len(created), err
+}
+
+// decrease removes the given instances and returns those removed successfully
+func (g *fleetingGroup) decrease(ids []string) ([]string, error) {
+	return g.group.ScaleDown(ids)
+}
+
+// connectInfo returns the addresses and SSH credentials of an instance
+func (g *fleetingGroup) connectInfo(id string) (fleetingConnectInfo, error) {
+	d, err := g.group.Driver(id)
+	if err != nil {
+		return fleetingConnectInfo{}, err
+	}
+
+	info := fleetingConnectInfo{Username: d.GetSSHUsername()}
+	if info.ExternalAddr, err = d.GetSSHHostname(); err != nil {
+		return fleetingConnectInfo{}, fmt.Errorf("could not get address of instance %v: %w", id, err)
+	}
+	if info.Port, err = d.GetSSHPort(); err != nil {
+		return fleetingConnectInfo{}, err
+	}
+	if info.Key, err = os.ReadFile(d.GetSSHKeyPath()); err != nil {
+		return fleetingConnectInfo{}, fmt.Errorf("could not read ssh key of instance %v: %w", id, err)
+	}
+
+	srv, err := d.GetHcloudServer()
+	if err != nil {
+		return fleetingConnectInfo{}, fmt.Errorf("could not get server of instance %v: %w", id, err)
+	}
+	if len(srv.PrivateNet) > 0 {
+		info.InternalAddr = srv.PrivateNet[0].IP.String()
+	}
+	return info, nil
+}
@@ -0,0 +1,87 @@
+//go:build fleeting
+
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/JonasProgrammer/docker-machine-driver-hetzner/driver"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/hashicorp/go-hclog"
+	"gitlab.com/gitlab-org/fleeting/fleeting/plugin"
+	"gitlab.com/gitlab-org/fleeting/fleeting/provider"
+)
+
+// the fleeting command requires the fleeting SDK and is therefore only built with `-tags fleeting`
+func init() {
+	commands["fleeting"] = command{
+		usage: "serve as a GitLab fleeting plugin, configured by the plugin_config of the runner",
+		run: func(d *driver.Driver, flags *flag.FlagSet, args []string) error {
+			if err := flags.Parse(args); err != nil {
+				return err
+			}
+			// stdout carries the plugin handshake
+			log.SetOutWriter(os.Stderr)
+			plugin.Serve(&fleetingPlugin{})
+			return nil
+		},
+	}
+}
+
+// fleetingPlugin implements the instance group interface of the fleeting SDK; the runner's plugin_config is decoded
+// into the embedded group
+type fleetingPlugin struct {
+	fleetingGroup
+}
+
+var _ provider.InstanceGroup = (*fleetingPlugin)(nil)
+
+func (p *fleetingPlugin) Init(_ context.Context, _ hclog.Logger, settings provider.Settings) (provider.ProviderInfo, error) {
+	if err := p.init(); err != nil {
+		return provider.ProviderInfo{}, err
+	}
+	return provider.ProviderInfo{
+		ID:      "hetzner/" + p.Name,
+		MaxSize: -1,
+		Version: version,
+	}, nil
+}
+
+func (p *fleetingPlugin) Update(_ context.Context, fn func(instance string, state provider.State)) error {
+	return p.update(func(id string, state fleetingState) {
+		fn(id, provider.State(state))
+	})
+}
+
+func (p *fleetingPlugin) Increase(_ context.Context, n int) (int, error) {
+	return p.increase(n)
+}
+
+func (p *fleetingPlugin) Decrease(_ context.Context, instances []string) ([]string, error) {
+	return p.decrease(instances)
+}
+
+func (p *fleetingPlugin) ConnectInfo(_ context.Context, instance string) (provider.ConnectInfo, error) {
+	info, err := p.connectInfo(instance)
+	if err != nil {
+		return provider.ConnectInfo{}, err
+	}
+
+	ret := provider.ConnectInfo{
+		ID:           instance,
+		ExternalAddr: info.ExternalAddr,
+		InternalAddr: info.InternalAddr,
+	}
+	ret.OS = "linux"
+	ret.Protocol = provider.ProtocolSSH
+	ret.ProtocolPort = info.Port
+	ret.Username = info.Username
+	ret.Key = info.Key
+	return ret, nil
+}
+
+func (p *fleetingPlugin) Shutdown(context.Context) error {
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/JonasProgrammer/docker-machine-driver-hetzner/driver"
+)
+
+// machine is a docker-machine host from the machine store, keeping all fields but the driver configuration opaque
+type machine struct {
+	path   string
+	config map[string]json.RawMessage
+}
+
+// machineStorePath returns the docker-machine storage directory, honouring MACHINE_STORAGE_PATH like docker-machine
+func machineStorePath() (string, error) {
+	if path := os.Getenv("MACHINE_STORAGE_PATH"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine machine storage path: %w", err)
+	}
+	return filepath.Join(home, ".docker", "machine"), nil
+}
+
+// loadMachine reads the configuration of the named machine into d
+func loadMachine(storePath, name string, d *driver.Driver) (*machine, error) {
+	m := &machine{path: filepath.Join(storePath, "machines", name, "config.json")}
+
+	raw, err := os.ReadFile(m.path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read machine config: %w", err)
+	}
+	if err := json.Unmarshal(raw, &m.config); err != nil {
+		return nil, fmt.Errorf("could not parse machine config %v: %w", m.path, err)
+	}
+
+	var driverName string
+	if err := json.Unmarshal(m.config["DriverName"], &driverName); err != nil || driverName != d.DriverName() {
+		return nil, fmt.Errorf("machine %v does not use the %v driver", name, d.DriverName())
+	}
+	if err := json.Unmarshal(m.config["Driver"], d); err != nil {
+		return nil, fmt.Errorf("could not parse driver config of machine %v: %w", name, err)
+	}
+	return m, nil
+}
+
+// save writes the machine configuration back to the store, replacing the driver configuration with d
+func (m *machine) save(d *driver.Driver) error {
+	rawDriver, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("could not serialize driver config: %w", err)
+	}
+	m.config["Driver"] = rawDriver
+
+	raw, err := json.MarshalIndent(m.config, "", "    ")
+	if err != nil {
+		return fmt.Errorf("could not serialize machine config: %w", err)
+	}
+
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return fmt.Errorf("could not write machine config: %w", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return errors.Join(fmt.Errorf("could not replace machine config: %w", err), os.Remove(tmp))
+	}
+	return nil
+}
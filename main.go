@@ -4,20 +4,60 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/JonasProgrammer/docker-machine-driver-hetzner/driver"
 	"github.com/docker/machine/libmachine/drivers/plugin"
+	"github.com/docker/machine/libmachine/log"
 )
 
+// shutdownGracePeriod bounds the time in-flight operations get to roll back when the plugin process is terminated
+const shutdownGracePeriod = 5 * time.Minute
+
 // Version will be added once we start the build process by goreleaser
 var version string
 
 func main() {
 	versionFlag := flag.Bool("v", false, "prints current docker-machine-driver-hetzner version")
+	flag.Usage = func() {
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage: %v [-v] [command [flags]]\n", os.Args[0])
+		flag.PrintDefaults()
+		_, _ = fmt.Fprint(flag.CommandLine.Output(), commandUsage())
+	}
+	// the runner starts fleeting plugins without arguments, so installing the binary as fleeting-plugin-hetzner serves
+	// the fleeting command
+	if strings.HasPrefix(filepath.Base(os.Args[0]), "fleeting-plugin-") {
+		os.Exit(runCommand(append([]string{"fleeting"}, os.Args[1:]...)))
+	}
+
 	flag.Parse()
 	if *versionFlag {
 		fmt.Printf("Version: %s\n", version)
 		os.Exit(0)
 	}
-	plugin.RegisterDriver(driver.NewDriver(version))
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args()))
+	}
+
+	d := driver.NewDriver(version)
+	go shutdownOnSignal(d)
+	plugin.RegisterDriver(d)
+}
+
+// shutdownOnSignal interrupts the in-flight operations of the driver on SIGTERM or SIGINT and exits once they cleaned up,
+// so restarting the process running docker-machine does not leave half-created servers and keys behind
+func shutdownOnSignal(d *driver.Driver) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	sig := <-signals
+
+	log.Infof("Received %v, cleaning up in-flight operations ...", sig)
+	if !d.Shutdown(shutdownGracePeriod) {
+		log.Warnf("in-flight operations did not clean up within %v, resources may be left behind", shutdownGracePeriod)
+	}
+	os.Exit(1)
 }
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/JonasProgrammer/docker-machine-driver-hetzner/driver"
+)
+
+// prewarm creates n powered off standby machines of the pool from the given create flag values
+func prewarm(w io.Writer, storePath, pool string, n int, values map[string]interface{}) error {
+	for i := 0; i < n; i++ {
+		name, err := randomMachineName(pool + "-standby")
+		if err != nil {
+			return err
+		}
+
+		d := driver.NewDriver(version)
+		d.MachineName = name
+		d.StorePath = storePath
+		if err = d.SetConfigFromFlags(newCreateOptions(d, values)); err != nil {
+			return err
+		}
+		if err = d.CreateStandby(); err != nil {
+			return fmt.Errorf("could not create standby machine %v: %w", name, err)
+		}
+		_, _ = fmt.Fprintf(w, "created standby server %v in pool %v\n", name, pool)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+)
+
+// selftestStep is a single check of the self-test
+type selftestStep struct {
+	name string
+	run  func(m *throwawayMachine) error
+}
+
+var selftestSteps = []selftestStep{
+	{"pre-create check", func(m *throwawayMachine) error {
+		return m.PreCreateCheck()
+	}},
+	{"create", func(m *throwawayMachine) error {
+		return m.Create()
+	}},
+	{"state", func(m *throwawayMachine) error {
+		st, err := m.GetState()
+		if err != nil {
+			return err
+		}
+		if st != state.Running {
+			return fmt.Errorf("expected state %v, but got %v", state.Running, st)
+		}
+		return nil
+	}},
+	{"ip", func(m *throwawayMachine) error {
+		ip, err := m.GetIP()
+		if err != nil {
+			return err
+		}
+		if ip == "" {
+			return errors.New("no IP address")
+		}
+		return nil
+	}},
+	{"ssh", func(m *throwawayMachine) error {
+		// the server is running, but sshd may not be accepting connections yet
+		if err := drivers.WaitForSSH(m); err != nil {
+			return err
+		}
+		out, err := drivers.RunSSHCommandFromDriver(m, "echo selftest")
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(out) != "selftest" {
+			return fmt.Errorf("unexpected output %q", out)
+		}
+		return nil
+	}},
+}
+
+// selftest creates a throwaway server with the given create flag values, runs all checks against it and removes it
+// again, printing a pass/fail report to w; it fails if any check (or the removal) failed
+func selftest(w io.Writer, values map[string]interface{}) error {
+	m, err := newThrowawayMachine("selftest", values)
+	if err != nil {
+		return err
+	}
+	defer m.close()
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	defer tw.Flush()
+	_, _ = fmt.Fprintln(tw, "STEP\tRESULT\tDURATION\tDETAILS")
+
+	report := func(name string, start time.Time, err error) {
+		result, details := "PASS", ""
+		if err != nil {
+			result, details = "FAIL", err.Error()
+		}
+		_, _ = fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", name, result, time.Since(start).Round(time.Millisecond), details)
+	}
+
+	var failed bool
+	for _, step := range selftestSteps {
+		start := time.Now()
+		err := step.run(m)
+		report(step.name, start, err)
+		if err != nil {
+			failed = true
+			break
+		}
+	}
+
+	// always attempt removal, as a failed creation may still leave a server behind
+	start := time.Now()
+	err = m.Remove()
+	report("remove", start, err)
+
+	if failed || err != nil {
+		return errors.New("self-test failed")
+	}
+	return nil
+}
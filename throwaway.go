@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/JonasProgrammer/docker-machine-driver-hetzner/driver"
+	"github.com/docker/machine/libmachine/mcnflag"
+)
+
+// createOptions provides driver options for machines created outside of docker-machine, falling back to the defaults
+// of the driver's create flags
+type createOptions map[string]interface{}
+
+func newCreateOptions(d *driver.Driver, values map[string]interface{}) createOptions {
+	opts := createOptions{}
+	for _, flag := range d.GetCreateFlags() {
+		if value := flag.Default(); value != nil {
+			opts[flag.String()] = value
+		}
+	}
+	for name, value := range values {
+		opts[name] = value
+	}
+	return opts
+}
+
+// envCreateValues returns the create flag values given by the flags' environment variables, like docker-machine does
+func envCreateValues(d *driver.Driver) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, flag := range d.GetCreateFlags() {
+		var envVar string
+		var parse func(string) interface{}
+		switch f := flag.(type) {
+		case mcnflag.StringFlag:
+			envVar, parse = f.EnvVar, func(v string) interface{} { return v }
+		case mcnflag.IntFlag:
+			envVar, parse = f.EnvVar, func(v string) interface{} { i, _ := strconv.Atoi(v); return i }
+		case mcnflag.BoolFlag:
+			envVar, parse = f.EnvVar, func(v string) interface{} { b, _ := strconv.ParseBool(v); return b }
+		case mcnflag.StringSliceFlag:
+			envVar, parse = f.EnvVar, func(v string) interface{} { return strings.Split(v, ",") }
+		}
+		if value, ok := os.LookupEnv(envVar); ok && envVar != "" {
+			values[flag.String()] = parse(value)
+		}
+	}
+	return values
+}
+
+// addCreateFlags adds the create flags of the driver to a command's flag set; the returned function yields the values
+// of those given on the command line once it has been parsed
+func addCreateFlags(d *driver.Driver, flags *flag.FlagSet) func() map[string]interface{} {
+	getters := make(map[string]func() interface{})
+	for _, createFlag := range d.GetCreateFlags() {
+		name := createFlag.String()
+		switch f := createFlag.(type) {
+		case mcnflag.StringFlag:
+			value := flags.String(name, f.Value, f.Usage)
+			getters[name] = func() interface{} { return *value }
+		case mcnflag.IntFlag:
+			value := flags.Int(name, f.Value, f.Usage)
+			getters[name] = func() interface{} { return *value }
+		case mcnflag.BoolFlag:
+			value := flags.Bool(name, false, f.Usage)
+			getters[name] = func() interface{} { return *value }
+		case mcnflag.StringSliceFlag:
+			value := new(stringSlice)
+			flags.Var(value, name, f.Usage)
+			getters[name] = func() interface{} { return []string(*value) }
+		}
+	}
+
+	return func() map[string]interface{} {
+		values := make(map[string]interface{})
+		flags.Visit(func(f *flag.Flag) {
+			if get, ok := getters[f.Name]; ok {
+				values[f.Name] = get()
+			}
+		})
+		return values
+	}
+}
+
+func (o createOptions) String(key string) string {
+	value, _ := o[key].(string)
+	return value
+}
+
+func (o createOptions) StringSlice(key string) []string {
+	value, _ := o[key].([]string)
+	return value
+}
+
+func (o createOptions) Int(key string) int {
+	value, _ := o[key].(int)
+	return value
+}
+
+func (o createOptions) Bool(key string) bool {
+	value, _ := o[key].(bool)
+	return value
+}
+
+// throwawayMachine is a machine created by a command for testing purposes, stored in a temporary directory
+type throwawayMachine struct {
+	*driver.Driver
+	storePath string
+}
+
+// newThrowawayMachine configures, but does not create, a machine with a random name based on prefix, using the given
+// create flag values on top of the defaults
+func newThrowawayMachine(prefix string, values map[string]interface{}) (*throwawayMachine, error) {
+	name, err := randomMachineName(prefix)
+	if err != nil {
+		return nil, err
+	}
+	storePath, err := os.MkdirTemp("", "docker-machine-driver-hetzner-")
+	if err != nil {
+		return nil, fmt.Errorf("could not create machine store: %w", err)
+	}
+
+	d := driver.NewDriver(version)
+	d.MachineName = name
+	d.StorePath = storePath
+	if err := d.SetConfigFromFlags(newCreateOptions(d, values)); err != nil {
+		_ = os.RemoveAll(storePath)
+		return nil, err
+	}
+	return &throwawayMachine{Driver: d, storePath: storePath}, nil
+}
+
+// close discards the machine's local state; the server must have been removed before
+func (m *throwawayMachine) close() {
+	_ = os.RemoveAll(m.storePath)
+}
+
+// randomMachineName returns a machine name consisting of prefix and a random suffix
+func randomMachineName(prefix string) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("could not generate machine name: %w", err)
+	}
+	return prefix + "-" + hex.EncodeToString(suffix), nil
+}